@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs 获取定时任务列表（下次执行时间、最近一次运行）
+func GetJobs(c *gin.Context) {
+	scheduler := services.GetScheduler()
+	if scheduler == nil {
+		utils.Error(c, http.StatusServiceUnavailable, "调度器未启动")
+		return
+	}
+
+	jobs, err := scheduler.Jobs(database.GetDB())
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取定时任务失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", jobs)
+}
+
+// GetJobRuns 获取定时任务的运行记录（所有实例）
+func GetJobRuns(c *gin.Context) {
+	scheduler := services.GetScheduler()
+	if scheduler == nil {
+		utils.Error(c, http.StatusServiceUnavailable, "调度器未启动")
+		return
+	}
+	name := c.Param("name")
+	if !scheduler.HasJob(name) {
+		utils.Error(c, http.StatusNotFound, services.ErrJobNotFound.Error())
+		return
+	}
+
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	db := database.GetDB()
+
+	query := db.Model(&models.JobRun{}).Where("job_name = ?", name)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if triggeredBy := c.Query("triggeredBy"); triggeredBy != "" {
+		query = query.Where("triggered_by = ?", triggeredBy)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []models.JobRun
+	err := query.Order("started_at DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int((page - 1) * limit)).
+		Find(&runs).Error
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取运行记录失败")
+		return
+	}
+
+	responses := make([]models.JobRunResponse, 0, len(runs))
+	for i := range runs {
+		responses = append(responses, runs[i].ToResponse())
+	}
+
+	utils.SuccessWithPagination(c, "获取成功", responses, utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	})
+}
+
+// TriggerJob 立即运行定时任务，运行结束后返回本次运行记录
+func TriggerJob(c *gin.Context) {
+	scheduler := services.GetScheduler()
+	if scheduler == nil {
+		utils.Error(c, http.StatusServiceUnavailable, "调度器未启动")
+		return
+	}
+
+	run, err := scheduler.TriggerJob(c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			utils.Error(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrJobRunning), errors.Is(err, services.ErrJobLeased):
+			utils.Error(c, http.StatusConflict, err.Error())
+		default:
+			utils.Error(c, http.StatusInternalServerError, "运行定时任务失败")
+		}
+		return
+	}
+
+	message := "任务运行完成"
+	if run.Status == models.JobRunFailed {
+		message = "任务运行失败"
+	}
+	utils.Success(c, message, run.ToResponse())
+}
@@ -1,72 +1,106 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 	"course-management-backend/database"
 	"course-management-backend/models"
+	"course-management-backend/services"
 	"course-management-backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetUpcomingCourses 获取即将开始的课程
 func GetUpcomingCourses(c *gin.Context) {
 	userID := c.GetUint("userID")
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "1"))
+	if days < 0 {
+		days = 0
+	}
 
 	db := database.GetDB()
-	today := time.Now()
-	_ = today.AddDate(0, 0, days) // 临时变量，用于扩展功能
-
-	var courses []models.Course
-	err := db.Where("user_id = ? AND is_active = ?", userID, true).
-		Preload("Schedules", "is_active = ?", true).
-		Find(&courses).Error
+	// 按用户时区计算今天
+	today := time.Now().In(userLocation(c))
+	from, _ := services.DayRange(today)
+	to := from.AddDate(0, 0, days+1)
 
+	occurrences, err := services.LoadAllOccurrences(db, userID, from, to)
 	if err != nil {
 		utils.Error(c, http.StatusInternalServerError, "查询课程失败")
 		return
 	}
 
+	// 批量查询范围内已有的出勤记录
+	attended, err := loadAttendanceKeys(occurrences, from, to)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询出勤记录失败")
+		return
+	}
+
 	var upcomingCourses []gin.H
-	for _, course := range courses {
-		for _, schedule := range course.Schedules {
-			// 计算未来几天内的上课日期
-			for d := 0; d <= days; d++ {
-				currentDate := today.AddDate(0, 0, d)
-				weekday := int(currentDate.Weekday())
-				if weekday == 0 {
-					weekday = 7 // 周日转换为7
-				}
-
-				if weekday == schedule.Weekday {
-					// 检查是否已经有出勤记录
-					var existingAttendance models.AttendanceRecord
-					db.Where("course_id = ? AND schedule_date = ?", course.ID, currentDate.Format("2006-01-02")).
-						First(&existingAttendance)
-
-					upcomingCourses = append(upcomingCourses, gin.H{
-						"courseId":     course.ID,
-						"courseName":   course.Name,
-						"scheduleDate": currentDate.Format("2006-01-02"),
-						"weekday":      schedule.Weekday,
-						"startTime":    schedule.StartTime,
-						"endTime":      schedule.EndTime,
-						"location":     schedule.Location,
-						"instructor":   schedule.Instructor,
-						"hasAttendance": existingAttendance.ID > 0,
-					})
-				}
-			}
+	for _, occurrence := range occurrences {
+		item := gin.H{
+			"courseId":     occurrence.Course.ID,
+			"courseName":   occurrence.Course.Name,
+			"scheduleDate": occurrence.Date,
+			"weekday":      occurrence.Schedule.Weekday,
+			"startTime":    occurrence.Schedule.StartTime,
+			"endTime":      occurrence.Schedule.EndTime,
+			"location":     occurrence.Schedule.Location,
+			"instructor":   occurrence.Schedule.Instructor,
+			"hasAttendance": attended[attendanceKey(occurrence.Course.ID, occurrence.Date)] > 0,
+			"cancelled":    occurrence.Cancelled(),
+			"rescheduled":  occurrence.Rescheduled(),
+		}
+		if occurrence.Override != nil {
+			item["overrideId"] = occurrence.Override.ID
+			item["originalDate"] = models.DateOnly(occurrence.Override.OriginalDate)
+			item["originalStartTime"] = occurrence.Override.OriginalStartTime
+			item["reason"] = occurrence.Override.Reason
 		}
+		upcomingCourses = append(upcomingCourses, item)
 	}
 
 	utils.Success(c, "获取成功", upcomingCourses)
 }
 
+// loadAttendanceKeys 查询上课实例对应的出勤记录，返回 课程ID+日期 -> 出勤记录ID
+func loadAttendanceKeys(occurrences []services.Occurrence, from, to time.Time) (map[string]uint, error) {
+	keys := make(map[string]uint)
+	if len(occurrences) == 0 {
+		return keys, nil
+	}
+
+	courseIDs := make([]uint, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		courseIDs = append(courseIDs, occurrence.Course.ID)
+	}
+
+	// 排课单独设置时区时上课日期可能落在相邻一天，查询范围前后各放宽一天
+	var records []models.AttendanceRecord
+	err := database.GetDB().
+		Where("course_id IN ? AND schedule_date >= ? AND schedule_date <= ?", courseIDs, from.AddDate(0, 0, -1).Format("2006-01-02"), to.Format("2006-01-02")).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		keys[attendanceKey(record.CourseID, models.DateOnly(record.ScheduleDate))] = record.ID
+	}
+	return keys, nil
+}
+
+// attendanceKey 出勤记录的唯一键
+func attendanceKey(courseID uint, date string) string {
+	return fmt.Sprintf("%d-%s", courseID, date)
+}
+
 // CreateAttendance 创建出勤记录
 func CreateAttendance(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -85,30 +119,27 @@ func CreateAttendance(c *gin.Context) {
 
 	// 验证课程是否存在且属于当前用户
 	var course models.Course
-	if err := db.Where("id = ? AND user_id = ?", req.CourseID, userID).First(&course).Error; err != nil {
+	if err := services.PreloadForOccurrences(db.Where("id = ? AND user_id = ?", req.CourseID, userID)).First(&course).Error; err != nil {
 		utils.Error(c, http.StatusNotFound, "课程不存在")
 		return
 	}
 
-	// 检查是否已有出勤记录
-	var existingAttendance models.AttendanceRecord
-	if err := db.Where("course_id = ? AND schedule_date = ?", req.CourseID, req.ScheduleDate).
-		First(&existingAttendance).Error; err == nil {
-		utils.Error(c, http.StatusBadRequest, "该日期已有出勤记录")
+	// 检查调课/机构取消
+	if err := services.CheckAttendanceDate(&course, req.ScheduleDate); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// 创建出勤记录
-	attendance := models.AttendanceRecord{
-		CourseID:     req.CourseID,
-		ScheduleDate: req.ScheduleDate,
-		Status:       "pending",
-	}
-
-	if err := db.Create(&attendance).Error; err != nil {
+	// 创建出勤记录（同一天只能有一条）
+	attendance, created, err := services.EnsureAttendanceRecord(db, req.CourseID, req.ScheduleDate)
+	if err != nil {
 		utils.Error(c, http.StatusInternalServerError, "创建出勤记录失败")
 		return
 	}
+	if !created {
+		utils.Error(c, http.StatusBadRequest, "该日期已有出勤记录")
+		return
+	}
 
 	utils.Success(c, "创建成功", attendance)
 }
@@ -144,176 +175,135 @@ func UpdateAttendance(c *gin.Context) {
 		return
 	}
 
-	// 更新出勤状态
-	attendance.Status = req.Status
+	// 更新出勤状态（补课校验、退回不消课状态下的课时）并按需自动消课，在同一事务中完成
 	attendance.Notes = req.Notes
-
-	if req.Status == "attend" {
-		now := time.Now()
+	if models.IsAttended(req.Status) {
+		now := time.Now().In(userLocation(c))
 		attendance.CheckInTime = &now
 	}
 
-	if err := db.Save(&attendance).Error; err != nil {
-		utils.Error(c, http.StatusInternalServerError, "更新出勤记录失败")
+	var consumptions, refunded []models.SessionConsumption
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		refunded, err = services.SetAttendanceStatus(tx, &attendance, req.Status, req.MakeupForID)
+		if err != nil || !req.ConsumeSession {
+			return err
+		}
+
+		shouldConsume, err := services.ShouldConsumeSession(tx, &attendance)
+		if err != nil || !shouldConsume {
+			return err
+		}
+
+		consumptions, err = services.ConsumeSessionsForAttendance(tx, &attendance.Course, &attendance, 1, "签到自动消课")
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInsufficientSessions), errors.Is(err, services.ErrOccurrenceCancelled),
+			errors.Is(err, services.ErrMakeupRequired), errors.Is(err, services.ErrInvalidMakeup):
+			utils.Error(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrMakeupExists), errors.Is(err, services.ErrMakeupReferenced):
+			utils.Error(c, http.StatusConflict, err.Error())
+		default:
+			utils.Error(c, http.StatusInternalServerError, "更新出勤记录失败")
+		}
 		return
 	}
 
-	utils.Success(c, "更新成功", attendance)
+	for i := range consumptions {
+		if err := services.SendConsumptionConfirmation(&attendance.Course, &attendance, &consumptions[i]); err != nil {
+			fmt.Printf("发送消课通知失败: %v\n", err)
+		}
+	}
+
+	// 始终返回相同结构，未消课或未退回时为空数组
+	if consumptions == nil {
+		consumptions = []models.SessionConsumption{}
+	}
+	if refunded == nil {
+		refunded = []models.SessionConsumption{}
+	}
+	utils.Success(c, "更新成功", gin.H{
+		"attendance":   attendance,
+		"consumptions": consumptions,
+		"refunded":     refunded,
+	})
 }
 
-// GetReminders 获取需要提醒的出勤记录（24小时内）
+// GetReminders 获取提醒提前量内即将开始的课程（只读，提醒由定时任务发送）
 func GetReminders(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	fmt.Printf("🔔 开始检查提醒 - 用户ID: %d\n", userID)
-
 	db := database.GetDB()
-	now := time.Now()
-	fmt.Printf("⏰ 当前时间: %s\n", now.Format("2006-01-02 15:04:05"))
-
-	var reminders []gin.H
+	now := time.Now().In(userLocation(c))
 
-	// 查询活跃课程
-	var courses []models.Course
-	err := db.Where("user_id = ? AND is_active = ?", userID, true).
-		Preload("Schedules", "is_active = ?", true).
-		Find(&courses).Error
+	preference, err := services.GetNotificationPreference(userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知偏好失败")
+		return
+	}
+	mutedCourseIDs, err := services.GetMutedCourseIDs(db, userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知偏好失败")
+		return
+	}
+	muted := make(map[uint]bool, len(mutedCourseIDs))
+	for _, courseID := range mutedCourseIDs {
+		muted[courseID] = true
+	}
 
+	// 展开最大提醒提前量内开始的上课实例
+	window := 24 * time.Hour
+	if leadTimes := services.ReminderLeadTimes(preference); len(leadTimes) > 0 {
+		window = leadTimes[0]
+	}
+	occurrences, err := services.LoadOccurrences(db, userID, now, now.Add(window))
 	if err != nil {
-		fmt.Printf("❌ 查询课程失败: %v\n", err)
 		utils.Error(c, http.StatusInternalServerError, "查询课程失败")
 		return
 	}
 
-	fmt.Printf("📊 查询到 %d 个活跃课程\n", len(courses))
-
-	// 显示所有课程和排课信息
-	for _, course := range courses {
-		fmt.Printf("📚 课程: %s (ID: %d), 排课数量: %d\n", course.Name, course.ID, len(course.Schedules))
-		for _, schedule := range course.Schedules {
-			fmt.Printf("  📅 排课: 星期%d, %s-%s\n", schedule.Weekday, schedule.StartTime, schedule.EndTime)
+	// 批量查询已有的出勤记录（不创建新记录），排课时区可能不同，范围前后各放宽一天
+	from, _ := services.DayRange(now.AddDate(0, 0, -1))
+	to, _ := services.DayRange(now.Add(window).AddDate(0, 0, 1))
+	records := make(map[string]models.AttendanceRecord)
+	if len(occurrences) > 0 {
+		courseIDs := make([]uint, 0, len(occurrences))
+		for _, occurrence := range occurrences {
+			courseIDs = append(courseIDs, occurrence.Course.ID)
+		}
+		var attendances []models.AttendanceRecord
+		err := db.Where("course_id IN ? AND schedule_date >= ? AND schedule_date <= ?", courseIDs, from.Format("2006-01-02"), to.Format("2006-01-02")).
+			Find(&attendances).Error
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "查询出勤记录失败")
+			return
+		}
+		for _, attendance := range attendances {
+			records[attendanceKey(attendance.CourseID, models.DateOnly(attendance.ScheduleDate))] = attendance
 		}
 	}
 
-	for _, course := range courses {
-		fmt.Printf("\n🎯 开始检查课程: %s (ID: %d)\n", course.Name, course.ID)
-		
-		for _, schedule := range course.Schedules {
-			fmt.Printf("  📅 检查排课: 星期%d, %s-%s\n", schedule.Weekday, schedule.StartTime, schedule.EndTime)
-			
-			// 检查未来24小时内的课程（扩展到未来7天，确保覆盖）
-			fmt.Printf("    🔍 检查未来7天课程安排...\n")
-			for d := 0; d <= 6; d++ { // 今天到未来6天
-				checkDate := now.AddDate(0, 0, d)
-				checkDateStr := checkDate.Format("2006-01-02")
-				
-				// 检查是否是上课日
-				weekday := int(checkDate.Weekday())
-				if weekday == 0 {
-					weekday = 7
-				}
-
-				fmt.Printf("    📋 检查日期: %s (星期%d), 排课星期: %d\n", checkDateStr, weekday, schedule.Weekday)
-
-				if weekday == schedule.Weekday {
-					fmt.Printf("    ✅ 是上课日!\n")
-					
-					// 检查是否已经发送过提醒
-					var attendance models.AttendanceRecord
-					db.Where("course_id = ? AND schedule_date = ?", course.ID, checkDateStr).
-						First(&attendance)
-
-					fmt.Printf("    📊 出勤记录状态: 存在=%t, 已提醒=%t\n", attendance.ID > 0, attendance.ReminderSent)
-
-					// 计算课程开始时间
-					scheduleTime, err := time.Parse("15:04", schedule.StartTime)
-					if err != nil {
-						fmt.Printf("    ❌ 解析时间失败: %v\n", err)
-						continue
-					}
-					
-					courseStartTime := time.Date(
-						checkDate.Year(), checkDate.Month(), checkDate.Day(),
-						scheduleTime.Hour(), scheduleTime.Minute(), 0, 0, time.Local,
-					)
-					
-					timeDiff := courseStartTime.Sub(now)
-					twentyFourHours := 24 * time.Hour
-					
-					fmt.Printf("    ⏱️ 时间差: %v (%.2f小时)\n", timeDiff, timeDiff.Hours())
-					fmt.Printf("    📅 课程开始时间: %s\n", courseStartTime.Format("2006-01-02 15:04:05"))
-					fmt.Printf("    📊 判断条件: timeDiff > 0 && timeDiff <= 24h -> %t && %t\n", 
-						timeDiff > 0, timeDiff <= twentyFourHours)
-					
-					// 调试：检查具体的课程信息
-					fmt.Printf("    🔍 课程详细信息: 课程ID=%d, 课程名=%s, 排课星期=%d, 开始时间=%s\n", 
-						course.ID, course.Name, schedule.Weekday, schedule.StartTime)
-					
-					// 只提醒24小时内的课程
-					if timeDiff > 0 && timeDiff <= twentyFourHours {
-						fmt.Printf("    ✅ 课程在24小时内!\n")
-						
-						// 如果没有出勤记录，先创建出勤记录
-						if attendance.ID == 0 {
-							fmt.Printf("    ➕ 创建新的出勤记录\n")
-							attendance = models.AttendanceRecord{
-								CourseID:     course.ID,
-								ScheduleDate: checkDateStr,
-								Status:       "pending",
-								ReminderSent: false,
-							}
-							if err := db.Create(&attendance).Error; err != nil {
-								fmt.Printf("    ❌ 创建出勤记录失败: %v\n", err)
-								continue
-							}
-							fmt.Printf("    ✅ 出勤记录创建成功, ID: %d\n", attendance.ID)
-						}
-
-						// 如果未发送提醒
-						if !attendance.ReminderSent {
-							fmt.Printf("    🔔 添加提醒到列表\n")
-							reminders = append(reminders, gin.H{
-								"courseId":     course.ID,
-								"courseName":   course.Name,
-								"scheduleDate": checkDateStr,
-								"startTime":    schedule.StartTime,
-								"endTime":      schedule.EndTime,
-								"attendanceId": attendance.ID,
-							})
-
-							// 标记为已发送提醒
-							attendance.ReminderSent = true
-							if err := db.Save(&attendance).Error; err != nil {
-								fmt.Printf("    ❌ 更新提醒状态失败: %v\n", err)
-							} else {
-								fmt.Printf("    ✅ 提醒状态更新成功\n")
-							}
-						} else {
-							fmt.Printf("    ⚠️ 提醒已发送过，跳过\n")
-						}
-					} else {
-						fmt.Printf("    ❌ 课程不在24小时内或已过期\n")
-						if timeDiff <= 0 {
-							fmt.Printf("    💡 原因: 课程已开始或已过期\n")
-						} else {
-							fmt.Printf("    💡 原因: 课程还有%.2f小时才开始\n", timeDiff.Hours())
-						}
-					}
-				} else {
-					fmt.Printf("    ❌ 不是上课日 (排课: 星期%d, 检查日: 星期%d)\n", schedule.Weekday, weekday)
-				}
-			}
+	reminders := []gin.H{}
+	for _, occurrence := range occurrences {
+		course := occurrence.Course
+		if muted[course.ID] {
+			continue
 		}
-	}
 
-	fmt.Printf("🔚 提醒检查完成，找到 %d 个需要提醒的课程\n", len(reminders))
-	if len(reminders) == 0 {
-		fmt.Printf("❌ 未找到需要提醒的课程，可能原因:")
-		fmt.Printf("   1. 课程时间不在24小时内")
-		fmt.Printf("   2. 课程状态不活跃")
-		fmt.Printf("   3. 排课设置有问题")
-		fmt.Printf("   4. 今天没有对应排课的课程")
+		attendance := records[attendanceKey(course.ID, occurrence.Date)]
+		reminders = append(reminders, gin.H{
+			"courseId":     course.ID,
+			"courseName":   course.Name,
+			"scheduleDate": occurrence.Date,
+			"startTime":    occurrence.Schedule.StartTime,
+			"endTime":      occurrence.Schedule.EndTime,
+			"attendanceId": attendance.ID,
+			"reminderSent": attendance.ReminderSent,
+		})
 	}
+
 	utils.Success(c, "获取成功", reminders)
 }
 
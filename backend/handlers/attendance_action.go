@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAttendanceAction 查看快捷操作token对应的课程（通过token访问，无需登录），供确认页展示
+func GetAttendanceAction(c *gin.Context) {
+	db := database.GetDB()
+
+	record, err := services.LookupAttendanceAction(db, c.Query("token"))
+	if err != nil {
+		utils.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", record.CourseID, record.UserID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, services.ErrActionTokenInvalid.Error())
+		return
+	}
+
+	date := models.DateOnly(record.ScheduleDate)
+	status := "pending"
+	var attendance models.AttendanceRecord
+	if err := db.Where("course_id = ? AND schedule_date = ?", record.CourseID, date).First(&attendance).Error; err == nil {
+		status = attendance.Status
+	}
+
+	utils.Success(c, "获取成功", gin.H{
+		"courseId":     course.ID,
+		"courseName":   course.Name,
+		"scheduleDate": date,
+		"status":       status,
+		"used":         record.UsedAt != nil,
+		"usedAction":   record.UsedAction,
+		"expiresAt":    record.ExpiresAt,
+	})
+}
+
+// SubmitAttendanceAction 通过通知中的快捷操作记录出勤（token一次性有效，无需登录）
+func SubmitAttendanceAction(c *gin.Context) {
+	var req models.AttendanceActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	result, err := services.RedeemAttendanceAction(database.GetDB(), req.Token, req.Action, req.Notes)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActionTokenInvalid):
+			utils.Error(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrActionTokenUsed), errors.Is(err, services.ErrAttendanceAlreadyMarked):
+			utils.Error(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrInsufficientSessions), errors.Is(err, services.ErrOccurrenceCancelled),
+			errors.Is(err, services.ErrAttendanceUnavailable):
+			utils.Error(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.Error(c, http.StatusInternalServerError, "记录出勤失败")
+		}
+		return
+	}
+
+	for i := range result.Consumptions {
+		if err := services.SendConsumptionConfirmation(&result.Course, &result.Attendance, &result.Consumptions[i]); err != nil {
+			fmt.Printf("发送消课通知失败: %v\n", err)
+		}
+	}
+
+	message := "已记录上课"
+	if req.Action == "absent" {
+		message = "已记录请假"
+	}
+	utils.Success(c, message, gin.H{
+		"courseName":   result.Course.Name,
+		"attendance":   result.Attendance,
+		"consumptions": result.Consumptions,
+	})
+}
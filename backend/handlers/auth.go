@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 	"course-management-backend/database"
 	"course-management-backend/models"
+	"course-management-backend/services"
 	"course-management-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +17,17 @@ func Register(c *gin.Context) {
 		Username string `json:"username" binding:"required,min=3,max=50"`
 		Password string `json:"password" binding:"required,min=6"`
 		Email    string `json:"email" binding:"omitempty,email"`
+		Timezone string `json:"timezone"` // IANA时区，可选（前端可传浏览器时区）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationError(c, err.Error())
 		return
 	}
+	if err := services.ValidateTimezone(req.Timezone); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	db := database.GetDB()
 
@@ -36,6 +43,7 @@ func Register(c *gin.Context) {
 		Username: req.Username,
 		Password: req.Password, // 会在BeforeCreate钩子中加密
 		Email:    req.Email,
+		Timezone: req.Timezone,
 	}
 
 	if err := db.Create(&user).Error; err != nil {
@@ -102,4 +110,51 @@ func GetProfile(c *gin.Context) {
 	utils.Success(c, "获取成功", gin.H{
 		"user": user.(*models.User).ToResponse(),
 	})
-}
\ No newline at end of file
+}
+
+// UpdateProfile 更新当前用户信息（邮箱、时区，未传的字段保持不变）
+func UpdateProfile(c *gin.Context) {
+	var req struct {
+		Email    *string `json:"email" binding:"omitempty,email"`
+		Timezone *string `json:"timezone"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	value, _ := c.Get("user")
+	user := value.(*models.User)
+
+	updates := map[string]interface{}{}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.Timezone != nil {
+		if err := services.ValidateTimezone(*req.Timezone); err != nil {
+			utils.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+
+	if len(updates) > 0 {
+		if err := database.GetDB().Model(user).Updates(updates).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "更新用户信息失败")
+			return
+		}
+	}
+
+	utils.Success(c, "更新成功", gin.H{
+		"user": user.ToResponse(),
+	})
+}
+
+// userLocation 当前登录用户的时区
+func userLocation(c *gin.Context) *time.Location {
+	if user, ok := c.Get("user"); ok {
+		return services.UserLocation(user.(*models.User))
+	}
+	return time.Local
+}
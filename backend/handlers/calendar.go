@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"course-management-backend/config"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCalendarFeedToken 获取当前日历订阅token状态（不返回token明文）
+func GetCalendarFeedToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	db := database.GetDB()
+
+	var feedToken models.CalendarFeedToken
+	if err := db.Where("user_id = ? AND revoked_at IS NULL", userID).First(&feedToken).Error; err != nil {
+		utils.Success(c, "获取成功", gin.H{"active": false})
+		return
+	}
+
+	utils.Success(c, "获取成功", gin.H{
+		"active":     true,
+		"createdAt":  feedToken.CreatedAt,
+		"lastUsedAt": feedToken.LastUsedAt,
+	})
+}
+
+// CreateCalendarFeedToken 生成新的日历订阅token，旧token同时失效
+func CreateCalendarFeedToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "生成订阅token失败")
+		return
+	}
+
+	db := database.GetDB()
+
+	feedToken := models.CalendarFeedToken{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeCalendarFeedTokens(tx, userID); err != nil {
+			return err
+		}
+		return tx.Create(&feedToken).Error
+	})
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "生成订阅token失败")
+		return
+	}
+
+	feedURL := fmt.Sprintf("%s/api/calendar/%s.ics", publicBaseURL(c), token)
+	utils.Success(c, "订阅链接已生成，请妥善保存", gin.H{
+		"token":     token,
+		"url":       feedURL,
+		"webcalUrl": "webcal://" + strings.SplitN(feedURL, "://", 2)[1],
+		"createdAt": feedToken.CreatedAt,
+	})
+}
+
+// RevokeCalendarFeedToken 撤销日历订阅token
+func RevokeCalendarFeedToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	if err := revokeCalendarFeedTokens(database.GetDB(), userID); err != nil {
+		utils.Error(c, http.StatusInternalServerError, "撤销订阅失败")
+		return
+	}
+
+	utils.Success(c, "订阅已撤销", nil)
+}
+
+// GetCalendarFeed 输出用户课程的iCalendar订阅（通过订阅token访问，无需登录）
+func GetCalendarFeed(c *gin.Context) {
+	token := c.Param("token")
+	if !strings.HasSuffix(token, ".ics") {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	token = strings.TrimSuffix(token, ".ics")
+
+	db := database.GetDB()
+
+	var feedToken models.CalendarFeedToken
+	if err := db.Where("token_hash = ? AND revoked_at IS NULL", utils.HashToken(token)).
+		Preload("User").First(&feedToken).Error; err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+
+	var courses []models.Course
+	err := services.PreloadForOccurrences(db.Where("user_id = ? AND is_active = ?", feedToken.UserID, true)).
+		Order("id ASC").
+		Find(&courses).Error
+	if err != nil {
+		c.String(http.StatusInternalServerError, "查询课程失败")
+		return
+	}
+
+	now := time.Now()
+	db.Model(&feedToken).Update("last_used_at", &now)
+
+	// 提醒使用用户设置的提前量，关闭提醒的课程不输出VALARM
+	preference, err := services.GetNotificationPreference(feedToken.UserID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "查询通知偏好失败")
+		return
+	}
+	mutedCourseIDs, err := services.GetMutedCourseIDs(db, feedToken.UserID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "查询通知偏好失败")
+		return
+	}
+
+	name := fmt.Sprintf("%s的课程", feedToken.User.Username)
+	body := services.RenderCalendar(name, courses, services.ReminderLeadTimes(preference), mutedCourseIDs, services.UserLocation(&feedToken.User))
+
+	c.Header("Content-Disposition", `inline; filename="courses.ics"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(body))
+}
+
+// revokeCalendarFeedTokens 撤销用户所有有效的订阅token
+func revokeCalendarFeedTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&models.CalendarFeedToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// publicBaseURL 获取对外访问的后端地址
+func publicBaseURL(c *gin.Context) string {
+	if baseURL := config.GetEnv("PUBLIC_BASE_URL", ""); baseURL != "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"course-management-backend/database"
 	"course-management-backend/models"
+	"course-management-backend/services"
 	"course-management-backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateConsumption 创建课时消耗记录
@@ -43,33 +46,23 @@ func CreateConsumption(c *gin.Context) {
 	}
 
 	// 验证出勤状态
-	if attendance.Status != "attend" {
-		utils.Error(c, http.StatusBadRequest, "只有已上课的出勤记录才能消耗课时")
+	if !models.CanConsumeSession(attendance.Status) {
+		utils.Error(c, http.StatusBadRequest, "只有上课、迟到、补课或缺席的出勤记录才能消耗课时")
 		return
 	}
 
-	// 检查剩余课时是否足够
-	remaining, err := attendance.Course.GetRemainingSessions(db)
+	// 在事务中检查对应课时池余额并创建消耗记录
+	var consumption *models.SessionConsumption
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		consumption, err = services.ConsumeFromPool(tx, &attendance, req.SessionType, req.SessionsConsumed, req.Description)
+		return err
+	})
 	if err != nil {
-		utils.Error(c, http.StatusInternalServerError, "查询剩余课时失败")
-		return
-	}
-
-	if int64(req.SessionsConsumed) > remaining {
-		utils.Error(c, http.StatusBadRequest, "剩余课时不足")
-		return
-	}
-
-	// 创建课时消耗记录
-	consumption := models.SessionConsumption{
-		CourseID:        attendance.CourseID,
-		AttendanceID:    &req.AttendanceID,
-		SessionsConsumed: req.SessionsConsumed,
-		SessionType:     req.SessionType,
-		Description:     req.Description,
-	}
-
-	if err := db.Create(&consumption).Error; err != nil {
+		if errors.Is(err, services.ErrInsufficientSessions) || errors.Is(err, services.ErrOccurrenceCancelled) {
+			utils.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.Error(c, http.StatusInternalServerError, "创建课时消耗记录失败")
 		return
 	}
@@ -110,6 +103,60 @@ func GetCourseConsumptions(c *gin.Context) {
 	utils.Success(c, "获取成功", consumptions)
 }
 
+// GetCourseConsumptionStats 获取课程课时消耗与出勤统计
+func GetCourseConsumptionStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("courseId"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	// 验证课程是否存在且属于当前用户
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	stats, err := services.GetConsumptionStats(db, &course)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取统计失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", stats)
+}
+
+// GetConsumption 获取单条课时消耗记录
+func GetConsumption(c *gin.Context) {
+	userID := c.GetUint("userID")
+	consumptionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的消耗记录ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var consumption models.SessionConsumption
+	if err := db.Where("id = ?", consumptionID).
+		Preload("Course").Preload("Attendance").First(&consumption).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "消耗记录不存在")
+		return
+	}
+
+	// 验证课程是否属于当前用户
+	if consumption.Course.UserID != userID {
+		utils.Error(c, http.StatusForbidden, "无权限操作此记录")
+		return
+	}
+
+	utils.Success(c, "获取成功", consumption)
+}
+
 // DeleteConsumption 删除课时消耗记录
 func DeleteConsumption(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 	"course-management-backend/database"
 	"course-management-backend/models"
+	"course-management-backend/services"
 	"course-management-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -52,17 +54,23 @@ func GetCourses(c *gin.Context) {
 		return
 	}
 
-	// 添加统计信息
+	// 添加统计信息（分池余额一次查询完成）
+	balances, err := services.GetCourseBalances(db, courses)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询课时余额失败")
+		return
+	}
+
 	var coursesWithStats []models.CourseWithStats
 	for _, course := range courses {
-		consumed, _ := course.GetConsumedSessions(db)
-		remaining, _ := course.GetRemainingSessions(db)
+		balance := balances[course.ID]
 		
 		coursesWithStats = append(coursesWithStats, models.CourseWithStats{
 			Course:           course,
 			TotalSessions:    int64(course.GetTotalSessions()),
-			ConsumedSessions: consumed,
-			RemainingSessions: remaining,
+			ConsumedSessions: balance.RegularConsumed + balance.BonusConsumed,
+			RemainingSessions: balance.TotalRemaining(),
+			Balance:          balance,
 		})
 	}
 
@@ -81,18 +89,16 @@ func GetCourses(c *gin.Context) {
 func GetTodayCourses(c *gin.Context) {
 	userID := c.GetUint("userID")
 	
-	today := time.Now().Format("2006-01-02")
-	weekday := int(time.Now().Weekday())
-	if weekday == 0 {
-		weekday = 7 // 周日转换为7
-	}
-
 	db := database.GetDB()
 
+	// "今天"按用户时区计算
+	dayStart, dayEnd := services.DayRange(time.Now().In(userLocation(c)))
+
+	// 异地排课的上课日期按排课时区计算，可能与用户的今天相差一天
 	var courses []models.Course
-	err := db.Where("user_id = ? AND is_active = ?", userID, true).
-		Preload("Schedules", "weekday = ? AND is_active = ?", weekday, true).
-		Preload("AttendanceRecords", "schedule_date = ?", today).
+	err := services.PreloadForOccurrences(db.Where("user_id = ? AND is_active = ?", userID, true)).
+		Preload("AttendanceRecords", "schedule_date >= ? AND schedule_date <= ?",
+			dayStart.AddDate(0, 0, -1).Format("2006-01-02"), dayEnd.Format("2006-01-02")).
 		Find(&courses).Error
 
 	if err != nil {
@@ -100,22 +106,41 @@ func GetTodayCourses(c *gin.Context) {
 		return
 	}
 
+	balances, err := services.GetCourseBalances(db, courses)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询课时余额失败")
+		return
+	}
+
+	// 按课程归并今日上课实例
+	todaySchedules := make(map[uint][]models.CourseSchedule)
+	todayDates := make(map[uint]map[string]bool)
+	var todayCourses []*models.Course
+	for _, occurrence := range services.ExpandOccurrences(courses, dayStart, dayEnd) {
+		if _, exists := todaySchedules[occurrence.Course.ID]; !exists {
+			todayCourses = append(todayCourses, occurrence.Course)
+			todayDates[occurrence.Course.ID] = make(map[string]bool)
+		}
+		todaySchedules[occurrence.Course.ID] = append(todaySchedules[occurrence.Course.ID], *occurrence.Schedule)
+		todayDates[occurrence.Course.ID][occurrence.Date] = true
+	}
+
 	// 添加出勤状态
 	var result []gin.H
-	for _, course := range courses {
+	for _, course := range todayCourses {
 		courseData := gin.H{
 			"id":            course.ID,
 			"name":          course.Name,
-			"schedules":     course.Schedules,
+			"schedules":     todaySchedules[course.ID],
 			"totalAmount":   course.TotalAmount,
-			"remainingSessions": course.BonusSessions + course.RegularSessions,
+			"remainingSessions": balances[course.ID].TotalRemaining(),
 		}
 
 		hasAttendance := false
 		attendanceStatus := "pending"
 		
 		for _, record := range course.AttendanceRecords {
-			if record.ScheduleDate == today {
+			if todayDates[course.ID][models.DateOnly(record.ScheduleDate)] {
 				hasAttendance = true
 				attendanceStatus = record.Status
 				break
@@ -145,10 +170,14 @@ func GetCourseById(c *gin.Context) {
 	var course models.Course
 	err = db.Where("id = ? AND user_id = ?", courseID, userID).
 		Preload("Schedules").
+		Preload("Exceptions").
 		Preload("AttendanceRecords", func(db *gorm.DB) *gorm.DB {
 			return db.Order("schedule_date DESC")
 		}).
 		Preload("Consumptions.Attendance").
+		Preload("Purchases", func(db *gorm.DB) *gorm.DB {
+			return db.Order("purchased_on DESC, id DESC")
+		}).
 		First(&course).Error
 
 	if err != nil {
@@ -156,8 +185,11 @@ func GetCourseById(c *gin.Context) {
 		return
 	}
 
-	consumed, _ := course.GetConsumedSessions(db)
-	remaining, _ := course.GetRemainingSessions(db)
+	balance, err := services.GetCourseBalance(db, &course)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询课时余额失败")
+		return
+	}
 
 	// 解析合同图片JSON数据
 	var contractImages []string
@@ -181,11 +213,15 @@ func GetCourseById(c *gin.Context) {
 		"createdAt":        course.CreatedAt,
 		"updatedAt":        course.UpdatedAt,
 		"schedules":        course.Schedules,
+		"exceptions":       course.Exceptions,
 		"attendanceRecords": course.AttendanceRecords,
 		"consumptions":     course.Consumptions,
+		"purchases":        course.Purchases,
+		"expiresOn":        course.ExpiresOn,
 		"totalSessions":    course.GetTotalSessions(),
-		"consumedSessions": consumed,
-		"remainingSessions": remaining,
+		"consumedSessions": balance.RegularConsumed + balance.BonusConsumed,
+		"remainingSessions": balance.TotalRemaining(),
+		"balance":          balance,
 	}
 
 	utils.Success(c, "获取成功", courseData)
@@ -202,25 +238,75 @@ func CreateCourse(c *gin.Context) {
 	}
 
 	// 验证必填字段
-	if req.Name == "" {
-		utils.Error(c, http.StatusBadRequest, "课程名称不能为空")
+	if err := validateCourseRequest(&req); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	
-	if req.RegularSessions <= 0 {
-		utils.Error(c, http.StatusBadRequest, "正式课时必须大于0")
+
+	db := database.GetDB()
+
+	// 开始事务
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	course, err := createCourseTx(tx, userID, req)
+	if err != nil {
+		tx.Rollback()
+		utils.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	db := database.GetDB()
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "创建课程失败")
+		return
+	}
 
+	// 查询创建后的完整课程
+	var createdCourse models.Course
+	db.Preload("Schedules").First(&createdCourse, course.ID)
+
+	utils.Success(c, "创建成功", createdCourse)
+}
+
+// validateCourseRequest 验证创建课程的必填字段
+func validateCourseRequest(req *models.CourseRequest) error {
+	if req.Name == "" {
+		return errors.New("课程名称不能为空")
+	}
+	if req.RegularSessions <= 0 {
+		return errors.New("正式课时必须大于0")
+	}
+	for _, exception := range req.Exceptions {
+		if _, err := time.Parse("2006-01-02", exception.Date); err != nil {
+			return errors.New("例外日期格式必须为YYYY-MM-DD")
+		}
+	}
+	return validateCourseExpiry(req)
+}
+
+// validateCourseExpiry 验证课包到期日（空字符串表示清除）
+func validateCourseExpiry(req *models.CourseRequest) error {
+	if req.ExpiresOn == nil || *req.ExpiresOn == "" {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", *req.ExpiresOn); err != nil {
+		return errors.New("到期日格式必须为YYYY-MM-DD")
+	}
+	return nil
+}
+
+// createCourseTx 在事务中创建课程、课程安排及例外日期
+func createCourseTx(tx *gorm.DB, userID uint, req models.CourseRequest) (*models.Course, error) {
 	// 处理合同图片（转换为JSON字符串存储）
 	var contractImagesJSON string
 	if len(req.ContractImages) > 0 {
 		imagesJSON, err := json.Marshal(req.ContractImages)
 		if err != nil {
-			utils.Error(c, http.StatusInternalServerError, "合同图片格式错误")
-			return
+			return nil, errors.New("合同图片格式错误")
 		}
 		contractImagesJSON = string(imagesJSON)
 	}
@@ -235,53 +321,50 @@ func CreateCourse(c *gin.Context) {
 		ContractImages:  contractImagesJSON,
 		Category:        req.Category,
 		Description:     req.Description,
+		MissedPolicy:    req.MissedPolicy,
 		IsActive:        true,
 	}
-
-	// 开始事务
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	if course.MissedPolicy == "" {
+		course.MissedPolicy = models.MissedPolicyNone
+	}
+	if req.ExpiresOn != nil && *req.ExpiresOn != "" {
+		course.ExpiresOn = req.ExpiresOn
+	}
+	course.LowSessionThreshold = req.LowSessionThreshold
 
 	if err := tx.Create(&course).Error; err != nil {
-		tx.Rollback()
-		utils.Error(c, http.StatusInternalServerError, "创建课程失败")
-		return
+		return nil, errors.New("创建课程失败")
+	}
+	if err := services.CreateInitialPurchase(tx, &course); err != nil {
+		return nil, errors.New("创建初始课包失败")
 	}
 
 	// 创建课程安排
-	if len(req.Schedules) > 0 {
-		for _, scheduleReq := range req.Schedules {
-			schedule := models.CourseSchedule{
-				CourseID:   course.ID,
-				Weekday:    scheduleReq.Weekday,
-				StartTime:  scheduleReq.StartTime,
-				EndTime:    scheduleReq.EndTime,
-				Location:   scheduleReq.Location,
-				Instructor: scheduleReq.Instructor,
-				IsActive:   true,
-			}
-			if err := tx.Create(&schedule).Error; err != nil {
-				tx.Rollback()
-				utils.Error(c, http.StatusInternalServerError, "创建课程安排失败: " + err.Error())
-				return
-			}
+	for _, scheduleReq := range req.Schedules {
+		schedule := newScheduleFromRequest(course.ID, scheduleReq)
+		if err := tx.Create(&schedule).Error; err != nil {
+			return nil, errors.New("创建课程安排失败: " + err.Error())
 		}
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		utils.Error(c, http.StatusInternalServerError, "创建课程失败")
-		return
+	// 创建例外日期（同一天只保留一条）
+	seen := make(map[string]bool)
+	for _, exceptionReq := range req.Exceptions {
+		if seen[exceptionReq.Date] {
+			continue
+		}
+		seen[exceptionReq.Date] = true
+		exception := models.ScheduleException{
+			CourseID: course.ID,
+			Date:     exceptionReq.Date,
+			Reason:   exceptionReq.Reason,
+		}
+		if err := tx.Create(&exception).Error; err != nil {
+			return nil, errors.New("创建例外日期失败: " + err.Error())
+		}
 	}
 
-	// 查询创建后的完整课程
-	var createdCourse models.Course
-	db.Preload("Schedules").First(&createdCourse, course.ID)
-
-	utils.Success(c, "创建成功", createdCourse)
+	return &course, nil
 }
 
 // UpdateCourse 更新课程
@@ -298,6 +381,10 @@ func UpdateCourse(c *gin.Context) {
 		utils.ValidationError(c, err.Error())
 		return
 	}
+	if err := validateCourseExpiry(&req); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// 调试日志：显示接收到的数据
 	fmt.Printf("更新课程 - 课程ID: %d, 课程名称: %s\n", courseID, req.Name)
@@ -331,11 +418,20 @@ func UpdateCourse(c *gin.Context) {
 
 	// 更新课程信息
 	course.Name = req.Name
-	course.TotalAmount = req.TotalAmount
-	course.RegularSessions = req.RegularSessions
-	course.BonusSessions = req.BonusSessions
 	course.Category = req.Category
 	course.Description = req.Description
+	if req.MissedPolicy != "" && req.MissedPolicy != course.MissedPolicy {
+		course.MissedPolicy = req.MissedPolicy
+		now := time.Now()
+		course.MissedPolicySince = &now
+	}
+	if course.ExpiresOn != nil {
+		expiresOn := models.DateOnly(*course.ExpiresOn)
+		course.ExpiresOn = &expiresOn
+	}
+	if req.LowSessionThreshold != nil {
+		course.LowSessionThreshold = req.LowSessionThreshold
+	}
 	
 	// 更新合同图片（总是更新，即使为空数组）
 	imagesJSON, err := json.Marshal(req.ContractImages)
@@ -352,6 +448,17 @@ func UpdateCourse(c *gin.Context) {
 		return
 	}
 
+	// 金额、课时和到期日由购买记录汇总，直接编辑时记为调整
+	if err := services.RecordCourseTotalsChange(tx, course.ID, req.TotalAmount, req.RegularSessions, req.BonusSessions, req.ExpiresOn); err != nil {
+		tx.Rollback()
+		if errors.Is(err, services.ErrPurchaseBelowConsumed) {
+			utils.Error(c, http.StatusConflict, err.Error())
+			return
+		}
+		utils.Error(c, http.StatusInternalServerError, "更新课程课时失败")
+		return
+	}
+
 	// 删除原有的课程安排（使用Unscoped强制删除，避免软删除）
 	fmt.Printf("开始删除课程安排 - 课程ID: %d\n", course.ID)
 	
@@ -376,15 +483,7 @@ func UpdateCourse(c *gin.Context) {
 	// 创建新的课程安排
 	if len(req.Schedules) > 0 {
 		for _, scheduleReq := range req.Schedules {
-			schedule := models.CourseSchedule{
-				CourseID:   course.ID,
-				Weekday:    scheduleReq.Weekday,
-				StartTime:  scheduleReq.StartTime,
-				EndTime:    scheduleReq.EndTime,
-				Location:   scheduleReq.Location,
-				Instructor: scheduleReq.Instructor,
-				IsActive:   true,
-			}
+			schedule := newScheduleFromRequest(course.ID, scheduleReq)
 			if err := tx.Create(&schedule).Error; err != nil {
 				tx.Rollback()
 				utils.Error(c, http.StatusInternalServerError, "创建课程安排失败: " + err.Error())
@@ -405,6 +504,38 @@ func UpdateCourse(c *gin.Context) {
 	utils.Success(c, "更新成功", updatedCourse)
 }
 
+// newScheduleFromRequest 根据请求构建课程安排
+func newScheduleFromRequest(courseID uint, req models.CourseScheduleRequest) models.CourseSchedule {
+	schedule := models.CourseSchedule{
+		CourseID:    courseID,
+		Weekday:     req.Weekday,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Location:    req.Location,
+		Instructor:  req.Instructor,
+		Recurrence:  req.Recurrence,
+		Interval:    req.Interval,
+		WeekOfMonth: req.WeekOfMonth,
+		Timezone:    req.Timezone,
+		IsActive:    true,
+	}
+	if schedule.Recurrence == "" {
+		schedule.Recurrence = models.RecurrenceWeekly
+	}
+	if schedule.Interval < 1 {
+		schedule.Interval = 1
+	}
+	if req.StartDate != "" {
+		startDate := req.StartDate
+		schedule.StartDate = &startDate
+	}
+	if req.EndDate != "" {
+		endDate := req.EndDate
+		schedule.EndDate = &endDate
+	}
+	return schedule
+}
+
 // DeleteCourse 删除课程
 func DeleteCourse(c *gin.Context) {
 	userID := c.GetUint("userID")
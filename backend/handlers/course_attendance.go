@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCourseAttendance 获取课程出勤历史（分页，可按状态和日期范围筛选）
+func GetCourseAttendance(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	query := db.Model(&models.AttendanceRecord{}).Where("course_id = ?", course.ID)
+	// status 可传多个，逗号分隔
+	if status := c.Query("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for _, item := range statuses {
+			if !models.IsValidAttendanceStatus(item) {
+				utils.Error(c, http.StatusBadRequest, "无效的出勤状态: "+item)
+				return
+			}
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+	for _, filter := range []struct {
+		param string
+		where string
+	}{
+		{"from", "schedule_date >= ?"},
+		{"to", "schedule_date <= ?"},
+	} {
+		value := c.Query(filter.param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			utils.Error(c, http.StatusBadRequest, "日期格式必须为YYYY-MM-DD")
+			return
+		}
+		query = query.Where(filter.where, value)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询出勤记录失败")
+		return
+	}
+
+	var records []models.AttendanceRecord
+	err = query.Order("schedule_date DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int((page - 1) * limit)).
+		Find(&records).Error
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询出勤记录失败")
+		return
+	}
+
+	// 批量查询每条出勤的消课数
+	consumed := make(map[uint]int64, len(records))
+	if len(records) > 0 {
+		recordIDs := make([]uint, 0, len(records))
+		for _, record := range records {
+			recordIDs = append(recordIDs, record.ID)
+		}
+		var rows []struct {
+			AttendanceID uint
+			Consumed     int64
+		}
+		err := db.Model(&models.SessionConsumption{}).
+			Select("attendance_id, COALESCE(SUM(sessions_consumed), 0) AS consumed").
+			Where("attendance_id IN ?", recordIDs).
+			Group("attendance_id").
+			Scan(&rows).Error
+		if err != nil {
+			utils.Error(c, http.StatusInternalServerError, "查询消课记录失败")
+			return
+		}
+		for _, row := range rows {
+			consumed[row.AttendanceID] = row.Consumed
+		}
+	}
+
+	items := make([]models.AttendanceHistoryItem, 0, len(records))
+	for _, record := range records {
+		items = append(items, models.AttendanceHistoryItem{
+			AttendanceRecord: record,
+			StatusText:       record.GetStatusText(),
+			SessionsConsumed: consumed[record.ID],
+		})
+	}
+
+	utils.SuccessWithPagination(c, "获取成功", items, utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	})
+}
+
+// GetCourseAttendanceStats 获取课程出勤统计
+func GetCourseAttendanceStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := services.PreloadForOccurrences(db.Where("id = ? AND user_id = ?", courseID, userID)).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	stats, err := services.GetAttendanceStats(db, &course, time.Now())
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取出勤统计失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", stats)
+}
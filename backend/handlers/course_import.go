@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxICSFileSize iCalendar文件大小上限
+const maxICSFileSize = 2 * 1024 * 1024
+
+// CourseImportRequest 批量导入课程请求
+type CourseImportRequest struct {
+	Courses []models.CourseRequest `json:"courses" binding:"required,min=1,dive"`
+}
+
+// ImportCoursesFromICS 解析上传的iCalendar文件并生成课程建议
+// 默认只预览（dryRun=true），dryRun=false 时直接按建议创建课程；无法确定课时数的课程需预览后通过 ImportCourses 确认
+func ImportCoursesFromICS(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "请选择要导入的日历文件")
+		return
+	}
+	if file.Size > maxICSFileSize {
+		utils.Error(c, http.StatusBadRequest, "日历文件不能超过2MB")
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "读取日历文件失败")
+		return
+	}
+	defer src.Close()
+
+	events, err := services.ParseICalendar(src, userLocation(c))
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	proposals := services.ProposeCourseImport(events)
+
+	if c.DefaultQuery("dryRun", "true") != "false" {
+		utils.Success(c, "解析成功", gin.H{
+			"dryRun":    true,
+			"proposals": proposals,
+		})
+		return
+	}
+
+	requests := make([]models.CourseRequest, len(proposals))
+	for i, proposal := range proposals {
+		// 无结束日期的重复事件无法确定课时数，需预览后填写课时再确认导入
+		if proposal.Course.RegularSessions <= 0 {
+			utils.Error(c, http.StatusBadRequest, fmt.Sprintf("课程（%s）无法从日历确定正式课时，请预览后填写课时并通过 POST /api/courses/import 确认导入", proposal.Course.Name))
+			return
+		}
+		requests[i] = proposal.Course
+	}
+	courses, err := importCourses(userID, requests)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Success(c, "导入成功", gin.H{
+		"dryRun":    false,
+		"proposals": proposals,
+		"courses":   courses,
+	})
+}
+
+// ImportCourses 批量创建课程（通常为预览后确认、修改过的导入建议）
+func ImportCourses(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CourseImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	courses, err := importCourses(userID, req.Courses)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Success(c, "导入成功", courses)
+}
+
+// importCourses 在同一事务中创建多门课程，任一失败则全部回滚
+func importCourses(userID uint, requests []models.CourseRequest) ([]models.Course, error) {
+	for i := range requests {
+		if err := validateCourseRequest(&requests[i]); err != nil {
+			return nil, fmt.Errorf("第%d门课程（%s）: %v", i+1, requests[i].Name, err)
+		}
+	}
+
+	db := database.GetDB()
+
+	ids := make([]uint, 0, len(requests))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i, req := range requests {
+			course, err := createCourseTx(tx, userID, req)
+			if err != nil {
+				return fmt.Errorf("第%d门课程（%s）: %v", i+1, req.Name, err)
+			}
+			ids = append(ids, course.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var courses []models.Course
+	db.Preload("Schedules").Preload("Exceptions").Where("id IN ?", ids).Order("id ASC").Find(&courses)
+	return courses, nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestImportCoursesFromICSRequiresSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:test-1",
+		"SUMMARY:钢琴课",
+		"DTSTART:20260105T180000",
+		"DTEND:20260105T190000",
+		"RRULE:FREQ=WEEKLY",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "courses.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(ics))
+	form.Close()
+
+	// 无结束日期的重复事件无法确定课时数，在访问数据库之前拒绝
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/courses/import/ics?dryRun=false", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	c.Set("userID", uint(1))
+	ImportCoursesFromICS(c)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(recorder.Body.String(), "/api/courses/import") {
+		t.Errorf("body = %s, want a pointer to the confirm endpoint", recorder.Body.String())
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDashboard 首页汇总：今日课程、即将上课、课时不足的课程、剩余预付价值和本月出勤率
+func GetDashboard(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	upcoming, _ := strconv.Atoi(c.DefaultQuery("upcoming", "5"))
+	if upcoming < 1 || upcoming > 50 {
+		upcoming = 5
+	}
+	// 未指定阈值时按各课程的课时不足阈值
+	var threshold *int64
+	if value := c.Query("threshold"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			utils.Error(c, http.StatusBadRequest, "threshold必须为非负整数")
+			return
+		}
+		threshold = &parsed
+	}
+
+	dashboard, err := services.BuildDashboard(database.GetDB(), userID, time.Now(), userLocation(c), upcoming, threshold)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取首页数据失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", dashboard)
+}
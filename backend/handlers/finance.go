@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"course-management-backend/config"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCourseValuation 获取课程价值与退费估算
+func GetCourseValuation(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	rule := c.DefaultQuery("rule", config.GetEnv("REFUND_DEFAULT_RULE", services.RefundRuleRegularFirst))
+	if !services.IsValidRefundRule(rule) {
+		utils.Error(c, http.StatusBadRequest, "不支持的退费规则")
+		return
+	}
+
+	feeRate, err := strconv.ParseFloat(c.DefaultQuery("feeRate", "0"), 64)
+	if err != nil || feeRate < 0 || feeRate > 1 {
+		utils.Error(c, http.StatusBadRequest, "手续费比例必须在0-1之间")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	valuation, err := services.GetCourseValuation(db, &course, rule, feeRate)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "计算课程价值失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", valuation)
+}
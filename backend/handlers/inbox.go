@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInboxMessages 获取站内通知列表（unread=true 只看未读）
+func GetInboxMessages(c *gin.Context) {
+	userID := c.GetUint("userID")
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	db := database.GetDB()
+
+	query := db.Model(&models.InboxMessage{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var messages []models.InboxMessage
+	err := query.Order("created_at DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int((page - 1) * limit)).
+		Find(&messages).Error
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取站内通知失败")
+		return
+	}
+
+	responses := make([]models.InboxMessageResponse, 0, len(messages))
+	for i := range messages {
+		responses = append(responses, messages[i].ToResponse())
+	}
+
+	utils.SuccessWithPagination(c, "获取成功", responses, utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	})
+}
+
+// GetInboxUnreadCount 获取未读站内通知数量
+func GetInboxUnreadCount(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	count, err := services.GetUnreadInboxCount(database.GetDB(), userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取未读数量失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", gin.H{"unread": count})
+}
+
+// MarkInboxMessageRead 标记站内通知为已读
+func MarkInboxMessageRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	messageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的通知ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var message models.InboxMessage
+	if err := db.Where("id = ? AND user_id = ?", messageID, userID).First(&message).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "通知不存在")
+		return
+	}
+
+	if message.ReadAt == nil {
+		now := time.Now()
+		if err := db.Model(&message).Update("read_at", &now).Error; err != nil {
+			utils.Error(c, http.StatusInternalServerError, "标记已读失败")
+			return
+		}
+	}
+
+	utils.Success(c, "已标记为已读", message.ToResponse())
+}
+
+// MarkAllInboxMessagesRead 将所有站内通知标记为已读
+func MarkAllInboxMessagesRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	result := database.GetDB().Model(&models.InboxMessage{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		utils.Error(c, http.StatusInternalServerError, "标记已读失败")
+		return
+	}
+
+	utils.Success(c, "已全部标记为已读", gin.H{"updated": result.RowsAffected})
+}
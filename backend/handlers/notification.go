@@ -2,50 +2,227 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"course-management-backend/database"
 	"course-management-backend/models"
 	"course-management-backend/services"
 	"course-management-backend/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // SubscribeNotifications 订阅推送通知
 func SubscribeNotifications(c *gin.Context) {
 	userID := c.GetUint("userID")
 	
-	var subscription map[string]interface{}
-	if err := c.ShouldBindJSON(&subscription); err != nil {
+	var req models.PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationError(c, err.Error())
 		return
 	}
 
-	// 验证订阅信息
-	endpoint, ok := subscription["endpoint"].(string)
-	if !ok || endpoint == "" {
-		utils.Error(c, http.StatusBadRequest, "无效的订阅信息")
+	subscription, err := services.SaveNotificationSubscription(userID, req, c.Request.UserAgent())
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "保存订阅失败")
 		return
 	}
 
-	// 保存订阅信息（这里简化处理，实际应该存储到数据库）
-	services.SaveNotificationSubscription(userID, subscription)
-
-	utils.Success(c, "订阅成功", nil)
+	utils.Success(c, "订阅成功", subscription)
 }
 
 // UnsubscribeNotifications 取消推送订阅
 func UnsubscribeNotifications(c *gin.Context) {
 	userID := c.GetUint("userID")
 	
-	var subscription map[string]interface{}
-	c.ShouldBindJSON(&subscription)
+	var req models.PushUnsubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
 
-	// 移除订阅信息
-	services.RemoveNotificationSubscription(userID, subscription)
+	if err := services.RemoveNotificationSubscription(userID, req.Endpoint); err != nil {
+		utils.Error(c, http.StatusInternalServerError, "取消订阅失败")
+		return
+	}
 
 	utils.Success(c, "取消订阅成功", nil)
 }
 
+// GetNotificationSubscriptions 获取当前用户的推送订阅（各设备）
+func GetNotificationSubscriptions(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	subscriptions, err := services.GetSubscriptions(userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取订阅失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", subscriptions)
+}
+
+// GetVAPIDPublicKey 获取推送订阅使用的VAPID公钥（applicationServerKey）
+func GetVAPIDPublicKey(c *gin.Context) {
+	publicKey, err := services.GetVAPIDPublicKey()
+	if err != nil {
+		utils.Error(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	utils.Success(c, "获取成功", gin.H{"publicKey": publicKey})
+}
+
+// GetNotificationPreferences 获取当前用户的通知偏好
+func GetNotificationPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	preference, err := services.GetNotificationPreference(userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知偏好失败")
+		return
+	}
+
+	mutedCourseIDs, err := services.GetMutedCourseIDs(database.GetDB(), userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知偏好失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", services.BuildPreferenceResponse(preference, mutedCourseIDs))
+}
+
+// UpdateNotificationPreferences 更新当前用户的通知偏好
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req models.NotificationPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+
+	preference, err := services.GetNotificationPreference(userID)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知偏好失败")
+		return
+	}
+
+	if req.EmailEnabled != nil {
+		if *req.EmailEnabled {
+			var user models.User
+			if err := db.First(&user, userID).Error; err != nil || user.Email == "" {
+				utils.Error(c, http.StatusBadRequest, "请先填写邮箱后再开启邮件提醒")
+				return
+			}
+		}
+		preference.EmailEnabled = *req.EmailEnabled
+	}
+	if req.LeadMinutes != nil {
+		preference.LeadMinutes = services.FormatLeadMinutes(req.LeadMinutes)
+	}
+	if req.QuietStart != nil {
+		preference.QuietStart = *req.QuietStart
+	}
+	if req.QuietEnd != nil {
+		preference.QuietEnd = *req.QuietEnd
+	}
+	if (preference.QuietStart == "") != (preference.QuietEnd == "") {
+		utils.Error(c, http.StatusBadRequest, "免打扰开始和结束时间需同时设置")
+		return
+	}
+	for _, clock := range []string{preference.QuietStart, preference.QuietEnd} {
+		if clock == "" {
+			continue
+		}
+		if err := services.ValidateClock(clock); err != nil {
+			utils.Error(c, http.StatusBadRequest, "免打扰"+err.Error())
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(preference).Error; err != nil {
+			return err
+		}
+		if req.MutedCourseIDs != nil {
+			return services.SetMutedCourses(tx, userID, req.MutedCourseIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "更新通知偏好失败: "+err.Error())
+		return
+	}
+
+	mutedCourseIDs, _ := services.GetMutedCourseIDs(db, userID)
+	utils.Success(c, "更新成功", services.BuildPreferenceResponse(preference, mutedCourseIDs))
+}
+
+// GetNotificationHistory 获取通知发送记录（可按状态、渠道、类型筛选）
+func GetNotificationHistory(c *gin.Context) {
+	userID := c.GetUint("userID")
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.GetDB().Model(&models.NotificationOutbox{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.NotificationOutbox
+	err := query.Order("created_at DESC, id DESC").
+		Limit(int(limit)).
+		Offset(int((page - 1) * limit)).
+		Find(&entries).Error
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知记录失败")
+		return
+	}
+
+	history := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		history = append(history, gin.H{
+			"id":            entry.ID,
+			"type":          entry.Type,
+			"channel":       entry.Channel,
+			"title":         entry.Title,
+			"body":          entry.Body,
+			"status":        entry.Status,
+			"statusText":    entry.GetStatusText(),
+			"attempts":      entry.Attempts,
+			"nextAttemptAt": entry.NextAttemptAt,
+			"lastError":     entry.LastError,
+			"deliveredAt":   entry.DeliveredAt,
+			"createdAt":     entry.CreatedAt,
+		})
+	}
+
+	utils.SuccessWithPagination(c, "获取成功", history, utils.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + limit - 1) / limit,
+	})
+}
+
 // TestNotification 发送测试通知
 func TestNotification(c *gin.Context) {
 	userID := c.GetUint("userID")
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationChannels 获取当前用户的外部通知渠道
+func GetNotificationChannels(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var channels []models.NotificationChannel
+	if err := database.GetDB().Where("user_id = ?", userID).Order("id ASC").Find(&channels).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "获取通知渠道失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", channels)
+}
+
+// CreateNotificationChannel 添加外部通知渠道，签名密钥只在创建时返回
+func CreateNotificationChannel(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req models.NotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+	if err := services.ValidateWebhookURL(req.URL); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		utils.Error(c, http.StatusInternalServerError, "生成签名密钥失败")
+		return
+	}
+
+	channel := models.NotificationChannel{
+		UserID:   userID,
+		Type:     models.ChannelTypeWebhook,
+		Name:     req.Name,
+		URL:      req.URL,
+		Secret:   secret,
+		IsActive: true,
+	}
+	if err := database.GetDB().Create(&channel).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "添加通知渠道失败")
+		return
+	}
+
+	utils.Success(c, "添加成功，请妥善保存签名密钥", gin.H{
+		"channel": channel,
+		"secret":  secret,
+	})
+}
+
+// UpdateNotificationChannel 更新外部通知渠道
+func UpdateNotificationChannel(c *gin.Context) {
+	channel, ok := findNotificationChannel(c)
+	if !ok {
+		return
+	}
+
+	var req models.NotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+	if err := services.ValidateWebhookURL(req.URL); err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	channel.Name = req.Name
+	channel.URL = req.URL
+	if req.IsActive != nil {
+		channel.IsActive = *req.IsActive
+	}
+	if err := database.GetDB().Save(channel).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "更新通知渠道失败")
+		return
+	}
+
+	utils.Success(c, "更新成功", channel)
+}
+
+// DeleteNotificationChannel 删除外部通知渠道
+func DeleteNotificationChannel(c *gin.Context) {
+	channel, ok := findNotificationChannel(c)
+	if !ok {
+		return
+	}
+
+	if err := database.GetDB().Delete(channel).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "删除通知渠道失败")
+		return
+	}
+
+	utils.Success(c, "删除成功", nil)
+}
+
+// TestNotificationChannel 向指定渠道发送测试消息
+func TestNotificationChannel(c *gin.Context) {
+	channel, ok := findNotificationChannel(c)
+	if !ok {
+		return
+	}
+
+	if err := services.SendTestWebhook(channel); err != nil {
+		utils.Error(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	utils.Success(c, "测试消息已发送", nil)
+}
+
+// findNotificationChannel 查找当前用户的通知渠道，找不到时直接写入错误响应
+func findNotificationChannel(c *gin.Context) (*models.NotificationChannel, bool) {
+	userID := c.GetUint("userID")
+	channelID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的渠道ID")
+		return nil, false
+	}
+
+	var channel models.NotificationChannel
+	if err := database.GetDB().Where("id = ? AND user_id = ?", channelID, userID).First(&channel).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "通知渠道不存在")
+		return nil, false
+	}
+	return &channel, true
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCoursePurchases 获取课程的购买记录（初始课包、续费、加购和调整）
+func GetCoursePurchases(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var purchases []models.CoursePurchase
+	if err := db.Where("course_id = ?", course.ID).Order("purchased_on DESC, id DESC").Find(&purchases).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询购买记录失败")
+		return
+	}
+
+	items := make([]models.CoursePurchaseResponse, 0, len(purchases))
+	for _, purchase := range purchases {
+		items = append(items, purchase.ToResponse())
+	}
+
+	utils.Success(c, "获取成功", items)
+}
+
+// CreateCoursePurchase 续费或加购课时
+func CreateCoursePurchase(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	var req models.CoursePurchaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var purchase *models.CoursePurchase
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		purchase, err = services.CreateCoursePurchase(tx, course.ID, req)
+		return err
+	})
+	if err != nil {
+		respondPurchaseError(c, err)
+		return
+	}
+
+	utils.Success(c, purchase.GetKindText()+"成功", purchase.ToResponse())
+}
+
+// UpdateCoursePurchase 修改购买记录
+func UpdateCoursePurchase(c *gin.Context) {
+	purchase, ok := findCoursePurchase(c)
+	if !ok {
+		return
+	}
+
+	var req models.CoursePurchaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return services.UpdateCoursePurchase(tx, purchase, req)
+	})
+	if err != nil {
+		respondPurchaseError(c, err)
+		return
+	}
+
+	utils.Success(c, "更新成功", purchase.ToResponse())
+}
+
+// DeleteCoursePurchase 删除购买记录
+func DeleteCoursePurchase(c *gin.Context) {
+	purchase, ok := findCoursePurchase(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		return services.DeleteCoursePurchase(tx, purchase)
+	})
+	if err != nil {
+		respondPurchaseError(c, err)
+		return
+	}
+
+	utils.Success(c, "删除成功", nil)
+}
+
+// findCoursePurchase 查找当前用户课程下的购买记录，未找到时直接返回错误响应
+func findCoursePurchase(c *gin.Context) (*models.CoursePurchase, bool) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return nil, false
+	}
+	purchaseID, err := strconv.ParseUint(c.Param("purchaseId"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的购买记录ID")
+		return nil, false
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return nil, false
+	}
+
+	var purchase models.CoursePurchase
+	if err := db.Where("id = ? AND course_id = ?", purchaseID, course.ID).First(&purchase).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "购买记录不存在")
+		return nil, false
+	}
+	return &purchase, true
+}
+
+// respondPurchaseError 请求校验失败返回400，修改或删除后课时不足以覆盖已消耗课时返回409，其余返回500
+func respondPurchaseError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPurchase):
+		utils.Error(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrPurchaseBelowConsumed):
+		utils.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+	default:
+		utils.Error(c, http.StatusInternalServerError, "保存购买记录失败")
+	}
+}
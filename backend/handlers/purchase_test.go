@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"course-management-backend/models"
+	"course-management-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondPurchaseError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"validation", fmt.Errorf("%w: 到期日不能早于购买日期", services.ErrInvalidPurchase), http.StatusBadRequest},
+		{"below consumed", services.ErrPurchaseBelowConsumed, http.StatusConflict},
+		{"database", errors.New("Error 1213: Deadlock found"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			respondPurchaseError(c, tt.err)
+			if recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCoursePurchaseResponseReceiptImages(t *testing.T) {
+	tests := []struct {
+		stored string
+		want   string
+	}{
+		{`["/uploads/a.jpg","/uploads/b.jpg"]`, `["/uploads/a.jpg","/uploads/b.jpg"]`},
+		{"", `[]`},
+	}
+
+	for _, tt := range tests {
+		purchase := models.CoursePurchase{Kind: models.PurchaseRenewal, ReceiptImages: tt.stored}
+		body, err := json.Marshal(purchase.ToResponse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			ReceiptImages json.RawMessage `json:"receiptImages"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if string(response.ReceiptImages) != tt.want {
+			t.Errorf("receiptImages = %s, want %s", response.ReceiptImages, tt.want)
+		}
+	}
+}
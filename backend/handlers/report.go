@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSpendingReport 按课程类别和月份汇总花费（购买日期）和消耗价值（上课日期）
+// from/to 默认最近12个月（含本月），format=csv 时返回CSV文件
+func GetSpendingReport(c *gin.Context) {
+	loc := userLocation(c)
+	now := time.Now().In(loc)
+	from := c.DefaultQuery("from", time.Date(now.Year(), now.Month()-11, 1, 0, 0, 0, 0, loc).Format("2006-01-02"))
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		utils.Error(c, http.StatusBadRequest, "format只支持json或csv")
+		return
+	}
+
+	report, err := services.BuildSpendingReport(database.GetDB(), c.GetUint("userID"), from, to, loc)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if format == "json" {
+		utils.Success(c, "获取成功", report)
+		return
+	}
+
+	// 带BOM便于Excel识别UTF-8中文
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"月份", "类别", "花费", "购买次数", "消耗价值", "消耗课时"})
+	for _, row := range report.Rows {
+		writer.Write([]string{
+			row.Month,
+			csvCell(row.Category),
+			strconv.FormatFloat(row.Spend, 'f', 2, 64),
+			strconv.FormatInt(row.Purchases, 10),
+			strconv.FormatFloat(row.ConsumedValue, 'f', 2, 64),
+			strconv.FormatInt(row.SessionsConsumed, 10),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		utils.Error(c, http.StatusInternalServerError, "生成CSV失败")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="spending-%s-%s.csv"`, report.From, report.To))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// csvCell 以=、+、-、@、制表符或回车开头的文本前加单引号，避免在Excel中被当作公式
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
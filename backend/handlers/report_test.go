@@ -0,0 +1,25 @@
+package handlers
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"钢琴", "钢琴"},
+		{"", ""},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
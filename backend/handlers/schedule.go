@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+	"course-management-backend/services"
+	"course-management-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCourseExceptions 获取课程例外日期列表
+func GetCourseExceptions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var exceptions []models.ScheduleException
+	if err := db.Where("course_id = ?", course.ID).Order("date ASC").Find(&exceptions).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询例外日期失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", exceptions)
+}
+
+// CreateCourseException 添加课程例外日期（当天停课）
+func CreateCourseException(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	var req models.ScheduleExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		utils.Error(c, http.StatusBadRequest, "日期格式必须为YYYY-MM-DD")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	// 同一天只保留一条例外记录
+	var existing models.ScheduleException
+	if err := db.Where("course_id = ? AND date = ?", course.ID, req.Date).First(&existing).Error; err == nil {
+		utils.Error(c, http.StatusBadRequest, "该日期已设置为例外日期")
+		return
+	}
+
+	exception := models.ScheduleException{
+		CourseID: course.ID,
+		Date:     req.Date,
+		Reason:   req.Reason,
+	}
+	if err := db.Create(&exception).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "添加例外日期失败")
+		return
+	}
+
+	utils.Success(c, "添加成功", exception)
+}
+
+// DeleteCourseException 删除课程例外日期
+func DeleteCourseException(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+	exceptionID, err := strconv.ParseUint(c.Param("exceptionId"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的例外日期ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	result := db.Where("id = ? AND course_id = ?", exceptionID, course.ID).Delete(&models.ScheduleException{})
+	if result.Error != nil {
+		utils.Error(c, http.StatusInternalServerError, "删除例外日期失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.Error(c, http.StatusNotFound, "例外日期不存在")
+		return
+	}
+
+	utils.Success(c, "删除成功", nil)
+}
+
+// GetCourseOverrides 获取课程的调课/取消记录
+func GetCourseOverrides(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var overrides []models.OccurrenceOverride
+	if err := db.Where("course_id = ?", course.ID).Order("original_date DESC").Find(&overrides).Error; err != nil {
+		utils.Error(c, http.StatusInternalServerError, "查询调课记录失败")
+		return
+	}
+
+	utils.Success(c, "获取成功", overrides)
+}
+
+// CreateCourseOverride 单次调课或机构取消
+func CreateCourseOverride(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+
+	var req models.OccurrenceOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationError(c, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := services.PreloadForOccurrences(db.Where("id = ? AND user_id = ?", courseID, userID)).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var override *models.OccurrenceOverride
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		override, err = services.CreateOccurrenceOverride(tx, &course, req)
+		return err
+	})
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Success(c, override.GetActionText()+"成功", override)
+}
+
+// DeleteCourseOverride 撤销调课/取消
+func DeleteCourseOverride(c *gin.Context) {
+	userID := c.GetUint("userID")
+	courseID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的课程ID")
+		return
+	}
+	overrideID, err := strconv.ParseUint(c.Param("overrideId"), 10, 32)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, "无效的调课记录ID")
+		return
+	}
+
+	db := database.GetDB()
+
+	var course models.Course
+	if err := db.Where("id = ? AND user_id = ?", courseID, userID).First(&course).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "课程不存在")
+		return
+	}
+
+	var override models.OccurrenceOverride
+	if err := db.Where("id = ? AND course_id = ?", overrideID, course.ID).First(&override).Error; err != nil {
+		utils.Error(c, http.StatusNotFound, "调课记录不存在")
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return services.DeleteOccurrenceOverride(tx, &override)
+	}); err != nil {
+		utils.Error(c, http.StatusInternalServerError, "撤销调课失败")
+		return
+	}
+
+	utils.Success(c, "撤销成功", nil)
+}
@@ -61,4 +61,20 @@ func AuthRequired() gin.HandlerFunc {
 		c.Set("userID", user.ID)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// AdminRequired 管理员权限中间件，需在 AuthRequired 之后使用
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get("user")
+		if !ok || !user.(*models.User).IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "需要管理员权限",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// 课程提醒类型
+const (
+	CourseAlertLowBalance = "low_balance" // 剩余课时低于阈值
+	CourseAlertExpiring   = "expiring"    // 课包即将到期
+)
+
+// CourseAlert 已发送的课程提醒，同一课程同一条件只提醒一次
+// ConditionKey 描述触发条件（课时不足为"课时总数/阈值"，到期为到期日），课时充值、修改阈值或到期日后条件变化会再次提醒
+type CourseAlert struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CourseID     uint      `json:"courseId" gorm:"not null;uniqueIndex:idx_course_alert_condition"`
+	UserID       uint      `json:"userId" gorm:"not null;index"`
+	Kind         string    `json:"kind" gorm:"not null;size:20;uniqueIndex:idx_course_alert_condition"`
+	ConditionKey string    `json:"conditionKey" gorm:"not null;size:64;uniqueIndex:idx_course_alert_condition"`
+	Message      string    `json:"message" gorm:"size:255"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -8,11 +8,12 @@ import (
 // AttendanceRecord 出勤记录模型
 type AttendanceRecord struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
-	CourseID     uint      `json:"courseId" gorm:"not null;index"`
-	ScheduleDate string    `json:"scheduleDate" gorm:"not null;type:date;index"`
-	Status       string    `json:"status" gorm:"not null;default:pending;type:enum('pending','attend','absent')"`
+	CourseID     uint      `json:"courseId" gorm:"not null;index;uniqueIndex:idx_attendance_course_date"`
+	ScheduleDate string    `json:"scheduleDate" gorm:"not null;type:date;index;uniqueIndex:idx_attendance_course_date"` // 每次课只有一条出勤记录
+	Status       string    `json:"status" gorm:"not null;default:pending;type:enum('pending','attend','late','absent','no_show','cancelled_by_provider','makeup')"`
 	CheckInTime  *time.Time `json:"checkInTime"`
 	Notes        string    `json:"notes" gorm:"type:text"`
+	MakeupForID  *uint     `json:"makeupForId" gorm:"index"` // 补课对应的原缺课记录
 	ReminderSent bool      `json:"reminderSent" gorm:"default:false"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
@@ -21,27 +22,68 @@ type AttendanceRecord struct {
 	// 关联
 	Course Course `json:"-" gorm:"foreignKey:CourseID"`
 	Consumptions []SessionConsumption `json:"-" gorm:"foreignKey:AttendanceID"`
+	MakeupFor    *AttendanceRecord    `json:"-" gorm:"foreignKey:MakeupForID"`
+}
+
+// 出勤状态
+const (
+	AttendancePending             = "pending"               // 待上课，不消课
+	AttendanceAttend              = "attend"                // 已上课，消课
+	AttendanceLate                = "late"                  // 迟到，按上课消课
+	AttendanceAbsent              = "absent"                // 请假，不消课，可补课
+	AttendanceNoShow              = "no_show"               // 缺席（未请假），可按机构规定消课，可补课
+	AttendanceCancelledByProvider = "cancelled_by_provider" // 机构取消，不消课，已消耗的退回，可补课
+	AttendanceMakeup              = "makeup"                // 补课，消课；原缺课已消课时不再消课
+)
+
+// IsValidAttendanceStatus 检查出勤状态是否有效
+func IsValidAttendanceStatus(status string) bool {
+	switch status {
+	case AttendancePending, AttendanceAttend, AttendanceLate, AttendanceAbsent,
+		AttendanceNoShow, AttendanceCancelledByProvider, AttendanceMakeup:
+		return true
+	}
+	return false
+}
+
+// IsAttended 是否到课（上课、迟到、补课）
+func IsAttended(status string) bool {
+	return status == AttendanceAttend || status == AttendanceLate || status == AttendanceMakeup
+}
+
+// IsMissed 是否缺课（请假、缺席、机构取消），缺课可以安排补课
+func IsMissed(status string) bool {
+	return status == AttendanceAbsent || status == AttendanceNoShow || status == AttendanceCancelledByProvider
+}
+
+// CanConsumeSession 该状态是否允许消耗课时（到课，或机构对缺席扣课时）
+func CanConsumeSession(status string) bool {
+	return IsAttended(status) || status == AttendanceNoShow
 }
 
 // CheckIn 签到
 func (ar *AttendanceRecord) CheckIn() {
-	ar.Status = "attend"
+	ar.Status = AttendanceAttend
 	now := time.Now()
 	ar.CheckInTime = &now
 }
 
 // TakeLeave 请假
 func (ar *AttendanceRecord) TakeLeave(notes string) {
-	ar.Status = "absent"
+	ar.Status = AttendanceAbsent
 	ar.Notes = notes
 }
 
 // GetStatusText 获取状态描述
 func (ar *AttendanceRecord) GetStatusText() string {
 	statusMap := map[string]string{
-		"pending": "待上课",
-		"attend":  "已上课",
-		"absent":  "请假",
+		AttendancePending:             "待上课",
+		AttendanceAttend:              "已上课",
+		AttendanceLate:                "迟到",
+		AttendanceAbsent:              "请假",
+		AttendanceNoShow:              "缺席",
+		AttendanceCancelledByProvider: "机构取消",
+		AttendanceMakeup:              "补课",
 	}
 	if text, exists := statusMap[ar.Status]; exists {
 		return text
@@ -51,12 +93,62 @@ func (ar *AttendanceRecord) GetStatusText() string {
 
 // AttendanceRequest 出勤请求
 type AttendanceRequest struct {
-	Status string `json:"status" binding:"required,oneof=attend absent"`
+	Status string `json:"status" binding:"required,oneof=attend late absent no_show cancelled_by_provider makeup"`
 	Notes  string `json:"notes"`
+	// MakeupForID 状态为makeup时必填，对应同一课程中请假/缺席/机构取消的出勤记录
+	MakeupForID *uint `json:"makeupForId"`
+	// ConsumeSession 为true且状态允许消课时（attend/late/makeup/no_show），在同一事务中自动消课（先正式课时，后赠送课时）
+	ConsumeSession bool `json:"consumeSession"`
 }
 
 // AttendanceResponse 出勤响应
 type AttendanceResponse struct {
 	AttendanceRecord
 	CourseName string `json:"courseName"`
-}
\ No newline at end of file
+}
+
+// AttendanceHistoryItem 课程出勤历史中的一条记录
+type AttendanceHistoryItem struct {
+	AttendanceRecord
+	StatusText       string `json:"statusText"`
+	SessionsConsumed int64  `json:"sessionsConsumed"`
+}
+
+// MonthlyAttendance 按月汇总的出勤
+type MonthlyAttendance struct {
+	Month            string `json:"month"` // YYYY-MM
+	Attended         int64  `json:"attended"`
+	Absences         int64  `json:"absences"`
+	SessionsConsumed int64  `json:"sessionsConsumed"`
+}
+
+// AttendanceStats 课程出勤统计
+type AttendanceStats struct {
+	ConsumptionStats
+	AbsenceCount    int64               `json:"absenceCount"`  // 请假 + 缺席
+	LongestStreak   int                 `json:"longestStreak"` // 最长连续到课次数（机构取消不中断）
+	CurrentStreak   int                 `json:"currentStreak"`
+	Monthly         []MonthlyAttendance `json:"monthly"`
+	// ProjectedExhaustionDate 按排课（无排课时按近90天消课速度）预计课时用完的日期，无法预计时为null
+	ProjectedExhaustionDate *string `json:"projectedExhaustionDate"`
+	ProjectionMethod        string  `json:"projectionMethod"` // schedule / average / exhausted / none
+}
+// AttendanceActionToken 通知中快捷出勤操作的token记录（token本身是签名的，这里只记录ID以保证一次性使用）
+type AttendanceActionToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	TokenID      string     `json:"-" gorm:"not null;uniqueIndex;size:64"`
+	UserID       uint       `json:"userId" gorm:"not null;index"`
+	CourseID     uint       `json:"courseId" gorm:"not null;index"`
+	ScheduleDate string     `json:"scheduleDate" gorm:"not null;type:date"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	UsedAt       *time.Time `json:"usedAt"`
+	UsedAction   string     `json:"usedAction" gorm:"size:20"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// AttendanceActionRequest 通过通知快捷操作更新出勤
+type AttendanceActionRequest struct {
+	Token  string `json:"token" binding:"required"`
+	Action string `json:"action" binding:"required,oneof=attend absent"`
+	Notes  string `json:"notes"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeedToken 日历订阅token（与登录JWT分离，长期有效，可撤销）
+type CalendarFeedToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"userId" gorm:"not null;index"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex;size:64"` // 只保存token的SHA-256摘要
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+
+	// 关联
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IsActive 是否有效
+func (t *CalendarFeedToken) IsActive() bool {
+	return t.RevokedAt == nil
+}
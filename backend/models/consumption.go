@@ -39,5 +39,11 @@ type ConsumptionStats struct {
 	TotalSessions     int64 `json:"totalSessions"`
 	ConsumedSessions  int64 `json:"consumedSessions"`
 	RemainingSessions int64 `json:"remainingSessions"`
-	AttendanceRate    int   `json:"attendanceRate"`
+	AttendanceRate    int   `json:"attendanceRate"` // 到课（上课、迟到、补课）占已结束课程（不含机构取消）的百分比
+	// 各出勤状态的次数
+	StatusCounts      map[string]int64 `json:"statusCounts"`
+	// 按状态消耗的课时数（未关联出勤记录的手动消课计入manual）
+	ConsumedByStatus  map[string]int64 `json:"consumedByStatus"`
+	// 尚未安排补课的缺课次数（请假、缺席、机构取消）
+	PendingMakeups    int64 `json:"pendingMakeups"`
 }
\ No newline at end of file
@@ -10,13 +10,17 @@ type Course struct {
 	ID               uint             `json:"id" gorm:"primaryKey"`
 	UserID           uint             `json:"userId" gorm:"not null;index"`
 	Name             string           `json:"name" gorm:"not null;size:100"`
-	TotalAmount      float64          `json:"totalAmount" gorm:"type:decimal(10,2)"`
+	TotalAmount      float64          `json:"totalAmount" gorm:"type:decimal(10,2)"` // 金额、课时和到期日由购买记录汇总
 	RegularSessions  int              `json:"regularSessions" gorm:"default:0"`
 	BonusSessions    int              `json:"bonusSessions" gorm:"default:0"`
 	ContractImages   string           `json:"contractImages" gorm:"type:text"` // JSON格式存储多个合同图片路径
 	IsActive         bool             `json:"isActive" gorm:"default:true"`
 	Category         string           `json:"category" gorm:"size:50;default:general"`
 	Description      string           `json:"description" gorm:"type:text"`
+	MissedPolicy     string           `json:"missedPolicy" gorm:"size:20;default:none"` // 过期未确认出勤的处理策略
+	MissedPolicySince *time.Time      `json:"missedPolicySince"`                        // 开启自动处理策略的时间，更早的课不自动处理
+	ExpiresOn        *string          `json:"expiresOn" gorm:"type:date"`               // 课包到期日，可选
+	LowSessionThreshold *int          `json:"lowSessionThreshold"`                      // 课时不足提醒阈值，为空时使用LOW_SESSION_THRESHOLD
 	CreatedAt        time.Time        `json:"createdAt"`
 	UpdatedAt        time.Time        `json:"updatedAt"`
 	DeletedAt        gorm.DeletedAt   `json:"-" gorm:"index"`
@@ -26,8 +30,18 @@ type Course struct {
 	Schedules        []CourseSchedule `json:"schedules,omitempty" gorm:"foreignKey:CourseID"`
 	AttendanceRecords []AttendanceRecord `json:"attendanceRecords,omitempty" gorm:"foreignKey:CourseID"`
 	Consumptions     []SessionConsumption `json:"consumptions,omitempty" gorm:"foreignKey:CourseID"`
+	Exceptions       []ScheduleException  `json:"exceptions,omitempty" gorm:"foreignKey:CourseID"`
+	Overrides        []OccurrenceOverride `json:"overrides,omitempty" gorm:"foreignKey:CourseID"`
+	Purchases        []CoursePurchase     `json:"purchases,omitempty" gorm:"foreignKey:CourseID"`
 }
 
+// 过期未确认出勤的处理策略
+const (
+	MissedPolicyNone   = "none"   // 保持待上课
+	MissedPolicyAbsent = "absent" // 标记缺席（no_show，不消课）
+	MissedPolicyAttend = "attend" // 标记出勤并消课（未到也扣课时的机构）
+)
+
 // GetTotalSessions 获取总课时数
 func (c *Course) GetTotalSessions() int {
 	return c.RegularSessions + c.BonusSessions
@@ -40,16 +54,30 @@ func (c *Course) GetConsumedSessions(db *gorm.DB) (int64, error) {
 	return count, err
 }
 
-// GetRemainingSessions 获取剩余课时数
+// GetConsumedSessionsByType 按课时类型获取已消耗课时数
+func (c *Course) GetConsumedSessionsByType(db *gorm.DB, sessionType string) (int64, error) {
+	var count int64
+	err := db.Model(&SessionConsumption{}).Where("course_id = ? AND session_type = ?", c.ID, sessionType).Select("COALESCE(SUM(sessions_consumed), 0)").Scan(&count).Error
+	return count, err
+}
+
+// GetRemainingSessions 获取剩余课时数（正式课时与赠送课时分池计算后求和）
 func (c *Course) GetRemainingSessions(db *gorm.DB) (int64, error) {
-	total := int64(c.GetTotalSessions())
-	consumed, err := c.GetConsumedSessions(db)
+	regularConsumed, err := c.GetConsumedSessionsByType(db, "regular")
+	if err != nil {
+		return 0, err
+	}
+	bonusConsumed, err := c.GetConsumedSessionsByType(db, "bonus")
 	if err != nil {
 		return 0, err
 	}
-	remaining := total - consumed
-	if remaining < 0 {
-		remaining = 0
+
+	remaining := int64(0)
+	if left := int64(c.RegularSessions) - regularConsumed; left > 0 {
+		remaining += left
+	}
+	if left := int64(c.BonusSessions) - bonusConsumed; left > 0 {
+		remaining += left
 	}
 	return remaining, nil
 }
@@ -60,18 +88,49 @@ type CourseWithStats struct {
 	TotalSessions     int64 `json:"totalSessions"`
 	ConsumedSessions  int64 `json:"consumedSessions"`
 	RemainingSessions int64 `json:"remainingSessions"`
+	Balance           CourseBalance `json:"balance"`
+}
+
+// CourseBalance 课时余额（正式课时与赠送课时分别统计）
+type CourseBalance struct {
+	RegularTotal     int64 `json:"regularTotal"`
+	RegularConsumed  int64 `json:"regularConsumed"`
+	RegularRemaining int64 `json:"regularRemaining"`
+	BonusTotal       int64 `json:"bonusTotal"`
+	BonusConsumed    int64 `json:"bonusConsumed"`
+	BonusRemaining   int64 `json:"bonusRemaining"`
+}
+
+// Remaining 获取指定课时类型的剩余数
+func (b CourseBalance) Remaining(sessionType string) int64 {
+	switch sessionType {
+	case "regular":
+		return b.RegularRemaining
+	case "bonus":
+		return b.BonusRemaining
+	}
+	return 0
+}
+
+// TotalRemaining 获取剩余课时总数
+func (b CourseBalance) TotalRemaining() int64 {
+	return b.RegularRemaining + b.BonusRemaining
 }
 
 // CourseRequest 课程请求结构
 type CourseRequest struct {
 	Name            string              `json:"name" binding:"required,min=1,max=100"`
-	TotalAmount     float64             `json:"totalAmount"`
+	TotalAmount     float64             `json:"totalAmount"`                        // 更新时与当前总数的差额记为调整购买记录
 	RegularSessions int                 `json:"regularSessions" binding:"min=0"`
 	BonusSessions   int                 `json:"bonusSessions" binding:"min=0"`
 	ContractImages  []string            `json:"contractImages"` // 多个合同图片路径
 	Category        string              `json:"category"`
 	Description     string              `json:"description"`
+	MissedPolicy    string              `json:"missedPolicy" binding:"omitempty,oneof=none absent attend"` // 为空时创建使用none，更新保持不变
+	ExpiresOn       *string             `json:"expiresOn"`                                    // YYYY-MM-DD，不传保持不变，空字符串清除
+	LowSessionThreshold *int            `json:"lowSessionThreshold" binding:"omitempty,min=0"` // 不传保持不变
 	Schedules       []CourseScheduleRequest `json:"schedules"`
+	Exceptions      []ScheduleExceptionRequest `json:"exceptions"` // 不上课的日期（如节假日）
 }
 
 // CourseScheduleRequest 课程安排请求
@@ -81,4 +140,10 @@ type CourseScheduleRequest struct {
 	EndTime   string `json:"endTime" binding:"required"`
 	Location  string `json:"location"`
 	Instructor string `json:"instructor"`
+	StartDate string `json:"startDate"` // YYYY-MM-DD，可选
+	EndDate   string `json:"endDate"`   // YYYY-MM-DD，可选
+	Recurrence string `json:"recurrence" binding:"omitempty,oneof=weekly monthly"`
+	Interval  int    `json:"interval" binding:"min=0"`
+	WeekOfMonth int  `json:"weekOfMonth" binding:"min=-1,max=5"`
+	Timezone  string `json:"timezone"` // IANA时区，可选，为空使用用户时区
 }
\ No newline at end of file
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// JobLease 定时任务租约，多实例部署时同一任务同一时刻只由持有租约的实例执行
+type JobLease struct {
+	Name       string    `json:"name" gorm:"primaryKey;size:64"`
+	Owner      string    `json:"owner" gorm:"not null;size:128"`
+	LeaseUntil time.Time `json:"leaseUntil" gorm:"not null"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// 任务运行状态
+const (
+	JobRunRunning = "running"
+	JobRunSuccess = "success"
+	JobRunFailed  = "failed"
+)
+
+// 任务触发方式
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobRun 定时任务运行记录
+type JobRun struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	JobName     string     `json:"jobName" gorm:"not null;size:64;index:idx_job_runs_name_started"`
+	TriggeredBy string     `json:"triggeredBy" gorm:"not null;size:20"` // schedule / manual
+	Instance    string     `json:"instance" gorm:"size:128"`
+	Status      string     `json:"status" gorm:"not null;size:20;index"`
+	Processed   int        `json:"processed"`
+	Error       string     `json:"error" gorm:"type:text"`
+	StartedAt   time.Time  `json:"startedAt" gorm:"not null;index:idx_job_runs_name_started"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+}
+
+// GetStatusText 获取状态描述
+func (r *JobRun) GetStatusText() string {
+	statusMap := map[string]string{
+		JobRunRunning: "运行中",
+		JobRunSuccess: "成功",
+		JobRunFailed:  "失败",
+	}
+	if text, exists := statusMap[r.Status]; exists {
+		return text
+	}
+	return r.Status
+}
+
+// JobRunResponse 任务运行记录响应
+type JobRunResponse struct {
+	JobRun
+	StatusText string `json:"statusText"`
+	DurationMs int64  `json:"durationMs"` // 运行耗时，未结束时为0
+}
+
+// ToResponse 转换为响应结构
+func (r *JobRun) ToResponse() JobRunResponse {
+	response := JobRunResponse{
+		JobRun:     *r,
+		StatusText: r.GetStatusText(),
+	}
+	if r.FinishedAt != nil {
+		response.DurationMs = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	}
+	return response
+}
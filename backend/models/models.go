@@ -1,16 +1,238 @@
 package models
 
 import (
+	"fmt"
+	"log"
+	"strings"
+
 	"gorm.io/gorm"
 )
 
 // AutoMigrate 自动迁移所有模型
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// 添加出勤记录唯一约束前先合并重复记录
+	if err := dedupeAttendanceRecords(db); err != nil {
+		return err
+	}
+	// 购买记录表首次创建时才需要为已有课程生成初始课包
+	purchasesCreated := !db.Migrator().HasTable(&CoursePurchase{})
+
+	if err := db.AutoMigrate(
 		&User{},
 		&Course{},
 		&CourseSchedule{},
 		&AttendanceRecord{},
 		&SessionConsumption{},
-	)
-}
\ No newline at end of file
+		&ScheduleException{},
+		&OccurrenceOverride{},
+		&CalendarFeedToken{},
+		&PushSubscription{},
+		&NotificationPreference{},
+		&NotificationChannel{},
+		&NotificationMute{},
+		&ReminderLog{},
+		&NotificationOutbox{},
+		&InboxMessage{},
+		&AttendanceActionToken{},
+		&JobLease{},
+		&JobRun{},
+		&CourseAlert{},
+		&CoursePurchase{},
+	); err != nil {
+		return err
+	}
+
+	// AutoMigrate 不会修改已有enum列的取值，需要单独扩展
+	if err := migrateAttendanceStatuses(db); err != nil {
+		return err
+	}
+
+	// 课程总数改为由购买记录汇总，只在升级时执行一次
+	if !purchasesCreated {
+		return nil
+	}
+	if err := migrateCoursePurchases(db); err != nil {
+		// 删除新建的表，下次启动时重新迁移
+		if dropErr := db.Migrator().DropTable(&CoursePurchase{}); dropErr != nil {
+			log.Printf("删除购买记录表失败: %v", dropErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// dedupeAttendanceRecords 合并同一课程同一天的重复出勤记录（旧版本在GET提醒接口和定时任务中可能重复创建）
+// 保留优先级：未删除 > 已有消课 > 已签到/请假 > ID最小；重复记录上的消课记录转到保留的记录，重复记录被物理删除
+func dedupeAttendanceRecords(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&AttendanceRecord{}) || migrator.HasIndex(&AttendanceRecord{}, "idx_attendance_course_date") {
+		return nil
+	}
+
+	var records []AttendanceRecord
+	err := db.Unscoped().
+		Where("(course_id, schedule_date) IN (?)", db.Unscoped().Model(&AttendanceRecord{}).
+			Select("course_id, schedule_date").
+			Group("course_id, schedule_date").
+			Having("COUNT(*) > 1")).
+		Order("course_id ASC, schedule_date ASC, id ASC").
+		Find(&records).Error
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	recordIDs := make([]uint, len(records))
+	for i, record := range records {
+		recordIDs[i] = record.ID
+	}
+	var consumedIDs []uint
+	if migrator.HasTable(&SessionConsumption{}) {
+		if err := db.Unscoped().Model(&SessionConsumption{}).
+			Where("attendance_id IN ?", recordIDs).
+			Distinct().
+			Pluck("attendance_id", &consumedIDs).Error; err != nil {
+			return err
+		}
+	}
+	consumed := make(map[uint]bool, len(consumedIDs))
+	for _, id := range consumedIDs {
+		consumed[id] = true
+	}
+
+	groups := make(map[string][]AttendanceRecord)
+	var keys []string
+	for _, record := range records {
+		key := fmt.Sprintf("%d-%s", record.CourseID, DateOnly(record.ScheduleDate))
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], record)
+	}
+
+	merged := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			keeper, duplicateIDs, updates := mergeAttendanceGroup(groups[key], consumed)
+
+			if len(consumedIDs) > 0 {
+				if err := tx.Unscoped().Model(&SessionConsumption{}).
+					Where("attendance_id IN ?", duplicateIDs).
+					Update("attendance_id", keeper.ID).Error; err != nil {
+					return err
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Unscoped().Model(&AttendanceRecord{}).Where("id = ?", keeper.ID).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id IN ?", duplicateIDs).Delete(&AttendanceRecord{}).Error; err != nil {
+				return err
+			}
+			merged += len(duplicateIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("已合并 %d 条重复的出勤记录", merged)
+	return nil
+}
+
+// mergeAttendanceGroup 选出同一课程同一天的重复记录中保留的一条，返回需要删除的记录和需要补到保留记录上的字段
+func mergeAttendanceGroup(group []AttendanceRecord, consumed map[uint]bool) (AttendanceRecord, []uint, map[string]interface{}) {
+	rank := func(record AttendanceRecord) int {
+		score := 0
+		if !record.DeletedAt.Valid {
+			score += 4
+		}
+		if consumed[record.ID] {
+			score += 2
+		}
+		if record.Status != AttendancePending {
+			score++
+		}
+		return score
+	}
+
+	keeper := group[0]
+	for _, record := range group[1:] {
+		if rank(record) > rank(keeper) {
+			keeper = record
+		}
+	}
+
+	var duplicateIDs []uint
+	updates := map[string]interface{}{}
+	for _, record := range group {
+		if record.ID == keeper.ID {
+			continue
+		}
+		duplicateIDs = append(duplicateIDs, record.ID)
+		if record.ReminderSent && !keeper.ReminderSent {
+			keeper.ReminderSent = true
+			updates["reminder_sent"] = true
+		}
+		if keeper.Notes == "" && record.Notes != "" {
+			keeper.Notes = record.Notes
+			updates["notes"] = record.Notes
+		}
+	}
+	return keeper, duplicateIDs, updates
+}
+
+// migrateAttendanceStatuses 扩展出勤状态enum（迟到、缺席、机构取消、补课）
+func migrateAttendanceStatuses(db *gorm.DB) error {
+	columnTypes, err := db.Migrator().ColumnTypes(&AttendanceRecord{})
+	if err != nil {
+		return err
+	}
+	for _, columnType := range columnTypes {
+		if columnType.Name() != "status" {
+			continue
+		}
+		if fullType, ok := columnType.ColumnType(); ok && strings.Contains(fullType, "'makeup'") {
+			return nil
+		}
+		return db.Migrator().AlterColumn(&AttendanceRecord{}, "Status")
+	}
+	return nil
+}
+
+// migrateCoursePurchases 为没有购买记录的旧课程创建初始课包，金额、课时和到期日取课程原有的值
+// 只在购买记录表首次创建时由 AutoMigrate 调用
+func migrateCoursePurchases(db *gorm.DB) error {
+	var courses []Course
+	err := db.Unscoped().
+		Where("id NOT IN (?)", db.Unscoped().Model(&CoursePurchase{}).Select("course_id")).
+		Find(&courses).Error
+	if err != nil || len(courses) == 0 {
+		return err
+	}
+
+	purchases := make([]CoursePurchase, 0, len(courses))
+	for _, course := range courses {
+		purchase := CoursePurchase{
+			CourseID:        course.ID,
+			Kind:            PurchaseInitial,
+			PurchasedOn:     course.CreatedAt.Format("2006-01-02"),
+			AmountPaid:      course.TotalAmount,
+			RegularSessions: course.RegularSessions,
+			BonusSessions:   course.BonusSessions,
+			Notes:           "由课程原有数据迁移",
+		}
+		if course.ExpiresOn != nil {
+			expiresOn := DateOnly(*course.ExpiresOn)
+			purchase.ExpiresOn = &expiresOn
+		}
+		purchases = append(purchases, purchase)
+	}
+	if err := db.CreateInBatches(&purchases, 100).Error; err != nil {
+		return err
+	}
+
+	log.Printf("已为 %d 门课程创建初始课包", len(purchases))
+	return nil
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMergeAttendanceGroup(t *testing.T) {
+	deleted := gorm.DeletedAt{Time: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	tests := []struct {
+		name       string
+		group      []AttendanceRecord
+		consumed   map[uint]bool
+		keeper     uint
+		duplicates []uint
+		updates    map[string]interface{}
+	}{
+		{
+			name:       "lowest id by default",
+			group:      []AttendanceRecord{{ID: 1, Status: "pending"}, {ID: 2, Status: "pending"}},
+			keeper:     1,
+			duplicates: []uint{2},
+			updates:    map[string]interface{}{},
+		},
+		{
+			name:       "checked in over pending",
+			group:      []AttendanceRecord{{ID: 1, Status: "pending"}, {ID: 2, Status: "attend"}},
+			keeper:     2,
+			duplicates: []uint{1},
+			updates:    map[string]interface{}{},
+		},
+		{
+			name:       "consumed over checked in",
+			group:      []AttendanceRecord{{ID: 1, Status: "attend"}, {ID: 2, Status: "pending"}},
+			consumed:   map[uint]bool{2: true},
+			keeper:     2,
+			duplicates: []uint{1},
+			updates:    map[string]interface{}{},
+		},
+		{
+			name:       "not deleted over consumed",
+			group:      []AttendanceRecord{{ID: 1, Status: "attend", DeletedAt: deleted}, {ID: 2, Status: "pending"}},
+			consumed:   map[uint]bool{1: true},
+			keeper:     2,
+			duplicates: []uint{1},
+			updates:    map[string]interface{}{},
+		},
+		{
+			name: "reminder and notes are carried over",
+			group: []AttendanceRecord{
+				{ID: 1, Status: "attend"},
+				{ID: 2, Status: "pending", ReminderSent: true, Notes: "带琴谱"},
+				{ID: 3, Status: "pending", Notes: "迟到"},
+			},
+			keeper:     1,
+			duplicates: []uint{2, 3},
+			updates:    map[string]interface{}{"reminder_sent": true, "notes": "带琴谱"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keeper, duplicates, updates := mergeAttendanceGroup(tt.group, tt.consumed)
+			if keeper.ID != tt.keeper {
+				t.Errorf("keeper = %d, want %d", keeper.ID, tt.keeper)
+			}
+			if !reflect.DeepEqual(duplicates, tt.duplicates) {
+				t.Errorf("duplicates = %v, want %v", duplicates, tt.duplicates)
+			}
+			if !reflect.DeepEqual(updates, tt.updates) {
+				t.Errorf("updates = %v, want %v", updates, tt.updates)
+			}
+		})
+	}
+}
+
+// newMockDB 创建基于sqlmock的MySQL方言gorm连接，测试结束时检查所有预期的SQL都已执行
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		conn.Close()
+	})
+	return db, mock
+}
+
+// expectCurrentDatabase 预期迁移器查询当前数据库名（HasTable、HasIndex之前都会查询）
+func expectCurrentDatabase(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT DATABASE\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"database"}).AddRow("course"))
+	mock.ExpectQuery("SELECT SCHEMA_NAME from Information_schema.SCHEMATA").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("course"))
+}
+
+func TestDedupeAttendanceRecords(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	count := func(n int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"count"}).AddRow(n)
+	}
+	expectCurrentDatabase(mock)
+	mock.ExpectQuery("FROM information_schema.tables").WithArgs("course", "attendance_records", "BASE TABLE").WillReturnRows(count(1))
+	expectCurrentDatabase(mock)
+	mock.ExpectQuery("FROM information_schema.statistics").WithArgs("course", "attendance_records", "idx_attendance_course_date").WillReturnRows(count(0))
+
+	// 课程5在01-05有两条记录：1未签到，2已签到并消课；课程6在01-06有两条记录，4在回收站
+	mock.ExpectQuery("SELECT \\* FROM `attendance_records` WHERE \\(course_id, schedule_date\\) IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "course_id", "schedule_date", "status", "notes", "reminder_sent", "deleted_at"}).
+			AddRow(1, 5, "2026-01-05", "pending", "带琴谱", true, nil).
+			AddRow(2, 5, "2026-01-05", "attend", "", false, nil).
+			AddRow(3, 6, "2026-01-06", "pending", "", false, nil).
+			AddRow(4, 6, "2026-01-06", "attend", "", false, time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC)))
+	expectCurrentDatabase(mock)
+	mock.ExpectQuery("FROM information_schema.tables").WithArgs("course", "session_consumptions", "BASE TABLE").WillReturnRows(count(1))
+	mock.ExpectQuery("SELECT DISTINCT `attendance_id` FROM `session_consumptions` WHERE attendance_id IN").
+		WithArgs(1, 2, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"attendance_id"}).AddRow(2))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `session_consumptions` SET `attendance_id`=\\?,`updated_at`=\\? WHERE attendance_id IN \\(\\?\\)").
+		WithArgs(2, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE `attendance_records` SET .* WHERE id = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM `attendance_records` WHERE id IN \\(\\?\\)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `session_consumptions` SET `attendance_id`=\\?,`updated_at`=\\? WHERE attendance_id IN \\(\\?\\)").
+		WithArgs(3, sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM `attendance_records` WHERE id IN \\(\\?\\)").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := dedupeAttendanceRecords(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDedupeAttendanceRecordsSkipsWhenIndexExists(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	expectCurrentDatabase(mock)
+	mock.ExpectQuery("FROM information_schema.tables").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expectCurrentDatabase(mock)
+	mock.ExpectQuery("FROM information_schema.statistics").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	if err := dedupeAttendanceRecords(db); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+	"gorm.io/gorm"
+)
+
+// PushSubscription 浏览器推送订阅（同一endpoint只保存一条）
+type PushSubscription struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"userId" gorm:"not null;index"`
+	Endpoint      string     `json:"endpoint" gorm:"not null;uniqueIndex;size:500"`
+	P256dh        string     `json:"-" gorm:"not null;size:255"`
+	Auth          string     `json:"-" gorm:"not null;size:255"`
+	UserAgent     string     `json:"userAgent" gorm:"size:500"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+
+	// 关联
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// PushSubscriptionRequest 浏览器 PushSubscription.toJSON() 的格式
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url,max=500"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys"`
+}
+
+// PushUnsubscribeRequest 取消订阅请求
+type PushUnsubscribeRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+// NotificationPreference 用户通知偏好（没有记录时使用默认值）
+type NotificationPreference struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"userId" gorm:"not null;uniqueIndex"`
+	EmailEnabled bool      `json:"emailEnabled" gorm:"default:false"` // 是否接收邮件提醒（需用户主动开启）
+	LeadMinutes  string    `json:"-" gorm:"size:100"`               // 提醒提前量（分钟，逗号分隔），为空使用系统默认
+	QuietStart   string    `json:"quietStart" gorm:"type:varchar(5)"` // 免打扰开始时间 HH:MM，为空表示不启用
+	QuietEnd     string    `json:"quietEnd" gorm:"type:varchar(5)"`   // 免打扰结束时间 HH:MM，可跨零点
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// NotificationMute 用户对某门课程关闭提醒
+type NotificationMute struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"userId" gorm:"not null;uniqueIndex:idx_mute_user_course"`
+	CourseID  uint      `json:"courseId" gorm:"not null;uniqueIndex:idx_mute_user_course"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ReminderLog 已发送的课程提醒，保证每次课每个提前量只提醒一次
+type ReminderLog struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	UserID         uint      `json:"userId" gorm:"not null;index"`
+	CourseID       uint      `json:"courseId" gorm:"not null;uniqueIndex:idx_reminder_occurrence_lead"`
+	OccurrenceDate string    `json:"occurrenceDate" gorm:"not null;type:date;uniqueIndex:idx_reminder_occurrence_lead"`
+	StartTime      string    `json:"startTime" gorm:"not null;type:varchar(5);uniqueIndex:idx_reminder_occurrence_lead"`
+	LeadMinutes    int       `json:"leadMinutes" gorm:"not null;uniqueIndex:idx_reminder_occurrence_lead"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// NotificationPreferenceRequest 更新通知偏好请求（未传的字段保持不变）
+type NotificationPreferenceRequest struct {
+	EmailEnabled   *bool   `json:"emailEnabled"`
+	LeadMinutes    []int   `json:"leadMinutes" binding:"omitempty,max=5,dive,min=1,max=10080"`
+	QuietStart     *string `json:"quietStart"`
+	QuietEnd       *string `json:"quietEnd"`
+	MutedCourseIDs []uint  `json:"mutedCourseIds"` // 传入时替换全部静音课程
+}
+
+// NotificationPreferenceResponse 通知偏好响应
+type NotificationPreferenceResponse struct {
+	EmailEnabled   bool   `json:"emailEnabled"`
+	LeadMinutes    []int  `json:"leadMinutes"`
+	QuietStart     string `json:"quietStart"`
+	QuietEnd       string `json:"quietEnd"`
+	MutedCourseIDs []uint `json:"mutedCourseIds"`
+}
+
+// 通知渠道类型
+const (
+	ChannelTypeWebhook = "webhook"
+)
+
+// NotificationChannel 用户配置的外部通知渠道（如转发到聊天工具的Webhook）
+type NotificationChannel struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"userId" gorm:"not null;index"`
+	Type          string     `json:"type" gorm:"not null;size:20;default:'webhook'"`
+	Name          string     `json:"name" gorm:"size:50"`
+	URL           string     `json:"url" gorm:"not null;size:500"`
+	Secret        string     `json:"-" gorm:"not null;size:64"` // HMAC签名密钥，仅创建时返回一次
+	IsActive      bool       `json:"isActive" gorm:"default:true"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt"`
+	LastError     string     `json:"lastError" gorm:"size:500"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// NotificationChannelRequest 创建/更新通知渠道请求
+type NotificationChannelRequest struct {
+	Type     string `json:"type" binding:"omitempty,oneof=webhook"`
+	Name     string `json:"name" binding:"max=50"`
+	URL      string `json:"url" binding:"required,url,max=500"`
+	IsActive *bool  `json:"isActive"`
+}
+
+// 通知发送状态
+const (
+	OutboxPending   = "pending"   // 等待发送或等待重试
+	OutboxDelivered = "delivered" // 已发送
+	OutboxFailed    = "failed"    // 重试次数用尽或没有可用渠道
+)
+
+// NotificationOutbox 通知发件箱，每条记录对应一条通知在一个渠道上的发送
+type NotificationOutbox struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"userId" gorm:"not null;index"`
+	Type          string     `json:"type" gorm:"not null;size:50"`
+	Channel       string     `json:"channel" gorm:"not null;size:20"`
+	Title         string     `json:"title" gorm:"size:200"`
+	Body          string     `json:"body" gorm:"size:500"`
+	Payload       string     `json:"-" gorm:"type:text"` // 完整通知内容（JSON），重试时使用
+	Status        string     `json:"status" gorm:"not null;size:20;default:'pending';index:idx_outbox_status_next"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt" gorm:"index:idx_outbox_status_next"`
+	LastError     string     `json:"lastError" gorm:"size:500"`
+	DeliveredAt   *time.Time `json:"deliveredAt"`
+	DeliveredTargets string  `json:"-" gorm:"type:text"` // 多目标渠道中已发送成功的目标（逗号分隔），重试时跳过
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// GetStatusText 获取状态文本
+func (o *NotificationOutbox) GetStatusText() string {
+	switch o.Status {
+	case OutboxPending:
+		return "等待发送"
+	case OutboxDelivered:
+		return "已发送"
+	case OutboxFailed:
+		return "发送失败"
+	default:
+		return "未知"
+	}
+}
+
+// InboxMessage 站内通知（无论推送是否可用都会保存）
+type InboxMessage struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"not null;index:idx_inbox_user_read"`
+	Type      string     `json:"type" gorm:"not null;size:50"`
+	Title     string     `json:"title" gorm:"size:200"`
+	Body      string     `json:"body" gorm:"size:500"`
+	Data      string     `json:"-" gorm:"type:text"` // 附加数据（JSON）
+	ReadAt    *time.Time `json:"readAt" gorm:"index:idx_inbox_user_read"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// InboxMessageResponse 站内通知响应
+type InboxMessageResponse struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data"`
+	IsRead    bool            `json:"isRead"`
+	ReadAt    *time.Time      `json:"readAt"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// ToResponse 转换为响应结构
+func (m *InboxMessage) ToResponse() InboxMessageResponse {
+	data := json.RawMessage("null")
+	if m.Data != "" && json.Valid([]byte(m.Data)) {
+		data = json.RawMessage(m.Data)
+	}
+	return InboxMessageResponse{
+		ID:        m.ID,
+		Type:      m.Type,
+		Title:     m.Title,
+		Body:      m.Body,
+		Data:      data,
+		IsRead:    m.ReadAt != nil,
+		ReadAt:    m.ReadAt,
+		CreatedAt: m.CreatedAt,
+	}
+}
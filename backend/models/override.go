@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"time"
+	"gorm.io/gorm"
+)
+
+// 调课操作类型
+const (
+	OverrideReschedule = "reschedule" // 单次调课
+	OverrideCancel     = "cancel"     // 机构取消（不消耗课时）
+)
+
+// OccurrenceOverride 单次上课调整（调课/机构取消）
+// 通过原上课日期和原开始时间定位被调整的那一次课
+type OccurrenceOverride struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	CourseID          uint      `json:"courseId" gorm:"not null;index"`
+	OriginalDate      string    `json:"originalDate" gorm:"not null;type:date;index"`
+	OriginalStartTime string    `json:"originalStartTime" gorm:"not null;type:varchar(5)"`
+	Action            string    `json:"action" gorm:"not null;type:enum('reschedule','cancel')"`
+	NewDate           *string   `json:"newDate" gorm:"type:date;index"`
+	NewStartTime      string    `json:"newStartTime" gorm:"type:varchar(5)"`
+	NewEndTime        string    `json:"newEndTime" gorm:"type:varchar(5)"`
+	Location          string    `json:"location" gorm:"size:100"` // 调课后的地点，为空沿用原地点
+	Reason            string    `json:"reason" gorm:"size:200"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// BeforeCreate GORM钩子 - 验证调整内容
+func (o *OccurrenceOverride) BeforeCreate(tx *gorm.DB) error {
+	return o.validate()
+}
+
+// validate 验证调整内容
+func (o *OccurrenceOverride) validate() error {
+	if _, err := time.Parse("2006-01-02", DateOnly(o.OriginalDate)); err != nil {
+		return errors.New("原上课日期格式必须为YYYY-MM-DD")
+	}
+	if o.Action != OverrideReschedule {
+		return nil
+	}
+	if o.NewDate == nil {
+		return errors.New("调课必须指定新的上课日期")
+	}
+	if _, err := time.Parse("2006-01-02", DateOnly(*o.NewDate)); err != nil {
+		return errors.New("新上课日期格式必须为YYYY-MM-DD")
+	}
+	if _, err := time.Parse("15:04", o.NewStartTime); err != nil {
+		return errors.New("新开始时间格式必须为HH:MM")
+	}
+	if _, err := time.Parse("15:04", o.NewEndTime); err != nil {
+		return errors.New("新结束时间格式必须为HH:MM")
+	}
+	if o.NewStartTime >= o.NewEndTime {
+		return errors.New("开始时间必须早于结束时间")
+	}
+	return nil
+}
+
+// GetActionText 获取调整类型描述
+func (o *OccurrenceOverride) GetActionText() string {
+	actionMap := map[string]string{
+		OverrideReschedule: "调课",
+		OverrideCancel:     "机构取消",
+	}
+	if text, exists := actionMap[o.Action]; exists {
+		return text
+	}
+	return o.Action
+}
+
+// OccurrenceOverrideRequest 单次上课调整请求
+type OccurrenceOverrideRequest struct {
+	OriginalDate      string `json:"originalDate" binding:"required"`
+	OriginalStartTime string `json:"originalStartTime" binding:"required"`
+	Action            string `json:"action" binding:"required,oneof=reschedule cancel"`
+	NewDate           string `json:"newDate"`
+	NewStartTime      string `json:"newStartTime"`
+	NewEndTime        string `json:"newEndTime"`
+	Location          string `json:"location"`
+	Reason            string `json:"reason"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+	"gorm.io/gorm"
+)
+
+// 购买类型
+const (
+	PurchaseInitial    = "initial"    // 创建课程时的初始课包
+	PurchaseRenewal    = "renewal"    // 续费
+	PurchaseTopUp      = "top_up"     // 加购课时
+	PurchaseAdjustment = "adjustment" // 编辑课程总数时自动生成的调整
+)
+
+// CoursePurchase 课程购买记录（初始课包、续费、加购），课程的金额和课时总数由购买记录汇总
+type CoursePurchase struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	CourseID        uint           `json:"courseId" gorm:"not null;index"`
+	Kind            string         `json:"kind" gorm:"not null;size:20;default:top_up"`
+	PurchasedOn     string         `json:"purchasedOn" gorm:"not null;type:date"`
+	AmountPaid      float64        `json:"amountPaid" gorm:"type:decimal(10,2)"`
+	RegularSessions int            `json:"regularSessions" gorm:"default:0"`
+	BonusSessions   int            `json:"bonusSessions" gorm:"default:0"`
+	ExpiresOn       *string        `json:"expiresOn" gorm:"type:date"`
+	ReceiptImages   string         `json:"receiptImages" gorm:"type:text"` // JSON格式存储多个收据图片路径
+	Notes           string         `json:"notes" gorm:"type:text"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联
+	Course Course `json:"-" gorm:"foreignKey:CourseID"`
+}
+
+// GetKindText 获取购买类型描述
+func (p *CoursePurchase) GetKindText() string {
+	kindMap := map[string]string{
+		PurchaseInitial:    "初始课包",
+		PurchaseRenewal:    "续费",
+		PurchaseTopUp:      "加购",
+		PurchaseAdjustment: "调整",
+	}
+	if text, exists := kindMap[p.Kind]; exists {
+		return text
+	}
+	return p.Kind
+}
+
+// CoursePurchaseRequest 创建/更新购买记录请求
+type CoursePurchaseRequest struct {
+	Kind            string   `json:"kind" binding:"omitempty,oneof=renewal top_up"`
+	PurchasedOn     string   `json:"purchasedOn" binding:"required"` // YYYY-MM-DD
+	AmountPaid      float64  `json:"amountPaid" binding:"min=0"`
+	RegularSessions int      `json:"regularSessions" binding:"min=0"`
+	BonusSessions   int      `json:"bonusSessions" binding:"min=0"`
+	ExpiresOn       string   `json:"expiresOn"` // YYYY-MM-DD，可选
+	ReceiptImages   []string `json:"receiptImages"`
+	Notes           string   `json:"notes"`
+}
+
+// CoursePurchaseResponse 购买记录响应
+type CoursePurchaseResponse struct {
+	CoursePurchase
+	KindText      string   `json:"kindText"`
+	ReceiptImages []string `json:"receiptImages"` // 解析后的收据图片路径，覆盖存储用的JSON字符串
+}
+
+// ToResponse 转换为响应格式
+func (p *CoursePurchase) ToResponse() CoursePurchaseResponse {
+	receiptImages := []string{}
+	if p.ReceiptImages != "" {
+		if err := json.Unmarshal([]byte(p.ReceiptImages), &receiptImages); err != nil {
+			receiptImages = []string{}
+		}
+	}
+	return CoursePurchaseResponse{
+		CoursePurchase: *p,
+		KindText:       p.GetKindText(),
+		ReceiptImages:  receiptImages,
+	}
+}
@@ -16,6 +16,12 @@ type CourseSchedule struct {
 	IsActive  bool      `json:"isActive" gorm:"default:true"`
 	Location  string    `json:"location" gorm:"size:100"`
 	Instructor string   `json:"instructor" gorm:"size:50"`
+	StartDate *string   `json:"startDate" gorm:"type:date"` // 生效开始日期（含），为空表示自创建起生效
+	EndDate   *string   `json:"endDate" gorm:"type:date"`   // 生效结束日期（含），为空表示长期有效
+	Recurrence string   `json:"recurrence" gorm:"size:20;default:weekly"` // weekly: 每N周；monthly: 每N月的第几个星期几
+	Interval  int       `json:"interval" gorm:"default:1"`    // 重复间隔，1表示每周/每月
+	WeekOfMonth int     `json:"weekOfMonth" gorm:"default:0"` // monthly时使用：1-5表示第几个，-1表示最后一个
+	Timezone  string    `json:"timezone" gorm:"size:64"`      // 上课地点的IANA时区（如异地上课），为空使用用户时区
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -42,9 +48,141 @@ func (cs *CourseSchedule) validateTime() error {
 	if cs.Weekday < 1 || cs.Weekday > 7 {
 		return errors.New("星期几必须在1-7之间")
 	}
+	switch cs.Recurrence {
+	case "", RecurrenceWeekly:
+	case RecurrenceMonthly:
+		if cs.WeekOfMonth != -1 && (cs.WeekOfMonth < 1 || cs.WeekOfMonth > 5) {
+			return errors.New("每月重复时第几周必须为1-5或-1（最后一周）")
+		}
+	default:
+		return errors.New("不支持的重复方式")
+	}
+	if cs.Interval < 0 {
+		return errors.New("重复间隔不能为负数")
+	}
+	if cs.Timezone != "" {
+		if _, err := time.LoadLocation(cs.Timezone); err != nil {
+			return errors.New("无效的时区")
+		}
+	}
+	for _, date := range []*string{cs.StartDate, cs.EndDate} {
+		if date == nil {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", DateOnly(*date)); err != nil {
+			return errors.New("日期格式必须为YYYY-MM-DD")
+		}
+	}
+	if cs.StartDate != nil && cs.EndDate != nil && DateOnly(*cs.StartDate) > DateOnly(*cs.EndDate) {
+		return errors.New("开始日期不能晚于结束日期")
+	}
 	return nil
 }
 
+// 重复方式
+const (
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+// OccursOn 判断该排课在指定日期是否上课（不含例外日期）
+func (cs *CourseSchedule) OccursOn(date time.Time) bool {
+	day := date.Format("2006-01-02")
+	if cs.StartDate != nil && *cs.StartDate != "" && day < DateOnly(*cs.StartDate) {
+		return false
+	}
+	if cs.EndDate != nil && *cs.EndDate != "" && day > DateOnly(*cs.EndDate) {
+		return false
+	}
+	if IsoWeekday(date) != cs.Weekday {
+		return false
+	}
+
+	interval := cs.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	anchor := cs.anchorDate(date.Location())
+
+	switch cs.Recurrence {
+	case RecurrenceMonthly:
+		nth := (date.Day()-1)/7 + 1
+		if cs.WeekOfMonth == -1 {
+			if date.AddDate(0, 0, 7).Month() == date.Month() {
+				return false
+			}
+		} else if nth != cs.WeekOfMonth {
+			return false
+		}
+		months := (date.Year()-anchor.Year())*12 + int(date.Month()-anchor.Month())
+		return months%interval == 0
+	default:
+		if interval == 1 {
+			return true
+		}
+		weeks := daysBetween(startOfWeek(anchor), startOfWeek(date)) / 7
+		return weeks%interval == 0
+	}
+}
+
+// anchorDate 重复间隔的计算起点：优先使用开始日期，否则使用创建日期
+func (cs *CourseSchedule) anchorDate(loc *time.Location) time.Time {
+	if cs.StartDate != nil && *cs.StartDate != "" {
+		if t, err := time.ParseInLocation("2006-01-02", DateOnly(*cs.StartDate), loc); err == nil {
+			return t
+		}
+	}
+	created := cs.CreatedAt.In(loc)
+	return time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, loc)
+}
+
+// ScheduleException 课程例外日期（节假日等停课日）
+type ScheduleException struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CourseID  uint      `json:"courseId" gorm:"not null;index"`
+	Date      string    `json:"date" gorm:"not null;type:date;index"`
+	Reason    string    `json:"reason" gorm:"size:200"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// ScheduleExceptionRequest 例外日期请求
+type ScheduleExceptionRequest struct {
+	Date   string `json:"date" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+// IsoWeekday 获取星期几（1-7 对应周一到周日）
+func IsoWeekday(t time.Time) int {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7 // 周日转换为7
+	}
+	return weekday
+}
+
+// DateOnly 截取日期部分（数据库date类型读出时可能带有时间部分）
+func DateOnly(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
+
+// startOfWeek 获取所在周的周一
+func startOfWeek(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, 1-IsoWeekday(day))
+}
+
+// daysBetween 计算两个日期相差的天数
+func daysBetween(from, to time.Time) int {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
 // GetWeekdayText 获取星期几的中文描述
 func (cs *CourseSchedule) GetWeekdayText() string {
 	weekdays := []string{"", "周一", "周二", "周三", "周四", "周五", "周六", "周日"}
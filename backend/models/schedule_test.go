@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func datePtr(date string) *string {
+	return &date
+}
+
+func TestCourseScheduleOccursOn(t *testing.T) {
+	// 2026-01-05、2026-01-26、2026-03-30 均为周一
+	created := time.Date(2026, 1, 6, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule CourseSchedule
+		dates    map[string]bool
+	}{
+		{
+			name:     "weekly",
+			schedule: CourseSchedule{Weekday: 1, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-05": true, "2026-01-06": false, "2026-01-12": true},
+		},
+		{
+			name:     "sunday is seven",
+			schedule: CourseSchedule{Weekday: 7, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-04": true, "2026-01-05": false},
+		},
+		{
+			name:     "date range",
+			schedule: CourseSchedule{Weekday: 1, StartDate: datePtr("2026-01-12"), EndDate: datePtr("2026-01-19T00:00:00+08:00")},
+			dates:    map[string]bool{"2026-01-05": false, "2026-01-12": true, "2026-01-19": true, "2026-01-26": false},
+		},
+		{
+			name:     "every two weeks from start date",
+			schedule: CourseSchedule{Weekday: 1, Interval: 2, StartDate: datePtr("2026-01-07")},
+			dates:    map[string]bool{"2026-01-05": false, "2026-01-12": false, "2026-01-19": true, "2026-01-26": false, "2026-02-02": true},
+		},
+		{
+			name:     "every two weeks from creation",
+			schedule: CourseSchedule{Weekday: 1, Interval: 2, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-05": true, "2026-01-12": false, "2026-01-19": true},
+		},
+		{
+			name:     "zero interval is weekly",
+			schedule: CourseSchedule{Weekday: 1, Interval: 0, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-05": true, "2026-01-12": true},
+		},
+		{
+			name:     "monthly first monday",
+			schedule: CourseSchedule{Weekday: 1, Recurrence: RecurrenceMonthly, WeekOfMonth: 1, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-05": true, "2026-01-12": false, "2026-02-02": true, "2026-02-09": false},
+		},
+		{
+			name:     "monthly last monday",
+			schedule: CourseSchedule{Weekday: 1, Recurrence: RecurrenceMonthly, WeekOfMonth: -1, CreatedAt: created},
+			dates:    map[string]bool{"2026-01-26": true, "2026-02-23": true, "2026-03-23": false, "2026-03-30": true},
+		},
+		{
+			name:     "monthly fifth monday",
+			schedule: CourseSchedule{Weekday: 1, Recurrence: RecurrenceMonthly, WeekOfMonth: 5, CreatedAt: created},
+			dates:    map[string]bool{"2026-02-23": false, "2026-03-30": true, "2026-06-29": true},
+		},
+		{
+			name:     "every two months from start date",
+			schedule: CourseSchedule{Weekday: 1, Recurrence: RecurrenceMonthly, WeekOfMonth: 1, Interval: 2, StartDate: datePtr("2026-01-01")},
+			dates:    map[string]bool{"2026-01-05": true, "2026-02-02": false, "2026-03-02": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for date, want := range tt.dates {
+				day, err := time.ParseInLocation("2006-01-02", date, time.UTC)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := tt.schedule.OccursOn(day); got != want {
+					t.Errorf("OccursOn(%s) = %v, want %v", date, got, want)
+				}
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ type User struct {
 	Username  string    `json:"username" gorm:"uniqueIndex;not null;size:50"`
 	Password  string    `json:"-" gorm:"not null;size:255"`
 	Email     string    `json:"email" gorm:"size:100"`
+	IsAdmin   bool      `json:"isAdmin" gorm:"default:false"` // 管理员可查看和触发定时任务
+	Timezone  string    `json:"timezone" gorm:"size:64"`      // IANA时区，今日课程、提醒和签到时间按此计算，为空使用服务器时区
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -51,6 +53,8 @@ type UserResponse struct {
 	ID        uint      `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
+	IsAdmin   bool      `json:"isAdmin"`
+	Timezone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -60,6 +64,8 @@ func (u *User) ToResponse() UserResponse {
 		ID:        u.ID,
 		Username:  u.Username,
 		Email:     u.Email,
+		IsAdmin:   u.IsAdmin,
+		Timezone:  u.Timezone,
 		CreatedAt: u.CreatedAt,
 	}
 }
\ No newline at end of file
@@ -17,6 +17,7 @@ func SetupRoutes(r *gin.Engine) {
 			authGroup.POST("/register", handlers.Register)
 			authGroup.POST("/login", handlers.Login)
 			authGroup.GET("/profile", middleware.AuthRequired(), handlers.GetProfile)
+			authGroup.PUT("/profile", middleware.AuthRequired(), handlers.UpdateProfile)
 		}
 
 		// 课程路由
@@ -26,11 +27,32 @@ func SetupRoutes(r *gin.Engine) {
 			coursesGroup.GET("", handlers.GetCourses)
 			coursesGroup.GET("/today", handlers.GetTodayCourses)
 			coursesGroup.GET("/:id", handlers.GetCourseById)
+			coursesGroup.GET("/:id/valuation", handlers.GetCourseValuation)
+			coursesGroup.GET("/:id/attendance", handlers.GetCourseAttendance)
+			coursesGroup.GET("/:id/attendance/stats", handlers.GetCourseAttendanceStats)
+			coursesGroup.GET("/:id/exceptions", handlers.GetCourseExceptions)
+			coursesGroup.POST("/:id/exceptions", handlers.CreateCourseException)
+			coursesGroup.DELETE("/:id/exceptions/:exceptionId", handlers.DeleteCourseException)
+			coursesGroup.GET("/:id/overrides", handlers.GetCourseOverrides)
+			coursesGroup.POST("/:id/overrides", handlers.CreateCourseOverride)
+			coursesGroup.DELETE("/:id/overrides/:overrideId", handlers.DeleteCourseOverride)
+			coursesGroup.GET("/:id/purchases", handlers.GetCoursePurchases)
+			coursesGroup.POST("/:id/purchases", handlers.CreateCoursePurchase)
+			coursesGroup.PUT("/:id/purchases/:purchaseId", handlers.UpdateCoursePurchase)
+			coursesGroup.DELETE("/:id/purchases/:purchaseId", handlers.DeleteCoursePurchase)
 			coursesGroup.POST("", handlers.CreateCourse)
+			coursesGroup.POST("/import", handlers.ImportCourses)
+			coursesGroup.POST("/import/ics", handlers.ImportCoursesFromICS)
 			coursesGroup.PUT("/:id", handlers.UpdateCourse)
 			coursesGroup.DELETE("/:id", handlers.DeleteCourse)
 		}
 
+		// 首页汇总
+		api.GET("/dashboard", middleware.AuthRequired(), handlers.GetDashboard)
+
+		// 报表
+		api.GET("/reports/spending", middleware.AuthRequired(), handlers.GetSpendingReport)
+
 		// 出勤路由
 		attendanceGroup := api.Group("/attendance")
 		attendanceGroup.Use(middleware.AuthRequired())
@@ -42,15 +64,65 @@ func SetupRoutes(r *gin.Engine) {
 			attendanceGroup.POST("/:id/reminders", handlers.SendReminder)
 		}
 
+		// 通知快捷出勤路由（通过通知中的一次性token访问，无需登录）
+		actionGroup := api.Group("/attendance/actions")
+		{
+			actionGroup.GET("", handlers.GetAttendanceAction)
+			actionGroup.POST("", handlers.SubmitAttendanceAction)
+		}
+
+		// 课时消耗路由
+		consumptionsGroup := api.Group("/consumptions")
+		consumptionsGroup.Use(middleware.AuthRequired())
+		{
+			consumptionsGroup.POST("", handlers.CreateConsumption)
+			consumptionsGroup.GET("/course/:courseId", handlers.GetCourseConsumptions)
+			consumptionsGroup.GET("/course/:courseId/stats", handlers.GetCourseConsumptionStats)
+			consumptionsGroup.GET("/:id", handlers.GetConsumption)
+			consumptionsGroup.DELETE("/:id", handlers.DeleteConsumption)
+		}
+
 		// 通知路由
 		notificationGroup := api.Group("/notifications")
 		notificationGroup.Use(middleware.AuthRequired())
 		{
+			notificationGroup.GET("/vapid-public-key", handlers.GetVAPIDPublicKey)
+			notificationGroup.GET("/subscriptions", handlers.GetNotificationSubscriptions)
+			notificationGroup.GET("/preferences", handlers.GetNotificationPreferences)
+			notificationGroup.PUT("/preferences", handlers.UpdateNotificationPreferences)
+			notificationGroup.GET("/history", handlers.GetNotificationHistory)
+			notificationGroup.GET("/inbox", handlers.GetInboxMessages)
+			notificationGroup.GET("/inbox/unread-count", handlers.GetInboxUnreadCount)
+			notificationGroup.PUT("/inbox/read-all", handlers.MarkAllInboxMessagesRead)
+			notificationGroup.PUT("/inbox/:id/read", handlers.MarkInboxMessageRead)
+			notificationGroup.GET("/channels", handlers.GetNotificationChannels)
+			notificationGroup.POST("/channels", handlers.CreateNotificationChannel)
+			notificationGroup.PUT("/channels/:id", handlers.UpdateNotificationChannel)
+			notificationGroup.DELETE("/channels/:id", handlers.DeleteNotificationChannel)
+			notificationGroup.POST("/channels/:id/test", handlers.TestNotificationChannel)
 			notificationGroup.POST("/subscribe", handlers.SubscribeNotifications)
 			notificationGroup.POST("/unsubscribe", handlers.UnsubscribeNotifications)
 			notificationGroup.POST("/test", handlers.TestNotification)
 		}
 
+		// 日历订阅路由（订阅地址使用独立token，无需登录）
+		calendarGroup := api.Group("/calendar")
+		{
+			calendarGroup.GET("/feed-token", middleware.AuthRequired(), handlers.GetCalendarFeedToken)
+			calendarGroup.POST("/feed-token", middleware.AuthRequired(), handlers.CreateCalendarFeedToken)
+			calendarGroup.DELETE("/feed-token", middleware.AuthRequired(), handlers.RevokeCalendarFeedToken)
+			calendarGroup.GET("/:token", handlers.GetCalendarFeed)
+		}
+
+		// 管理路由（需要管理员权限）
+		adminGroup := api.Group("/admin")
+		adminGroup.Use(middleware.AuthRequired(), middleware.AdminRequired())
+		{
+			adminGroup.GET("/jobs", handlers.GetJobs)
+			adminGroup.GET("/jobs/:name/runs", handlers.GetJobRuns)
+			adminGroup.POST("/jobs/:name/trigger", handlers.TriggerJob)
+		}
+
 		// 文件上传路由
 		uploadGroup := api.Group("/upload")
 		uploadGroup.Use(middleware.AuthRequired())
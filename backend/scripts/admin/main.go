@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"course-management-backend/database"
+	"course-management-backend/models"
+)
+
+// 设置或取消用户的管理员权限：go run ./scripts/admin -user 用户名 [-revoke]
+func main() {
+	username := flag.String("user", "", "用户名")
+	revoke := flag.Bool("revoke", false, "取消管理员权限")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("请通过 -user 指定用户名")
+	}
+
+	if err := database.InitDatabase(); err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+
+	result := database.GetDB().Model(&models.User{}).
+		Where("username = ?", *username).
+		Update("is_admin", !*revoke)
+	if result.Error != nil {
+		log.Fatalf("更新用户失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		log.Fatalf("用户 %s 不存在或权限未变化", *username)
+	}
+
+	if *revoke {
+		log.Printf("已取消用户 %s 的管理员权限", *username)
+	} else {
+		log.Printf("已将用户 %s 设为管理员", *username)
+	}
+}
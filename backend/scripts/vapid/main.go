@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"course-management-backend/services"
+)
+
+// 生成Web Push使用的VAPID密钥对，输出可直接写入.env
+func main() {
+	publicKey, privateKey, err := services.GenerateVAPIDKeys()
+	if err != nil {
+		log.Fatalf("生成VAPID密钥失败: %v", err)
+	}
+
+	fmt.Println("# 将以下配置写入 .env")
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\n", publicKey)
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", privateKey)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnsureAttendanceRecord 获取一次课的出勤记录，不存在时创建待上课记录
+// 依赖 (course_id, schedule_date) 唯一约束，并发创建时不会产生重复记录；已删除的记录会被恢复为待上课
+// 返回值 created 表示是否新建（含恢复）
+func EnsureAttendanceRecord(db *gorm.DB, courseID uint, date string) (*models.AttendanceRecord, bool, error) {
+	record := models.AttendanceRecord{
+		CourseID:     courseID,
+		ScheduleDate: date,
+		Status:       models.AttendancePending,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return &record, true, nil
+	}
+
+	// 唯一约束也包含已删除的记录，需一并查询
+	var existing models.AttendanceRecord
+	if err := db.Unscoped().Where("course_id = ? AND schedule_date = ?", courseID, date).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if !existing.DeletedAt.Valid {
+		return &existing, false, nil
+	}
+
+	// 已删除的记录恢复为新的待上课记录
+	restored := db.Unscoped().Model(&models.AttendanceRecord{}).
+		Where("id = ? AND deleted_at IS NOT NULL", existing.ID).
+		Updates(map[string]interface{}{
+			"deleted_at":    nil,
+			"status":        models.AttendancePending,
+			"check_in_time": nil,
+			"notes":         "",
+			"makeup_for_id": nil,
+			"reminder_sent": false,
+		})
+	if restored.Error != nil {
+		return nil, false, restored.Error
+	}
+	if restored.RowsAffected == 0 {
+		// 已被并发恢复
+		if err := db.First(&existing, existing.ID).Error; err != nil {
+			return nil, false, err
+		}
+		return &existing, false, nil
+	}
+
+	record.ID = existing.ID
+	record.CreatedAt = existing.CreatedAt
+	return &record, true, nil
+}
+
+// 出勤状态错误
+var (
+	ErrInvalidAttendanceStatus = errors.New("无效的出勤状态")
+	ErrMakeupRequired          = errors.New("补课需指定对应的缺课记录")
+	ErrInvalidMakeup           = errors.New("补课只能关联同一课程中请假、缺席或机构取消的出勤记录")
+	ErrMakeupExists            = errors.New("该次缺课已安排补课")
+	ErrMakeupReferenced        = errors.New("该次缺课已安排补课，只能改为其他缺课状态")
+)
+
+// SetAttendanceStatus 更新出勤状态并保存，必须在事务中调用
+// 补课需关联同一课程中尚未安排补课的缺课记录；改为不允许消课的状态时退回已消耗的课时，返回被退回的消课记录
+func SetAttendanceStatus(tx *gorm.DB, attendance *models.AttendanceRecord, status string, makeupForID *uint) ([]models.SessionConsumption, error) {
+	if !models.IsValidAttendanceStatus(status) {
+		return nil, ErrInvalidAttendanceStatus
+	}
+
+	// 已被补课关联的缺课记录只能在缺课状态之间修改
+	if !models.IsMissed(status) {
+		var makeups int64
+		if err := tx.Model(&models.AttendanceRecord{}).Where("makeup_for_id = ?", attendance.ID).Count(&makeups).Error; err != nil {
+			return nil, err
+		}
+		if makeups > 0 {
+			return nil, ErrMakeupReferenced
+		}
+	}
+
+	attendance.MakeupForID = nil
+	if status == models.AttendanceMakeup {
+		if makeupForID == nil {
+			return nil, ErrMakeupRequired
+		}
+		var original models.AttendanceRecord
+		if err := tx.Where("id = ? AND course_id = ?", *makeupForID, attendance.CourseID).First(&original).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidMakeup
+			}
+			return nil, err
+		}
+		if original.ID == attendance.ID || !models.IsMissed(original.Status) {
+			return nil, ErrInvalidMakeup
+		}
+
+		var existing int64
+		if err := tx.Model(&models.AttendanceRecord{}).
+			Where("makeup_for_id = ? AND id <> ?", original.ID, attendance.ID).
+			Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			return nil, ErrMakeupExists
+		}
+		attendance.MakeupForID = &original.ID
+	}
+
+	// 不允许消课的状态（待上课、请假、机构取消）退回已消耗的课时
+	var refunded []models.SessionConsumption
+	if !models.CanConsumeSession(status) {
+		if err := tx.Where("attendance_id = ?", attendance.ID).Find(&refunded).Error; err != nil {
+			return nil, err
+		}
+		if len(refunded) > 0 {
+			if err := tx.Where("attendance_id = ?", attendance.ID).Delete(&models.SessionConsumption{}).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	attendance.Status = status
+	if err := tx.Save(attendance).Error; err != nil {
+		return nil, err
+	}
+	return refunded, nil
+}
+
+// ShouldConsumeSession 判断出勤记录是否还需要消课
+// 状态不允许消课或已经消课时不需要；补课对应的缺课已消课时（如缺席已扣课时）不再重复消课
+func ShouldConsumeSession(db *gorm.DB, attendance *models.AttendanceRecord) (bool, error) {
+	if !models.CanConsumeSession(attendance.Status) {
+		return false, nil
+	}
+	consumed, err := HasConsumption(db, attendance.ID)
+	if err != nil || consumed {
+		return false, err
+	}
+	if attendance.Status == models.AttendanceMakeup && attendance.MakeupForID != nil {
+		consumed, err := HasConsumption(db, *attendance.MakeupForID)
+		if err != nil || consumed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// GetConsumptionStats 获取课程的课时消耗与出勤统计
+// 出勤率 = 到课（上课、迟到、补课）/ 已确认的课（不含待上课和机构取消）
+func GetConsumptionStats(db *gorm.DB, course *models.Course) (*models.ConsumptionStats, error) {
+	balance, err := GetCourseBalance(db, course)
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.ConsumptionStats{
+		TotalSessions:     int64(course.GetTotalSessions()),
+		ConsumedSessions:  balance.RegularConsumed + balance.BonusConsumed,
+		RemainingSessions: balance.TotalRemaining(),
+		StatusCounts:      make(map[string]int64),
+		ConsumedByStatus:  make(map[string]int64),
+	}
+
+	var statusRows []struct {
+		Status string
+		Count  int64
+	}
+	if err := db.Model(&models.AttendanceRecord{}).
+		Select("status, COUNT(*) AS count").
+		Where("course_id = ?", course.ID).
+		Group("status").
+		Scan(&statusRows).Error; err != nil {
+		return nil, err
+	}
+	var attended, confirmed int64
+	for _, row := range statusRows {
+		stats.StatusCounts[row.Status] = row.Count
+		if row.Status == models.AttendancePending || row.Status == models.AttendanceCancelledByProvider {
+			continue
+		}
+		confirmed += row.Count
+		if models.IsAttended(row.Status) {
+			attended += row.Count
+		}
+	}
+	if confirmed > 0 {
+		stats.AttendanceRate = int(attended * 100 / confirmed)
+	}
+
+	var consumedRows []struct {
+		Status   *string
+		Consumed int64
+	}
+	if err := db.Table("session_consumptions").
+		Select("attendance_records.status AS status, COALESCE(SUM(session_consumptions.sessions_consumed), 0) AS consumed").
+		Joins("LEFT JOIN attendance_records ON attendance_records.id = session_consumptions.attendance_id AND attendance_records.deleted_at IS NULL").
+		Where("session_consumptions.course_id = ? AND session_consumptions.deleted_at IS NULL", course.ID).
+		Group("attendance_records.status").
+		Scan(&consumedRows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range consumedRows {
+		status := "manual"
+		if row.Status != nil {
+			status = *row.Status
+		}
+		stats.ConsumedByStatus[status] += row.Consumed
+	}
+
+	// 尚未安排补课的缺课
+	if err := db.Model(&models.AttendanceRecord{}).
+		Where("course_id = ? AND status IN ?", course.ID, []string{models.AttendanceAbsent, models.AttendanceNoShow, models.AttendanceCancelledByProvider}).
+		Where("id NOT IN (?)", db.Model(&models.AttendanceRecord{}).
+			Select("makeup_for_id").
+			Where("course_id = ? AND makeup_for_id IS NOT NULL", course.ID)).
+		Count(&stats.PendingMakeups).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"course-management-backend/models"
+	"course-management-backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// attendanceActionTTL 快捷操作token在课程开始后仍可使用的时长
+const attendanceActionTTL = 48 * time.Hour
+
+// 快捷操作错误
+var (
+	ErrActionTokenInvalid      = errors.New("操作链接无效或已过期")
+	ErrActionTokenUsed         = errors.New("该操作链接已使用")
+	ErrAttendanceAlreadyMarked = errors.New("该次课程已记录出勤")
+	ErrAttendanceUnavailable   = errors.New("无法记录出勤")
+)
+
+// AttendanceActionResult 快捷操作的结果
+type AttendanceActionResult struct {
+	Course       models.Course
+	Attendance   models.AttendanceRecord
+	Consumptions []models.SessionConsumption
+}
+
+// IssueAttendanceActionToken 为一次课签发快捷操作token（上课/请假），startsAt 为上课时间
+// 同一次课已有未使用且未过期的token时沿用其记录，多次提醒和重试不会产生新的token记录
+func IssueAttendanceActionToken(db *gorm.DB, userID, courseID uint, date string, startsAt time.Time) (string, error) {
+	var record models.AttendanceActionToken
+	err := db.Where("user_id = ? AND course_id = ? AND schedule_date = ? AND used_at IS NULL AND expires_at > ?",
+		userID, courseID, date, time.Now()).
+		Order("id DESC").
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var tokenID string
+		if tokenID, err = utils.GenerateRandomToken(16); err != nil {
+			return "", err
+		}
+		record = models.AttendanceActionToken{
+			TokenID:      tokenID,
+			UserID:       userID,
+			CourseID:     courseID,
+			ScheduleDate: date,
+			ExpiresAt:    startsAt.Add(attendanceActionTTL),
+		}
+		err = db.Create(&record).Error
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GenerateActionToken(record.TokenID, userID, courseID, date, record.ExpiresAt)
+}
+
+// LookupAttendanceAction 校验token签名并查询对应记录（不消耗token）
+func LookupAttendanceAction(db *gorm.DB, token string) (*models.AttendanceActionToken, error) {
+	claims, err := utils.ValidateActionToken(token)
+	if err != nil {
+		return nil, ErrActionTokenInvalid
+	}
+
+	var record models.AttendanceActionToken
+	if err := db.Where("token_id = ?", claims.ID).First(&record).Error; err != nil {
+		return nil, ErrActionTokenInvalid
+	}
+	// 签名内容必须与签发记录一致
+	if record.UserID != claims.UserID || record.CourseID != claims.CourseID || models.DateOnly(record.ScheduleDate) != claims.Date {
+		return nil, ErrActionTokenInvalid
+	}
+	return &record, nil
+}
+
+// RedeemAttendanceAction 使用快捷操作token更新出勤：attend 签到并消课，absent 请假
+// token 的使用与出勤更新在同一事务中，失败时token仍可再次使用
+func RedeemAttendanceAction(db *gorm.DB, token, action, notes string) (*AttendanceActionResult, error) {
+	record, err := LookupAttendanceAction(db, token)
+	if err != nil {
+		return nil, err
+	}
+	if record.UsedAt != nil {
+		return nil, ErrActionTokenUsed
+	}
+	date := models.DateOnly(record.ScheduleDate)
+
+	result := &AttendanceActionResult{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		claimed := tx.Model(&models.AttendanceActionToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", record.ID, now).
+			Updates(map[string]interface{}{"used_at": now, "used_action": action})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			if record.ExpiresAt.Before(now) {
+				return ErrActionTokenInvalid
+			}
+			return ErrActionTokenUsed
+		}
+
+		course := &result.Course
+		if err := PreloadForOccurrences(tx.Where("id = ? AND user_id = ?", record.CourseID, record.UserID)).
+			First(course).Error; err != nil {
+			return ErrActionTokenInvalid
+		}
+		if err := CheckAttendanceDate(course, date); err != nil {
+			return fmt.Errorf("%w: %v", ErrAttendanceUnavailable, err)
+		}
+
+		record, _, err := EnsureAttendanceRecord(tx, course.ID, date)
+		if err != nil {
+			return err
+		}
+		attendance := &result.Attendance
+		*attendance = *record
+		if attendance.Status != models.AttendancePending {
+			return ErrAttendanceAlreadyMarked
+		}
+
+		if action == "absent" {
+			attendance.TakeLeave(notes)
+			return tx.Save(attendance).Error
+		}
+
+		attendance.CheckIn()
+		checkInTime := attendance.CheckInTime.In(CourseLocation(course, time.Local))
+		attendance.CheckInTime = &checkInTime
+		if notes != "" {
+			attendance.Notes = notes
+		}
+		if err := tx.Save(attendance).Error; err != nil {
+			return err
+		}
+
+		consumed, err := HasConsumption(tx, attendance.ID)
+		if err != nil || consumed {
+			return err
+		}
+		result.Consumptions, err = ConsumeSessionsForAttendance(tx, course, attendance, 1, "通知快捷签到消课")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"course-management-backend/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestIssueAttendanceActionTokenReusesUnusedToken(t *testing.T) {
+	db, mock := newMockDB(t)
+	startsAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	// 第一次提醒签发新token
+	mock.ExpectQuery("SELECT \\* FROM `attendance_action_tokens` WHERE user_id = \\? AND course_id = \\? AND schedule_date = \\? AND used_at IS NULL AND expires_at > \\?").
+		WithArgs(1, 5, "2026-01-05", sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("INSERT INTO `attendance_action_tokens`").WillReturnResult(sqlmock.NewResult(9, 1))
+
+	first, err := IssueAttendanceActionToken(db, 1, 5, "2026-01-05", startsAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ValidateActionToken(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他提前量的提醒和重试沿用同一条记录
+	mock.ExpectQuery("SELECT \\* FROM `attendance_action_tokens`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "user_id", "course_id", "schedule_date", "expires_at"}).
+			AddRow(9, claims.ID, 1, 5, "2026-01-05", startsAt.Add(attendanceActionTTL)))
+
+	second, err := IssueAttendanceActionToken(db, 1, 5, "2026-01-05", startsAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reused, err := utils.ValidateActionToken(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.ID != claims.ID {
+		t.Errorf("token id = %s, want reused %s", reused.ID, claims.ID)
+	}
+	if !reused.ExpiresAt.Equal(claims.ExpiresAt.Time) {
+		t.Errorf("expires at %v, want %v", reused.ExpiresAt, claims.ExpiresAt)
+	}
+}
+
+func TestRedeemAttendanceActionSingleUse(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	token, err := utils.GenerateActionToken("token-1", 1, 5, "2026-01-05", expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		usedAt  interface{}
+		claimed int64 // 领取token时更新的行数
+	}{
+		{name: "already used", usedAt: time.Now().Add(-time.Minute)},
+		{name: "used concurrently", claimed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery("SELECT \\* FROM `attendance_action_tokens` WHERE token_id = \\?").
+				WithArgs("token-1", 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "user_id", "course_id", "schedule_date", "expires_at", "used_at"}).
+					AddRow(9, "token-1", 1, 5, "2026-01-05", expiresAt, tt.usedAt))
+			if tt.usedAt == nil {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE `attendance_action_tokens` SET .* WHERE id = \\? AND used_at IS NULL AND expires_at > \\?").
+					WillReturnResult(sqlmock.NewResult(0, tt.claimed))
+				mock.ExpectRollback()
+			}
+
+			_, err := RedeemAttendanceAction(db, token, "attend", "")
+			if !errors.Is(err, ErrActionTokenUsed) {
+				t.Errorf("err = %v, want %v", err, ErrActionTokenUsed)
+			}
+		})
+	}
+}
+
+func TestRedeemAttendanceActionRejectsMismatchedClaims(t *testing.T) {
+	token, err := utils.GenerateActionToken("token-1", 1, 5, "2026-01-05", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock := newMockDB(t)
+	// 记录属于另一门课程，不能用这个token操作
+	mock.ExpectQuery("SELECT \\* FROM `attendance_action_tokens` WHERE token_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "token_id", "user_id", "course_id", "schedule_date"}).
+			AddRow(9, "token-1", 1, 6, "2026-01-05"))
+
+	if _, err := RedeemAttendanceAction(db, token, "attend", ""); !errors.Is(err, ErrActionTokenInvalid) {
+		t.Errorf("err = %v, want %v", err, ErrActionTokenInvalid)
+	}
+}
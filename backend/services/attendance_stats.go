@@ -0,0 +1,152 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 预计课时用完日期的方式
+const (
+	ProjectionSchedule  = "schedule"  // 按之后的排课，每次课消耗1课时
+	ProjectionAverage   = "average"   // 按近期消课速度
+	ProjectionExhausted = "exhausted" // 课时已用完
+	ProjectionNone      = "none"      // 无排课也无近期消课，无法预计
+)
+
+// projectionYears 按排课预计时最多展开的年数
+const projectionYears = 2
+
+// averageWindowDays 按近期消课速度预计时统计的天数
+const averageWindowDays = 90
+
+// GetAttendanceStats 获取课程出勤统计：出勤率、缺课次数、连续到课、按月汇总及课时预计用完日期
+// course需通过PreloadForOccurrences预加载关联
+func GetAttendanceStats(db *gorm.DB, course *models.Course, now time.Time) (*models.AttendanceStats, error) {
+	consumption, err := GetConsumptionStats(db, course)
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.AttendanceStats{
+		ConsumptionStats: *consumption,
+		AbsenceCount:     consumption.StatusCounts[models.AttendanceAbsent] + consumption.StatusCounts[models.AttendanceNoShow],
+		Monthly:          []models.MonthlyAttendance{},
+	}
+
+	var records []models.AttendanceRecord
+	if err := db.Select("id", "schedule_date", "status").
+		Where("course_id = ?", course.ID).
+		Order("schedule_date ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	var consumptions []models.SessionConsumption
+	if err := db.Select("id", "attendance_id", "sessions_consumed", "created_at").
+		Where("course_id = ?", course.ID).
+		Find(&consumptions).Error; err != nil {
+		return nil, err
+	}
+
+	loc := CourseLocation(course, time.Local)
+	months := make(map[string]*models.MonthlyAttendance)
+	month := func(key string) *models.MonthlyAttendance {
+		if months[key] == nil {
+			months[key] = &models.MonthlyAttendance{Month: key}
+		}
+		return months[key]
+	}
+
+	recordMonths := make(map[uint]string, len(records))
+	for _, record := range records {
+		key := monthKey(models.DateOnly(record.ScheduleDate))
+		recordMonths[record.ID] = key
+		switch {
+		case models.IsAttended(record.Status):
+			month(key).Attended++
+		case record.Status == models.AttendanceAbsent || record.Status == models.AttendanceNoShow:
+			month(key).Absences++
+		}
+	}
+	stats.CurrentStreak, stats.LongestStreak = attendanceStreaks(records)
+
+	// 消课按对应出勤的上课月份统计，手动消课按创建时间
+	for _, item := range consumptions {
+		key := item.CreatedAt.In(loc).Format("2006-01")
+		if item.AttendanceID != nil && recordMonths[*item.AttendanceID] != "" {
+			key = recordMonths[*item.AttendanceID]
+		}
+		month(key).SessionsConsumed += int64(item.SessionsConsumed)
+	}
+
+	for _, item := range months {
+		stats.Monthly = append(stats.Monthly, *item)
+	}
+	sort.Slice(stats.Monthly, func(i, j int) bool { return stats.Monthly[i].Month < stats.Monthly[j].Month })
+
+	projectExhaustion(course, stats, consumptions, now.In(loc))
+	return stats, nil
+}
+
+// attendanceStreaks 按上课日期顺序（records已排序）统计当前和最长连续到课次数
+// 机构取消和待上课不中断连续，请假和缺席中断连续
+func attendanceStreaks(records []models.AttendanceRecord) (current, longest int) {
+	for _, record := range records {
+		switch {
+		case models.IsAttended(record.Status):
+			current++
+			if current > longest {
+				longest = current
+			}
+		case record.Status == models.AttendanceAbsent || record.Status == models.AttendanceNoShow:
+			current = 0
+		}
+	}
+	return current, longest
+}
+
+// projectExhaustion 预计剩余课时用完的日期
+func projectExhaustion(course *models.Course, stats *models.AttendanceStats, consumptions []models.SessionConsumption, now time.Time) {
+	remaining := stats.RemainingSessions
+	if remaining <= 0 {
+		stats.ProjectionMethod = ProjectionExhausted
+		return
+	}
+
+	// 之后的每次课（不含机构取消）消耗1课时
+	occurrences := ExpandOccurrences([]models.Course{*course}, now, now.AddDate(projectionYears, 0, 0))
+	if int64(len(occurrences)) >= remaining {
+		date := occurrences[remaining-1].Date
+		stats.ProjectedExhaustionDate = &date
+		stats.ProjectionMethod = ProjectionSchedule
+		return
+	}
+
+	// 排课不足以用完时按近期消课速度估算
+	since := now.AddDate(0, 0, -averageWindowDays)
+	recent := int64(0)
+	for _, item := range consumptions {
+		if item.CreatedAt.After(since) {
+			recent += int64(item.SessionsConsumed)
+		}
+	}
+	if recent == 0 {
+		stats.ProjectionMethod = ProjectionNone
+		return
+	}
+	days := int(math.Ceil(float64(remaining) * averageWindowDays / float64(recent)))
+	date := now.AddDate(0, 0, days).Format("2006-01-02")
+	stats.ProjectedExhaustionDate = &date
+	stats.ProjectionMethod = ProjectionAverage
+}
+
+// monthKey 日期对应的月份 YYYY-MM
+func monthKey(date string) string {
+	if len(date) < 7 {
+		return date
+	}
+	return date[:7]
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func TestAttendanceStreaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		current  int
+		longest  int
+	}{
+		{"no records", nil, 0, 0},
+		{"all attended", []string{models.AttendanceAttend, models.AttendanceLate, models.AttendanceMakeup}, 3, 3},
+		{"absence resets", []string{models.AttendanceAttend, models.AttendanceAttend, models.AttendanceAbsent, models.AttendanceAttend}, 1, 2},
+		{"no show resets", []string{models.AttendanceAttend, models.AttendanceNoShow}, 0, 1},
+		{"provider cancellation keeps streak", []string{models.AttendanceAttend, models.AttendanceCancelledByProvider, models.AttendanceAttend}, 2, 2},
+		{"pending keeps streak", []string{models.AttendanceAttend, models.AttendancePending, models.AttendanceLate}, 2, 2},
+		{"longest in the middle", []string{models.AttendanceAttend, models.AttendanceAttend, models.AttendanceAttend, models.AttendanceAbsent, models.AttendanceAttend}, 1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([]models.AttendanceRecord, 0, len(tt.statuses))
+			for _, status := range tt.statuses {
+				records = append(records, models.AttendanceRecord{Status: status})
+			}
+			current, longest := attendanceStreaks(records)
+			if current != tt.current || longest != tt.longest {
+				t.Errorf("streaks = %d/%d, want %d/%d", current, longest, tt.current, tt.longest)
+			}
+		})
+	}
+}
+
+func TestProjectExhaustion(t *testing.T) {
+	// 2026-01-05 为周一，课程每周一18:00上课
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	weekly := models.CourseSchedule{Weekday: 1, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2026-01-05")}
+	recent := []models.SessionConsumption{
+		{SessionsConsumed: 5, CreatedAt: now.AddDate(0, 0, -10)},
+		{SessionsConsumed: 4, CreatedAt: now.AddDate(0, 0, -80)},
+		{SessionsConsumed: 20, CreatedAt: now.AddDate(0, 0, -100)},
+	}
+
+	tests := []struct {
+		name         string
+		course       models.Course
+		remaining    int64
+		consumptions []models.SessionConsumption
+		method       string
+		date         string
+	}{
+		{
+			name:      "exhausted",
+			course:    models.Course{Schedules: []models.CourseSchedule{weekly}},
+			remaining: 0,
+			method:    ProjectionExhausted,
+		},
+		{
+			name:      "by schedule",
+			course:    models.Course{Schedules: []models.CourseSchedule{weekly}},
+			remaining: 3,
+			method:    ProjectionSchedule,
+			date:      "2026-01-19",
+		},
+		{
+			name: "cancelled classes are not counted",
+			course: models.Course{
+				Schedules: []models.CourseSchedule{weekly},
+				Overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-12", OriginalStartTime: "18:00", Action: models.OverrideCancel}},
+			},
+			remaining: 2,
+			method:    ProjectionSchedule,
+			date:      "2026-01-19",
+		},
+		{
+			// 排课只到01-12，按近90天消耗9课时的速度：5课时需50天
+			name:         "by recent average",
+			course:       models.Course{Schedules: []models.CourseSchedule{withEndDate(weekly, "2026-01-12")}},
+			remaining:    5,
+			consumptions: recent,
+			method:       ProjectionAverage,
+			date:         "2026-02-24",
+		},
+		{
+			name:         "no schedule and no recent consumption",
+			course:       models.Course{},
+			remaining:    5,
+			consumptions: recent[2:],
+			method:       ProjectionNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &models.AttendanceStats{ConsumptionStats: models.ConsumptionStats{RemainingSessions: tt.remaining}}
+			projectExhaustion(&tt.course, stats, tt.consumptions, now)
+			if stats.ProjectionMethod != tt.method {
+				t.Errorf("method = %s, want %s", stats.ProjectionMethod, tt.method)
+			}
+			date := ""
+			if stats.ProjectedExhaustionDate != nil {
+				date = *stats.ProjectedExhaustionDate
+			}
+			if date != tt.date {
+				t.Errorf("date = %q, want %q", date, tt.date)
+			}
+		})
+	}
+}
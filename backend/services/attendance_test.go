@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSetAttendanceStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     string
+		status   string
+		makeups  int   // 关联到这条记录的补课数
+		consumed []int // 这条记录上已有的消课记录ID
+		refunded int
+		err      error
+	}{
+		{name: "late keeps consumption", from: models.AttendanceAttend, status: models.AttendanceLate, consumed: []int{7}},
+		{name: "no show keeps consumption", from: models.AttendanceAttend, status: models.AttendanceNoShow, consumed: []int{7}},
+		{name: "absent refunds", from: models.AttendanceAttend, status: models.AttendanceAbsent, consumed: []int{7}, refunded: 1},
+		{name: "provider cancellation refunds", from: models.AttendanceLate, status: models.AttendanceCancelledByProvider, consumed: []int{7, 8}, refunded: 2},
+		{name: "back to pending refunds", from: models.AttendanceAttend, status: models.AttendancePending, consumed: []int{7}, refunded: 1},
+		{name: "nothing to refund", from: models.AttendancePending, status: models.AttendanceAbsent},
+		{name: "invalid status", from: models.AttendancePending, status: "present", err: ErrInvalidAttendanceStatus},
+		{name: "referenced by a makeup", from: models.AttendanceAbsent, status: models.AttendanceAttend, makeups: 1, err: ErrMakeupReferenced},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			attendance := &models.AttendanceRecord{ID: 42, CourseID: 5, ScheduleDate: "2026-01-05", Status: tt.from}
+
+			if tt.err != ErrInvalidAttendanceStatus && !models.IsMissed(tt.status) {
+				mock.ExpectQuery("SELECT count\\(\\*\\) FROM `attendance_records` WHERE makeup_for_id = \\?").
+					WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.makeups))
+			}
+			if tt.err == nil {
+				if !models.CanConsumeSession(tt.status) {
+					rows := sqlmock.NewRows([]string{"id", "course_id", "attendance_id", "sessions_consumed", "session_type"})
+					for _, id := range tt.consumed {
+						rows.AddRow(id, 5, 42, 1, "regular")
+					}
+					mock.ExpectQuery("SELECT \\* FROM `session_consumptions` WHERE attendance_id = \\?").WithArgs(42).WillReturnRows(rows)
+					if len(tt.consumed) > 0 {
+						mock.ExpectExec("UPDATE `session_consumptions` SET `deleted_at`=\\? WHERE attendance_id = \\?").
+							WithArgs(sqlmock.AnyArg(), 42).
+							WillReturnResult(sqlmock.NewResult(0, int64(len(tt.consumed))))
+					}
+				}
+				mock.ExpectExec("UPDATE `attendance_records` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			refunded, err := SetAttendanceStatus(db, attendance, tt.status, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(refunded) != tt.refunded {
+				t.Errorf("refunded = %d, want %d", len(refunded), tt.refunded)
+			}
+			if tt.err == nil && attendance.Status != tt.status {
+				t.Errorf("status = %s, want %s", attendance.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestEnsureAttendanceRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		inserted  int64
+		deletedAt interface{} // 已有记录的删除时间
+		restored  int64
+		created   bool
+		status    string
+	}{
+		{name: "creates missing record", inserted: 1, created: true, status: models.AttendancePending},
+		{name: "returns existing record", status: models.AttendanceAttend},
+		{name: "restores deleted record", deletedAt: time.Now(), restored: 1, created: true, status: models.AttendancePending},
+		{name: "deleted record restored concurrently", deletedAt: time.Now(), status: models.AttendancePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectExec("INSERT INTO `attendance_records` .* ON DUPLICATE KEY UPDATE").
+				WillReturnResult(sqlmock.NewResult(42, tt.inserted))
+			if tt.inserted == 0 {
+				mock.ExpectQuery("SELECT \\* FROM `attendance_records` WHERE course_id = \\? AND schedule_date = \\? ORDER BY").
+					WithArgs(5, "2026-01-05", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "course_id", "schedule_date", "status", "deleted_at"}).
+						AddRow(42, 5, "2026-01-05", models.AttendanceAttend, tt.deletedAt))
+			}
+			if tt.deletedAt != nil {
+				mock.ExpectExec("UPDATE `attendance_records` SET .*`deleted_at`=\\?.* WHERE id = \\? AND deleted_at IS NOT NULL").
+					WillReturnResult(sqlmock.NewResult(0, tt.restored))
+				if tt.restored == 0 {
+					mock.ExpectQuery("SELECT \\* FROM `attendance_records` WHERE `attendance_records`.`id` = \\? AND `attendance_records`.`deleted_at` IS NULL").
+						WillReturnRows(sqlmock.NewRows([]string{"id", "course_id", "schedule_date", "status"}).
+							AddRow(42, 5, "2026-01-05", models.AttendancePending))
+				}
+			}
+
+			record, created, err := EnsureAttendanceRecord(db, 5, "2026-01-05")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created != tt.created {
+				t.Errorf("created = %v, want %v", created, tt.created)
+			}
+			if record.ID != 42 || record.Status != tt.status {
+				t.Errorf("record = %d %s, want 42 %s", record.ID, record.Status, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// consumedByType 按课程和课时类型汇总的消耗数
+type consumedByType struct {
+	CourseID    uint
+	SessionType string
+	Consumed    int64
+}
+
+// GetCourseBalance 获取单个课程的分池课时余额
+func GetCourseBalance(db *gorm.DB, course *models.Course) (models.CourseBalance, error) {
+	balances, err := GetCourseBalances(db, []models.Course{*course})
+	if err != nil {
+		return models.CourseBalance{}, err
+	}
+	return balances[course.ID], nil
+}
+
+// GetCourseBalances 批量获取课程的分池课时余额，一次查询完成汇总
+func GetCourseBalances(db *gorm.DB, courses []models.Course) (map[uint]models.CourseBalance, error) {
+	balances := make(map[uint]models.CourseBalance, len(courses))
+	if len(courses) == 0 {
+		return balances, nil
+	}
+
+	courseIDs := make([]uint, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+
+	var rows []consumedByType
+	err := db.Model(&models.SessionConsumption{}).
+		Select("course_id, session_type, COALESCE(SUM(sessions_consumed), 0) AS consumed").
+		Where("course_id IN ?", courseIDs).
+		Group("course_id, session_type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := make(map[uint]map[string]int64, len(courses))
+	for _, row := range rows {
+		if consumed[row.CourseID] == nil {
+			consumed[row.CourseID] = make(map[string]int64)
+		}
+		consumed[row.CourseID][row.SessionType] = row.Consumed
+	}
+
+	for _, course := range courses {
+		balance := models.CourseBalance{
+			RegularTotal:    int64(course.RegularSessions),
+			RegularConsumed: consumed[course.ID]["regular"],
+			BonusTotal:      int64(course.BonusSessions),
+			BonusConsumed:   consumed[course.ID]["bonus"],
+		}
+		balance.RegularRemaining = clampZero(balance.RegularTotal - balance.RegularConsumed)
+		balance.BonusRemaining = clampZero(balance.BonusTotal - balance.BonusConsumed)
+		balances[course.ID] = balance
+	}
+
+	return balances, nil
+}
+
+// CheckPoolBalance 检查指定课时池是否足够扣除
+func CheckPoolBalance(balance models.CourseBalance, sessionType string, sessions int) error {
+	remaining := balance.Remaining(sessionType)
+	if int64(sessions) > remaining {
+		return fmt.Errorf("%w：%s剩余 %d 节，本次需要 %d 节", ErrInsufficientSessions, sessionTypeText(sessionType), remaining, sessions)
+	}
+	return nil
+}
+
+// sessionTypeText 获取课时类型描述
+func sessionTypeText(sessionType string) string {
+	consumption := models.SessionConsumption{SessionType: sessionType}
+	return consumption.GetSessionTypeText()
+}
+
+// clampZero 负数按0处理
+func clampZero(n int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
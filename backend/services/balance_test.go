@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// expectConsumedByType 预期一次按课时类型汇总消耗的查询
+func expectConsumedByType(mock sqlmock.Sqlmock, courseID uint, regular, bonus int64) {
+	rows := sqlmock.NewRows([]string{"course_id", "session_type", "consumed"})
+	if regular > 0 {
+		rows.AddRow(courseID, "regular", regular)
+	}
+	if bonus > 0 {
+		rows.AddRow(courseID, "bonus", bonus)
+	}
+	mock.ExpectQuery("SELECT course_id, session_type, COALESCE\\(SUM\\(sessions_consumed\\), 0\\) AS consumed FROM `session_consumptions`").
+		WillReturnRows(rows)
+}
+
+func TestGetCourseBalances(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery("SELECT course_id, session_type, .* GROUP BY course_id, session_type").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"course_id", "session_type", "consumed"}).
+			AddRow(1, "regular", 4).
+			AddRow(1, "bonus", 1).
+			AddRow(2, "regular", 12))
+
+	courses := []models.Course{
+		{ID: 1, RegularSessions: 10, BonusSessions: 2},
+		{ID: 2, RegularSessions: 10, BonusSessions: 0},
+		{ID: 3, RegularSessions: 8, BonusSessions: 1},
+	}
+	balances, err := GetCourseBalances(db, courses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint]models.CourseBalance{
+		1: {RegularTotal: 10, RegularConsumed: 4, RegularRemaining: 6, BonusTotal: 2, BonusConsumed: 1, BonusRemaining: 1},
+		// 超扣的正式课时剩余按0计
+		2: {RegularTotal: 10, RegularConsumed: 12, RegularRemaining: 0},
+		3: {RegularTotal: 8, RegularRemaining: 8, BonusTotal: 1, BonusRemaining: 1},
+	}
+	for id, balance := range want {
+		if balances[id] != balance {
+			t.Errorf("course %d balance = %+v, want %+v", id, balances[id], balance)
+		}
+	}
+}
+
+func TestCheckPoolBalance(t *testing.T) {
+	balance := models.CourseBalance{RegularRemaining: 2, BonusRemaining: 0}
+	tests := []struct {
+		sessionType string
+		sessions    int
+		ok          bool
+	}{
+		{"regular", 2, true},
+		{"regular", 3, false},
+		{"bonus", 1, false},
+		{"bonus", 0, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckPoolBalance(balance, tt.sessionType, tt.sessions)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckPoolBalance(%s, %d) = %v, want ok %v", tt.sessionType, tt.sessions, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInsufficientSessions) {
+			t.Errorf("err = %v, want ErrInsufficientSessions", err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientSessions 剩余课时不足
+var ErrInsufficientSessions = errors.New("剩余课时不足")
+
+// ConsumeSessionsForAttendance 为出勤记录消课，先扣正式课时，再扣赠送课时
+// 必须在事务中调用，课程行会被加锁以避免并发超扣
+func ConsumeSessionsForAttendance(tx *gorm.DB, course *models.Course, attendance *models.AttendanceRecord, sessions int, description string) ([]models.SessionConsumption, error) {
+	if sessions <= 0 {
+		return nil, fmt.Errorf("消耗课时数必须大于0")
+	}
+
+	if err := ensureNotCancelled(tx, attendance); err != nil {
+		return nil, err
+	}
+
+	locked, balance, err := lockCourseBalance(tx, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	if int64(sessions) > balance.TotalRemaining() {
+		return nil, ErrInsufficientSessions
+	}
+
+	// 按池拆分：正式课时优先
+	fromRegular := int64(sessions)
+	if fromRegular > balance.RegularRemaining {
+		fromRegular = balance.RegularRemaining
+	}
+	fromBonus := int64(sessions) - fromRegular
+
+	attendanceID := attendance.ID
+	var consumptions []models.SessionConsumption
+	for _, part := range []struct {
+		sessionType string
+		count       int64
+	}{
+		{"regular", fromRegular},
+		{"bonus", fromBonus},
+	} {
+		if part.count == 0 {
+			continue
+		}
+		consumption := models.SessionConsumption{
+			CourseID:         locked.ID,
+			AttendanceID:     &attendanceID,
+			SessionsConsumed: int(part.count),
+			SessionType:      part.sessionType,
+			Description:      description,
+		}
+		if err := tx.Create(&consumption).Error; err != nil {
+			return nil, err
+		}
+		consumptions = append(consumptions, consumption)
+	}
+
+	return consumptions, nil
+}
+
+// ConsumeFromPool 从指定课时池消课，余额不足时返回ErrInsufficientSessions
+// 必须在事务中调用
+func ConsumeFromPool(tx *gorm.DB, attendance *models.AttendanceRecord, sessionType string, sessions int, description string) (*models.SessionConsumption, error) {
+	if err := ensureNotCancelled(tx, attendance); err != nil {
+		return nil, err
+	}
+
+	courseID := attendance.CourseID
+	attendanceID := attendance.ID
+	_, balance, err := lockCourseBalance(tx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckPoolBalance(balance, sessionType, sessions); err != nil {
+		return nil, err
+	}
+
+	consumption := models.SessionConsumption{
+		CourseID:         courseID,
+		AttendanceID:     &attendanceID,
+		SessionsConsumed: sessions,
+		SessionType:      sessionType,
+		Description:      description,
+	}
+	if err := tx.Create(&consumption).Error; err != nil {
+		return nil, err
+	}
+	return &consumption, nil
+}
+
+// ensureNotCancelled 机构取消的课不能消耗课时
+func ensureNotCancelled(tx *gorm.DB, attendance *models.AttendanceRecord) error {
+	cancelled, err := IsOccurrenceCancelled(tx, attendance.CourseID, attendance.ScheduleDate)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return ErrOccurrenceCancelled
+	}
+	return nil
+}
+
+// lockCourseBalance 锁定课程行并读取分池余额
+func lockCourseBalance(tx *gorm.DB, courseID uint) (*models.Course, models.CourseBalance, error) {
+	var course models.Course
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&course, courseID).Error; err != nil {
+		return nil, models.CourseBalance{}, err
+	}
+	balance, err := GetCourseBalance(tx, &course)
+	if err != nil {
+		return nil, models.CourseBalance{}, err
+	}
+	return &course, balance, nil
+}
+
+// HasConsumption 检查出勤记录是否已经消课
+func HasConsumption(db *gorm.DB, attendanceID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.SessionConsumption{}).Where("attendance_id = ?", attendanceID).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestConsumeSessionsForAttendance(t *testing.T) {
+	tests := []struct {
+		name            string
+		regularConsumed int64
+		bonusConsumed   int64
+		sessions        int
+		want            []string // 依次写入的 课时类型:数量
+		err             error
+	}{
+		{"regular first", 3, 0, 1, []string{"regular:1"}, nil},
+		{"bonus after regular is used up", 10, 0, 1, []string{"bonus:1"}, nil},
+		{"split across pools", 9, 0, 2, []string{"regular:1", "bonus:1"}, nil},
+		{"over consumed regular pool", 12, 1, 1, []string{"bonus:1"}, nil},
+		{"insufficient", 10, 2, 1, nil, ErrInsufficientSessions},
+		{"more than both pools", 8, 0, 5, nil, ErrInsufficientSessions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			expectNotCancelled(mock)
+			mock.ExpectQuery("SELECT \\* FROM `courses` WHERE `courses`.`id` = \\? .*FOR UPDATE").
+				WithArgs(5, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "regular_sessions", "bonus_sessions"}).AddRow(5, 10, 2))
+			expectConsumedByType(mock, 5, tt.regularConsumed, tt.bonusConsumed)
+			for i := range tt.want {
+				mock.ExpectExec("INSERT INTO `session_consumptions`").
+					WillReturnResult(sqlmock.NewResult(int64(100+i), 1))
+			}
+
+			course := &models.Course{ID: 5}
+			attendance := &models.AttendanceRecord{ID: 42, CourseID: 5}
+			consumptions, err := ConsumeSessionsForAttendance(db, course, attendance, tt.sessions, "签到自动消课")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			var got []string
+			for _, consumption := range consumptions {
+				got = append(got, consumption.SessionType+":"+strconv.Itoa(consumption.SessionsConsumed))
+				if consumption.AttendanceID == nil || *consumption.AttendanceID != 42 {
+					t.Errorf("attendanceId = %v, want 42", consumption.AttendanceID)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("consumptions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// expectNotCancelled 预期一次查询该次课的取消记录，没有取消记录时不再展开排课
+func expectNotCancelled(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `occurrence_overrides`").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"course-management-backend/config"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CourseLowSessionThreshold 课程的课时不足阈值，未设置时使用LOW_SESSION_THRESHOLD（默认3）
+func CourseLowSessionThreshold(course *models.Course) int64 {
+	if course.LowSessionThreshold != nil {
+		return int64(*course.LowSessionThreshold)
+	}
+	return int64(config.GetEnvInt("LOW_SESSION_THRESHOLD", 3))
+}
+
+// CheckCourseAlerts 检查活跃课程的剩余课时和到期日，剩余课时低于阈值或课包在COURSE_EXPIRY_ALERT_DAYS天内到期时提醒用户
+// 同一课程同一条件只提醒一次，返回发送的提醒数
+func CheckCourseAlerts(db *gorm.DB, now time.Time) (int, error) {
+	var courses []models.Course
+	if err := db.Preload("User").Where("is_active = ?", true).Find(&courses).Error; err != nil {
+		return 0, fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	// 与 Course.GetRemainingSessions 相同的分池计算，批量查询
+	balances, err := GetCourseBalances(db, courses)
+	if err != nil {
+		return 0, fmt.Errorf("查询课时余额失败: %v", err)
+	}
+	expiryDays := config.GetEnvInt("COURSE_EXPIRY_ALERT_DAYS", 7)
+
+	sent := 0
+	for i := range courses {
+		course := &courses[i]
+		total := int64(course.GetTotalSessions())
+		remaining := balances[course.ID].TotalRemaining()
+		for _, alert := range courseAlertConditions(course, total, remaining, now, expiryDays) {
+			if raiseCourseAlert(db, course, alert.Kind, alert.ConditionKey, alert.Message) {
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
+
+// courseAlertCondition 课程当前满足的一个提醒条件
+type courseAlertCondition struct {
+	Kind         string
+	ConditionKey string // 同一课程同一条件只提醒一次
+	Message      string
+}
+
+// courseAlertConditions 判断课程当前满足的提醒条件
+func courseAlertConditions(course *models.Course, total, remaining int64, now time.Time, expiryDays int) []courseAlertCondition {
+	var conditions []courseAlertCondition
+
+	// 课时不足：课时总数或阈值变化（如续费）后视为新的条件
+	threshold := CourseLowSessionThreshold(course)
+	if total > 0 && remaining < threshold {
+		message := fmt.Sprintf("%s 剩余 %d 课时", course.Name, remaining)
+		if remaining == 0 {
+			message = fmt.Sprintf("%s 课时已用完", course.Name)
+		}
+		conditions = append(conditions, courseAlertCondition{models.CourseAlertLowBalance, fmt.Sprintf("%d/%d", total, threshold), message})
+	}
+
+	// 即将到期：只提醒仍有剩余课时的课包，按用户时区计算剩余天数
+	if course.ExpiresOn == nil || remaining == 0 || expiryDays <= 0 {
+		return conditions
+	}
+	expiresOn := models.DateOnly(*course.ExpiresOn)
+	expiry, err := time.Parse("2006-01-02", expiresOn)
+	if err != nil {
+		return conditions
+	}
+	local := now.In(CourseLocation(course, time.Local))
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	daysLeft := int(expiry.Sub(today).Hours() / 24)
+	if daysLeft < 0 || daysLeft > expiryDays {
+		return conditions
+	}
+	message := fmt.Sprintf("%s 将于 %s 到期，还剩 %d 课时", course.Name, expiresOn, remaining)
+	if daysLeft == 0 {
+		message = fmt.Sprintf("%s 今天到期，还剩 %d 课时", course.Name, remaining)
+	}
+	return append(conditions, courseAlertCondition{models.CourseAlertExpiring, expiresOn, message})
+}
+
+// raiseCourseAlert 记录并发送课程提醒，同一条件已提醒过时返回false
+func raiseCourseAlert(db *gorm.DB, course *models.Course, kind, conditionKey, message string) bool {
+	alert := models.CourseAlert{
+		CourseID:     course.ID,
+		UserID:       course.UserID,
+		Kind:         kind,
+		ConditionKey: conditionKey,
+		Message:      truncateText(message, 255),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+	if result.Error != nil {
+		log.Printf("记录课程提醒失败 (课程ID: %d): %v", course.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	if err := SendCourseAlert(course, &alert); err != nil {
+		log.Printf("发送课程提醒失败 (课程ID: %d): %v", course.ID, err)
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCourseAlertConditions(t *testing.T) {
+	threshold := 3
+	// 上海时间已是1月10日
+	now := time.Date(2026, 1, 9, 17, 0, 0, 0, time.UTC)
+	expiresOn := func(date string) *string { return &date }
+
+	tests := []struct {
+		name       string
+		remaining  int64
+		expiresOn  *string
+		conditions string
+	}{
+		{name: "at threshold", remaining: 3},
+		{name: "below threshold", remaining: 2, conditions: "low_balance 10/3"},
+		{name: "exhausted", remaining: 0, expiresOn: expiresOn("2026-01-12"), conditions: "low_balance 10/3"},
+		{name: "expires within alert days", remaining: 5, expiresOn: expiresOn("2026-01-17"), conditions: "expiring 2026-01-17"},
+		{name: "expires today in user timezone", remaining: 5, expiresOn: expiresOn("2026-01-10"), conditions: "expiring 2026-01-10"},
+		{name: "expires later", remaining: 5, expiresOn: expiresOn("2026-01-18")},
+		{name: "already expired", remaining: 5, expiresOn: expiresOn("2026-01-09")},
+		{name: "low and expiring", remaining: 1, expiresOn: expiresOn("2026-01-12"), conditions: "low_balance 10/3,expiring 2026-01-12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			course := &models.Course{
+				Name:                "钢琴课",
+				ExpiresOn:           tt.expiresOn,
+				LowSessionThreshold: &threshold,
+				User:                models.User{ID: 1, Timezone: "Asia/Shanghai"},
+			}
+			var got []string
+			for _, condition := range courseAlertConditions(course, 10, tt.remaining, now, 7) {
+				got = append(got, condition.Kind+" "+condition.ConditionKey)
+			}
+			if strings.Join(got, ",") != tt.conditions {
+				t.Errorf("conditions = %v, want %s", got, tt.conditions)
+			}
+		})
+	}
+}
+
+func TestRaiseCourseAlertOncePerCondition(t *testing.T) {
+	db, mock := newMockDB(t)
+	course := &models.Course{ID: 5, UserID: 1, Name: "钢琴课"}
+
+	// 同一条件已记录过，不再发送
+	mock.ExpectExec("INSERT INTO `course_alerts` .* ON DUPLICATE KEY UPDATE").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if raiseCourseAlert(db, course, models.CourseAlertLowBalance, "10/3", "钢琴课 剩余 2 课时") {
+		t.Error("alert raised twice for the same condition")
+	}
+}
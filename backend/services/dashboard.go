@@ -0,0 +1,217 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// dashboardUpcomingDays 即将上课最多展开的天数
+const dashboardUpcomingDays = 60
+
+// DashboardOccurrence 首页中的一次课
+type DashboardOccurrence struct {
+	CourseID          uint      `json:"courseId"`
+	CourseName        string    `json:"courseName"`
+	Date              string    `json:"date"`
+	StartTime         string    `json:"startTime"`
+	EndTime           string    `json:"endTime"`
+	StartsAt          time.Time `json:"startsAt"`
+	Location          string    `json:"location"`
+	Instructor        string    `json:"instructor"`
+	Cancelled         bool      `json:"cancelled"`
+	Rescheduled       bool      `json:"rescheduled"`
+	AttendanceID      *uint     `json:"attendanceId"`
+	AttendanceStatus  string    `json:"attendanceStatus"`
+	StatusText        string    `json:"statusText"`
+	RemainingSessions int64     `json:"remainingSessions"`
+}
+
+// LowBalanceCourse 课时不足的课程
+type LowBalanceCourse struct {
+	CourseID          uint                 `json:"courseId"`
+	CourseName        string               `json:"courseName"`
+	RemainingSessions int64                `json:"remainingSessions"`
+	Threshold         int64                `json:"threshold"`
+	Balance           models.CourseBalance `json:"balance"`
+}
+
+// MonthAttendance 本月出勤汇总
+type MonthAttendance struct {
+	Month          string `json:"month"` // YYYY-MM
+	Attended       int64  `json:"attended"`
+	Missed         int64  `json:"missed"`
+	Pending        int64  `json:"pending"`
+	AttendanceRate int    `json:"attendanceRate"` // 到课 / 已确认的课（不含机构取消）
+}
+
+// Dashboard 首页汇总
+type Dashboard struct {
+	Today               []DashboardOccurrence `json:"today"`
+	Upcoming            []DashboardOccurrence `json:"upcoming"`
+	LowBalance          []LowBalanceCourse    `json:"lowBalance"`
+	LowBalanceThreshold *int64                `json:"lowBalanceThreshold"` // 请求指定的阈值，为null时按各课程的阈值
+	RemainingValue      float64               `json:"remainingValue"` // 活跃课程剩余预付价值合计
+	ActiveCourses       int                   `json:"activeCourses"`
+	Month               MonthAttendance       `json:"month"`
+}
+
+// BuildDashboard 汇总用户首页数据：今日课程及出勤状态、之后的upcomingLimit次课、剩余课时低于阈值的课程、
+// 剩余预付价值和本月出勤率；threshold为nil时使用各课程的课时不足阈值，日期按loc计算
+func BuildDashboard(db *gorm.DB, userID uint, now time.Time, loc *time.Location, upcomingLimit int, threshold *int64) (*Dashboard, error) {
+	now = now.In(loc)
+	dashboard := &Dashboard{
+		Today:               []DashboardOccurrence{},
+		Upcoming:            []DashboardOccurrence{},
+		LowBalance:          []LowBalanceCourse{},
+		LowBalanceThreshold: threshold,
+	}
+
+	courses, err := loadActiveCourses(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	dashboard.ActiveCourses = len(courses)
+
+	balances, err := GetCourseBalances(db, courses)
+	if err != nil {
+		return nil, err
+	}
+
+	// 今日（含已取消，便于展示）与之后的课
+	dayStart, dayEnd := DayRange(now)
+	today := ExpandAllOccurrences(courses, dayStart, dayEnd)
+	upcoming := ExpandOccurrences(courses, now, dayStart.AddDate(0, 0, dashboardUpcomingDays))
+	if len(upcoming) > upcomingLimit {
+		upcoming = upcoming[:upcomingLimit]
+	}
+
+	// 一次查询涵盖今日和之后的出勤记录（排课时区不同时日期可能相差一天）
+	records := make(map[string]models.AttendanceRecord)
+	if len(courses) > 0 && (len(today) > 0 || len(upcoming) > 0) {
+		to := dayEnd
+		if len(upcoming) > 0 && upcoming[len(upcoming)-1].Start.After(to) {
+			to = upcoming[len(upcoming)-1].Start
+		}
+		courseIDs := make([]uint, 0, len(courses))
+		for _, course := range courses {
+			courseIDs = append(courseIDs, course.ID)
+		}
+		var attendances []models.AttendanceRecord
+		if err := db.Where("course_id IN ? AND schedule_date >= ? AND schedule_date <= ?", courseIDs,
+			dayStart.AddDate(0, 0, -1).Format("2006-01-02"), to.AddDate(0, 0, 1).Format("2006-01-02")).
+			Find(&attendances).Error; err != nil {
+			return nil, err
+		}
+		for _, attendance := range attendances {
+			records[dashboardKey(attendance.CourseID, models.DateOnly(attendance.ScheduleDate))] = attendance
+		}
+	}
+
+	build := func(occurrence Occurrence) DashboardOccurrence {
+		item := DashboardOccurrence{
+			CourseID:          occurrence.Course.ID,
+			CourseName:        occurrence.Course.Name,
+			Date:              occurrence.Date,
+			StartTime:         occurrence.Start.Format("15:04"),
+			EndTime:           occurrence.End.Format("15:04"),
+			StartsAt:          occurrence.Start,
+			Location:          occurrence.Schedule.Location,
+			Instructor:        occurrence.Schedule.Instructor,
+			Cancelled:         occurrence.Cancelled(),
+			Rescheduled:       occurrence.Rescheduled(),
+			AttendanceStatus:  models.AttendancePending,
+			RemainingSessions: balances[occurrence.Course.ID].TotalRemaining(),
+		}
+		if occurrence.Override != nil && occurrence.Override.Location != "" {
+			item.Location = occurrence.Override.Location
+		}
+		record, exists := records[dashboardKey(occurrence.Course.ID, occurrence.Date)]
+		if exists {
+			item.AttendanceID = &record.ID
+			item.AttendanceStatus = record.Status
+		}
+		item.StatusText = (&models.AttendanceRecord{Status: item.AttendanceStatus}).GetStatusText()
+		return item
+	}
+	for _, occurrence := range today {
+		dashboard.Today = append(dashboard.Today, build(occurrence))
+	}
+	for _, occurrence := range upcoming {
+		dashboard.Upcoming = append(dashboard.Upcoming, build(occurrence))
+	}
+
+	// 课时不足的课程与剩余预付价值
+	for i := range courses {
+		course := &courses[i]
+		balance := balances[course.ID]
+		dashboard.RemainingValue += ValueCourse(course, balance, RefundRuleRegularFirst, 0).RemainingValue
+		courseThreshold := CourseLowSessionThreshold(course)
+		if threshold != nil {
+			courseThreshold = *threshold
+		}
+		if balance.TotalRemaining() < courseThreshold {
+			dashboard.LowBalance = append(dashboard.LowBalance, LowBalanceCourse{
+				CourseID:          course.ID,
+				CourseName:        course.Name,
+				RemainingSessions: balance.TotalRemaining(),
+				Threshold:         courseThreshold,
+				Balance:           balance,
+			})
+		}
+	}
+	dashboard.RemainingValue = roundCents(dashboard.RemainingValue)
+	sort.SliceStable(dashboard.LowBalance, func(i, j int) bool {
+		return dashboard.LowBalance[i].RemainingSessions < dashboard.LowBalance[j].RemainingSessions
+	})
+
+	month, err := monthAttendance(db, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	dashboard.Month = *month
+	return dashboard, nil
+}
+
+// monthAttendance 统计用户所有课程本月的出勤（含已停用的课程）
+func monthAttendance(db *gorm.DB, userID uint, now time.Time) (*MonthAttendance, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	month := &MonthAttendance{Month: monthStart.Format("2006-01")}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := db.Model(&models.AttendanceRecord{}).
+		Select("status, COUNT(*) AS count").
+		Where("course_id IN (?)", db.Model(&models.Course{}).Select("id").Where("user_id = ?", userID)).
+		Where("schedule_date >= ? AND schedule_date < ?", monthStart.Format("2006-01-02"), monthStart.AddDate(0, 1, 0).Format("2006-01-02")).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		switch {
+		case models.IsAttended(row.Status):
+			month.Attended += row.Count
+		case row.Status == models.AttendanceAbsent || row.Status == models.AttendanceNoShow:
+			month.Missed += row.Count
+		case row.Status == models.AttendancePending:
+			month.Pending += row.Count
+		}
+	}
+	if confirmed := month.Attended + month.Missed; confirmed > 0 {
+		month.AttendanceRate = int(month.Attended * 100 / confirmed)
+	}
+	return month, nil
+}
+
+// dashboardKey 出勤记录的唯一键
+func dashboardKey(courseID uint, date string) string {
+	return fmt.Sprintf("%d-%s", courseID, date)
+}
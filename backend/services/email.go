@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"course-management-backend/config"
+	"course-management-backend/database"
+	"course-management-backend/models"
+)
+
+// SMTP 加密方式
+const (
+	SMTPTLSNone     = "none"     // 明文（仅用于本地测试）
+	SMTPTLSStartTLS = "starttls" // 先明文连接再升级（通常为587端口）
+	SMTPTLSImplicit = "tls"      // 直接TLS连接（通常为465端口）
+)
+
+// ErrEmailNotConfigured 未配置SMTP服务器
+var ErrEmailNotConfigured = errors.New("未配置SMTP服务器，无法发送邮件")
+
+// SMTPConfig SMTP服务器配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLS      string
+}
+
+// EmailMessage 邮件内容（纯文本与HTML两种格式）
+type EmailMessage struct {
+	To      string `json:"to,omitempty"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// LoadSMTPConfig 从环境变量读取SMTP配置
+func LoadSMTPConfig() SMTPConfig {
+	return SMTPConfig{
+		Host:     config.GetEnv("SMTP_HOST", ""),
+		Port:     config.GetEnvInt("SMTP_PORT", 587),
+		Username: config.GetEnv("SMTP_USERNAME", ""),
+		Password: config.GetEnv("SMTP_PASSWORD", ""),
+		From:     config.GetEnv("SMTP_FROM", ""),
+		TLS:      strings.ToLower(config.GetEnv("SMTP_TLS", SMTPTLSStartTLS)),
+	}
+}
+
+// Enabled 是否已配置SMTP
+func (c SMTPConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// SendEmail 通过SMTP发送邮件
+func SendEmail(cfg SMTPConfig, message EmailMessage) error {
+	if !cfg.Enabled() {
+		return ErrEmailNotConfigured
+	}
+
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	fromAddress, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("发件人地址无效: %v", err)
+	}
+	toAddress, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("收件人地址无效: %v", err)
+	}
+
+	body, err := buildMIMEMessage(fromAddress, toAddress, message)
+	if err != nil {
+		return err
+	}
+
+	client, err := dialSMTP(cfg)
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+	defer client.Close()
+
+	if cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			auth := smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+			if err := client.Auth(auth); err != nil {
+				return fmt.Errorf("SMTP认证失败: %v", err)
+			}
+		}
+	}
+
+	if err := client.Mail(fromAddress.Address); err != nil {
+		return fmt.Errorf("SMTP发件人被拒绝: %v", err)
+	}
+	if err := client.Rcpt(toAddress.Address); err != nil {
+		return fmt.Errorf("SMTP收件人被拒绝: %v", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(body); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("SMTP发送失败: %v", err)
+	}
+	return client.Quit()
+}
+
+// dialSMTP 按配置的加密方式连接SMTP服务器
+func dialSMTP(cfg SMTPConfig) (*smtp.Client, error) {
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+
+	if cfg.TLS == SMTPTLSImplicit {
+		conn, err := tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, cfg.Host)
+	}
+
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if cfg.TLS == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP服务器不支持STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// buildMIMEMessage 构建 multipart/alternative 邮件
+func buildMIMEMessage(from, to *mail.Address, message EmailMessage) ([]byte, error) {
+	boundaryBytes := make([]byte, 12)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := "course-record-" + hex.EncodeToString(boundaryBytes)
+
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", from.String())
+	writeHeader("To", to.String())
+	writeHeader("Subject", mime.BEncoding.Encode("UTF-8", message.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, boundary))
+	buf.WriteString("\r\n")
+
+	writePart := func(contentType, content string) {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		writeHeader("Content-Type", contentType+"; charset=UTF-8")
+		writeHeader("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		encoded := base64.StdEncoding.EncodeToString([]byte(content))
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+	writePart("text/plain", message.Text)
+	if message.HTML != "" {
+		writePart("text/html", message.HTML)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// sendEmailToUser 给开启了邮件提醒的用户发送邮件
+// 用户未开启邮件提醒时返回 false, nil
+func sendEmailToUser(userID uint, message EmailMessage) (bool, error) {
+	preference, err := GetNotificationPreference(userID)
+	if err != nil {
+		return false, fmt.Errorf("查询通知偏好失败: %v", err)
+	}
+
+	return deliverEmail(LoadSMTPConfig(), preference, func() (string, error) {
+		var user models.User
+		if err := database.GetDB().First(&user, userID).Error; err != nil {
+			return "", fmt.Errorf("查询用户失败: %v", err)
+		}
+		return user.Email, nil
+	}, message)
+}
+
+// deliverEmail 按通知偏好发送邮件，确认需要发送后才通过 lookupEmail 查询收件地址
+func deliverEmail(cfg SMTPConfig, preference *models.NotificationPreference, lookupEmail func() (string, error), message EmailMessage) (bool, error) {
+	if !preference.EmailEnabled {
+		return false, nil
+	}
+	if !cfg.Enabled() {
+		return false, ErrEmailNotConfigured
+	}
+
+	email, err := lookupEmail()
+	if err != nil {
+		return false, err
+	}
+	if email == "" {
+		return false, errors.New("用户未填写邮箱")
+	}
+
+	message.To = email
+	if err := SendEmail(cfg, message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reminderEmailData 课程提醒邮件模板数据
+type reminderEmailData struct {
+	CourseName string
+	DateText   string
+	StartTime  string
+	EndTime    string
+	Location   string
+	Instructor string
+}
+
+// consumptionEmailData 消课确认邮件模板数据
+type consumptionEmailData struct {
+	CourseName  string
+	DateText    string
+	Sessions    int
+	SessionType string
+	Description string
+}
+
+var reminderTextTemplate = texttemplate.Must(texttemplate.New("reminder").Parse(`课程提醒
+
+{{.CourseName}}
+时间：{{.DateText}}{{if .StartTime}} {{.StartTime}}-{{.EndTime}}{{end}}
+{{- if .Location}}
+地点：{{.Location}}{{end}}
+{{- if .Instructor}}
+老师：{{.Instructor}}{{end}}
+
+请按时上课，如需请假请在课程管理中标记。
+`))
+
+var reminderHTMLTemplate = htmltemplate.Must(htmltemplate.New("reminder").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+<h2 style="margin-bottom:8px">课程提醒</h2>
+<p style="font-size:16px;font-weight:bold">{{.CourseName}}</p>
+<table cellpadding="4">
+<tr><td>时间</td><td>{{.DateText}}{{if .StartTime}} {{.StartTime}}-{{.EndTime}}{{end}}</td></tr>
+{{if .Location}}<tr><td>地点</td><td>{{.Location}}</td></tr>{{end}}
+{{if .Instructor}}<tr><td>老师</td><td>{{.Instructor}}</td></tr>{{end}}
+</table>
+<p style="color:#888">请按时上课，如需请假请在课程管理中标记。</p>
+</body></html>
+`))
+
+var consumptionTextTemplate = texttemplate.Must(texttemplate.New("consumption").Parse(`消课成功
+
+{{.CourseName}}{{if .DateText}}（{{.DateText}}）{{end}}
+已消耗 {{.Sessions}} 个{{.SessionType}}
+{{- if .Description}}
+备注：{{.Description}}{{end}}
+`))
+
+var consumptionHTMLTemplate = htmltemplate.Must(htmltemplate.New("consumption").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+<h2 style="margin-bottom:8px">消课成功</h2>
+<p style="font-size:16px;font-weight:bold">{{.CourseName}}{{if .DateText}}（{{.DateText}}）{{end}}</p>
+<p>已消耗 <strong>{{.Sessions}}</strong> 个{{.SessionType}}</p>
+{{if .Description}}<p style="color:#888">备注：{{.Description}}</p>{{end}}
+</body></html>
+`))
+
+// reconcileEmailItem 出勤自动处理邮件中的一次课
+type reconcileEmailItem struct {
+	CourseName string
+	DateText   string
+	StartTime  string
+	Result     string
+}
+
+var reconcileTextTemplate = texttemplate.Must(texttemplate.New("reconcile").Parse(`出勤自动处理
+
+以下课程未确认出勤，已按课程设置自动处理：
+{{range .}}
+- {{.CourseName}} {{.DateText}} {{.StartTime}}：{{.Result}}{{end}}
+
+如有错误，可在出勤记录中修改。
+`))
+
+var reconcileHTMLTemplate = htmltemplate.Must(htmltemplate.New("reconcile").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+<h2 style="margin-bottom:8px">出勤自动处理</h2>
+<p>以下课程未确认出勤，已按课程设置自动处理：</p>
+<table cellpadding="4">
+{{range .}}<tr><td>{{.CourseName}}</td><td>{{.DateText}} {{.StartTime}}</td><td>{{.Result}}</td></tr>
+{{end}}</table>
+<p style="color:#888">如有错误，可在出勤记录中修改。</p>
+</body></html>
+`))
+
+// courseAlertEmailData 课程提醒邮件数据
+type courseAlertEmailData struct {
+	Title      string
+	CourseName string
+	Message    string
+}
+
+var courseAlertTextTemplate = texttemplate.Must(texttemplate.New("course_alert").Parse(`{{.Title}}
+
+{{.Message}}
+
+可在课程管理中续费，或调整到期日和提醒阈值。
+`))
+
+var courseAlertHTMLTemplate = htmltemplate.Must(htmltemplate.New("course_alert").Parse(`<!DOCTYPE html>
+<html><body style="font-family:sans-serif;color:#333">
+<h2 style="margin-bottom:8px">{{.Title}}</h2>
+<p style="font-size:16px;font-weight:bold">{{.CourseName}}</p>
+<p>{{.Message}}</p>
+<p style="color:#888">可在课程管理中续费，或调整到期日和提醒阈值。</p>
+</body></html>
+`))
+
+// renderReminderEmail 渲染课程提醒邮件
+func renderReminderEmail(course *models.Course, date string) (EmailMessage, error) {
+	data := reminderEmailData{
+		CourseName: course.Name,
+		DateText:   formatDateText(date),
+	}
+	if len(course.Schedules) > 0 {
+		schedule := course.Schedules[0]
+		data.StartTime = schedule.StartTime
+		data.EndTime = schedule.EndTime
+		data.Location = schedule.Location
+		data.Instructor = schedule.Instructor
+	}
+
+	return renderEmail(fmt.Sprintf("课程提醒：%s %s", data.DateText, course.Name),
+		reminderTextTemplate, reminderHTMLTemplate, data)
+}
+
+// renderConsumptionEmail 渲染消课确认邮件
+func renderConsumptionEmail(course *models.Course, attendance *models.AttendanceRecord, consumption *models.SessionConsumption) (EmailMessage, error) {
+	data := consumptionEmailData{
+		CourseName:  course.Name,
+		Sessions:    consumption.SessionsConsumed,
+		SessionType: consumption.GetSessionTypeText(),
+		Description: consumption.Description,
+	}
+	if attendance != nil && attendance.ScheduleDate != "" {
+		data.DateText = formatDateText(models.DateOnly(attendance.ScheduleDate))
+	}
+
+	return renderEmail(fmt.Sprintf("消课成功：%s", course.Name),
+		consumptionTextTemplate, consumptionHTMLTemplate, data)
+}
+
+// renderReconcileEmail 渲染出勤自动处理汇总邮件
+func renderReconcileEmail(results []ReconciledAttendance) (EmailMessage, error) {
+	items := make([]reconcileEmailItem, 0, len(results))
+	for _, result := range results {
+		items = append(items, reconcileEmailItem{
+			CourseName: result.CourseName,
+			DateText:   formatDateText(result.Date),
+			StartTime:  result.StartTime,
+			Result:     result.GetResultText(),
+		})
+	}
+
+	return renderEmail(fmt.Sprintf("出勤自动处理：%d 节课", len(results)),
+		reconcileTextTemplate, reconcileHTMLTemplate, items)
+}
+
+// renderCourseAlertEmail 渲染课时不足/即将到期提醒邮件
+func renderCourseAlertEmail(course *models.Course, title, message string) (EmailMessage, error) {
+	data := courseAlertEmailData{
+		Title:      title,
+		CourseName: course.Name,
+		Message:    message,
+	}
+
+	return renderEmail(fmt.Sprintf("%s：%s", title, course.Name),
+		courseAlertTextTemplate, courseAlertHTMLTemplate, data)
+}
+
+// renderEmail 渲染纯文本和HTML邮件正文
+func renderEmail(subject string, textTemplate *texttemplate.Template, htmlTemplate *htmltemplate.Template, data interface{}) (EmailMessage, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return EmailMessage{}, err
+	}
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return EmailMessage{}, err
+	}
+	return EmailMessage{Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
+
+// formatDateText 格式化日期为 "01月02日 周一"
+func formatDateText(date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	weekdayText := []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}[t.Weekday()]
+	return fmt.Sprintf("%s %s", t.Format("01月02日"), weekdayText)
+}
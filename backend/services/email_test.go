@@ -0,0 +1,251 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"course-management-backend/models"
+)
+
+// smtpSink 只在本机监听的最简SMTP服务器，记录收到的信封和邮件内容
+type smtpSink struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	connections int
+	from        string
+	rcpt        []string
+	data        []byte
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go sink.serve()
+	return sink
+}
+
+func (s *smtpSink) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: portNumber, From: "课程记录 <noreply@example.com>", TLS: SMTPTLSNone}
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.connections++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = line[len("MAIL FROM:"):]
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, line[len("RCPT TO:"):])
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		emailEnabled bool
+		email        string
+		message      EmailMessage
+		sent         bool
+		wantErr      bool
+		parts        []string
+	}{
+		{
+			name:         "text and html",
+			emailEnabled: true,
+			email:        "student@example.com",
+			message:      EmailMessage{Subject: "课程提醒：01月05日 周一 钢琴课", Text: "钢琴课 18:00-19:00\n请按时上课。", HTML: "<p>钢琴课 <strong>18:00</strong></p>"},
+			sent:         true,
+			parts:        []string{"text/plain", "text/html"},
+		},
+		{
+			name:         "text only",
+			emailEnabled: true,
+			email:        "student@example.com",
+			message:      EmailMessage{Subject: "消课成功：钢琴课", Text: strings.Repeat("已消耗 1 个正式课时。", 20)},
+			sent:         true,
+			parts:        []string{"text/plain"},
+		},
+		{
+			name:         "opted out",
+			emailEnabled: false,
+			email:        "student@example.com",
+			message:      EmailMessage{Subject: "课程提醒", Text: "钢琴课"},
+		},
+		{
+			name:         "no address",
+			emailEnabled: true,
+			message:      EmailMessage{Subject: "课程提醒", Text: "钢琴课"},
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newSMTPSink(t)
+			lookups := 0
+			preference := &models.NotificationPreference{EmailEnabled: tt.emailEnabled}
+			sent, err := deliverEmail(sink.config(), preference, func() (string, error) {
+				lookups++
+				return tt.email, nil
+			}, tt.message)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if sent != tt.sent {
+				t.Fatalf("sent = %v, want %v", sent, tt.sent)
+			}
+
+			sink.mu.Lock()
+			defer sink.mu.Unlock()
+			if !tt.sent {
+				if sink.connections != 0 {
+					t.Errorf("opened %d SMTP connections, want none", sink.connections)
+				}
+				if !tt.emailEnabled && lookups != 0 {
+					t.Errorf("looked up the address for an opted-out user")
+				}
+				return
+			}
+
+			if sink.from != "<noreply@example.com>" {
+				t.Errorf("MAIL FROM = %s", sink.from)
+			}
+			if len(sink.rcpt) != 1 || sink.rcpt[0] != "<"+tt.email+">" {
+				t.Errorf("RCPT TO = %v", sink.rcpt)
+			}
+			checkMIMEMessage(t, sink.data, tt.message, tt.parts)
+		})
+	}
+}
+
+// checkMIMEMessage 校验 multipart/alternative 结构、B编码的主题和各部分正文
+func checkMIMEMessage(t *testing.T, data []byte, message EmailMessage, parts []string) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	rawSubject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?UTF-8?b?") {
+		t.Errorf("Subject %q is not B-encoded", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil || subject != message.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, message.Subject)
+	}
+	if parsed.Header.Get("MIME-Version") != "1.0" {
+		t.Errorf("MIME-Version = %q", parsed.Header.Get("MIME-Version"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %s (%v)", mediaType, err)
+	}
+
+	bodies := map[string]string{"text/plain": message.Text, "text/html": message.HTML}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			if i != len(parts) {
+				t.Errorf("got %d parts, want %d", i, len(parts))
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		if i >= len(parts) {
+			t.Fatalf("unexpected extra part %s", part.Header.Get("Content-Type"))
+		}
+
+		partType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != parts[i] || !strings.EqualFold(partParams["charset"], "UTF-8") {
+			t.Errorf("part %d Content-Type = %s", i, part.Header.Get("Content-Type"))
+		}
+		if part.Header.Get("Content-Transfer-Encoding") != "base64" {
+			t.Errorf("part %d Content-Transfer-Encoding = %s", i, part.Header.Get("Content-Transfer-Encoding"))
+		}
+
+		scanner := bufio.NewScanner(part)
+		var encoded strings.Builder
+		for scanner.Scan() {
+			if len(scanner.Text()) > 76 {
+				t.Errorf("part %d has a %d character line", i, len(scanner.Text()))
+			}
+			encoded.WriteString(scanner.Text())
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded.String())
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		if string(decoded) != bodies[partType] {
+			t.Errorf("part %d body = %q, want %q", i, decoded, bodies[partType])
+		}
+	}
+}
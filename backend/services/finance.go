@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 退费规则
+const (
+	// RefundRuleRegularFirst 按实际消课记录计算：已消耗的正式课时按单价扣除，剩余正式课时退费，赠送课时不计价
+	RefundRuleRegularFirst = "regular_first"
+	// RefundRuleProRata 按比例退费：总金额平摊到全部课时（含赠送），按剩余课时比例退费
+	RefundRuleProRata = "pro_rata"
+	// RefundRuleForfeitBonus 赠送课时作废：已上的赠送课时也按正式课时单价扣除
+	RefundRuleForfeitBonus = "forfeit_bonus"
+)
+
+// IsValidRefundRule 检查退费规则是否有效
+func IsValidRefundRule(rule string) bool {
+	switch rule {
+	case RefundRuleRegularFirst, RefundRuleProRata, RefundRuleForfeitBonus:
+		return true
+	}
+	return false
+}
+
+// CourseValuation 课程价值估算
+type CourseValuation struct {
+	CourseID        uint                 `json:"courseId"`
+	TotalAmount     float64              `json:"totalAmount"`
+	PricePerSession float64              `json:"pricePerSession"` // 正式课时单价，赠送课时价值为0
+	ConsumedValue   float64              `json:"consumedValue"`
+	RemainingValue  float64              `json:"remainingValue"`
+	Balance         models.CourseBalance `json:"balance"`
+	Refund          RefundEstimate       `json:"refund"`
+}
+
+// RefundEstimate 退费估算
+type RefundEstimate struct {
+	Rule            string  `json:"rule"`
+	ChargedSessions int64   `json:"chargedSessions"` // 计费扣除的课时数
+	GrossAmount     float64 `json:"grossAmount"`     // 扣手续费前的退费金额
+	FeeRate         float64 `json:"feeRate"`
+	Fee             float64 `json:"fee"`
+	Amount          float64 `json:"amount"` // 实际可退金额
+}
+
+// PricePerSession 计算正式课时单价
+func PricePerSession(course *models.Course) float64 {
+	if course.RegularSessions <= 0 {
+		return 0
+	}
+	return course.TotalAmount / float64(course.RegularSessions)
+}
+
+// GetCourseValuation 计算课程已消耗价值、剩余价值和退费估算
+func GetCourseValuation(db *gorm.DB, course *models.Course, rule string, feeRate float64) (*CourseValuation, error) {
+	if !IsValidRefundRule(rule) {
+		return nil, fmt.Errorf("不支持的退费规则: %s", rule)
+	}
+	if feeRate < 0 || feeRate > 1 {
+		return nil, fmt.Errorf("手续费比例必须在0-1之间")
+	}
+
+	balance, err := GetCourseBalance(db, course)
+	if err != nil {
+		return nil, err
+	}
+
+	return ValueCourse(course, balance, rule, feeRate), nil
+}
+
+// ValueCourse 根据余额计算课程价值（不访问数据库）
+func ValueCourse(course *models.Course, balance models.CourseBalance, rule string, feeRate float64) *CourseValuation {
+	price := PricePerSession(course)
+
+	// 赠送课时不计价，只有正式课时产生消耗价值
+	consumedValue := roundCents(price * float64(minInt64(balance.RegularConsumed, balance.RegularTotal)))
+	remainingValue := roundCents(course.TotalAmount - consumedValue)
+	if remainingValue < 0 {
+		remainingValue = 0
+	}
+
+	refund := RefundEstimate{Rule: rule, FeeRate: feeRate}
+	switch rule {
+	case RefundRuleProRata:
+		totalSessions := balance.RegularTotal + balance.BonusTotal
+		refund.ChargedSessions = minInt64(balance.RegularConsumed+balance.BonusConsumed, totalSessions)
+		if totalSessions > 0 {
+			refund.GrossAmount = roundCents(course.TotalAmount * float64(totalSessions-refund.ChargedSessions) / float64(totalSessions))
+		}
+	case RefundRuleForfeitBonus:
+		refund.ChargedSessions = minInt64(balance.RegularConsumed+balance.BonusConsumed, balance.RegularTotal)
+		refund.GrossAmount = roundCents(course.TotalAmount - price*float64(refund.ChargedSessions))
+	default:
+		refund.ChargedSessions = minInt64(balance.RegularConsumed, balance.RegularTotal)
+		refund.GrossAmount = remainingValue
+	}
+	if refund.GrossAmount < 0 {
+		refund.GrossAmount = 0
+	}
+	refund.Fee = roundCents(refund.GrossAmount * feeRate)
+	refund.Amount = roundCents(refund.GrossAmount - refund.Fee)
+
+	return &CourseValuation{
+		CourseID:        course.ID,
+		TotalAmount:     course.TotalAmount,
+		PricePerSession: roundCents(price),
+		ConsumedValue:   consumedValue,
+		RemainingValue:  remainingValue,
+		Balance:         balance,
+		Refund:          refund,
+	}
+}
+
+// roundCents 金额保留两位小数
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// minInt64 取较小值
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
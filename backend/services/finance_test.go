@@ -0,0 +1,138 @@
+package services
+
+import (
+	"testing"
+	"course-management-backend/models"
+)
+
+// valuationWant 期望的单价、消耗价值、剩余价值和退费结果
+type valuationWant struct {
+	price          float64
+	consumedValue  float64
+	remainingValue float64
+	charged        int64
+	gross          float64
+	fee            float64
+	amount         float64
+}
+
+func TestValueCourse(t *testing.T) {
+	tenSessions := &models.Course{TotalAmount: 1000, RegularSessions: 10}
+	threeSessions := &models.Course{TotalAmount: 1000, RegularSessions: 3}
+	noSessions := &models.Course{TotalAmount: 500}
+
+	tests := []struct {
+		name    string
+		course  *models.Course
+		balance models.CourseBalance
+		rule    string
+		feeRate float64
+		want    valuationWant
+	}{
+		{
+			name:    "regular first ignores bonus",
+			course:  tenSessions,
+			balance: models.CourseBalance{RegularTotal: 10, RegularConsumed: 3, BonusTotal: 2, BonusConsumed: 1},
+			rule:    RefundRuleRegularFirst,
+			want:    valuationWant{100, 300, 700, 3, 700, 0, 700},
+		},
+		{
+			name:    "pro rata spreads over bonus sessions",
+			course:  tenSessions,
+			balance: models.CourseBalance{RegularTotal: 10, RegularConsumed: 3, BonusTotal: 2, BonusConsumed: 1},
+			rule:    RefundRuleProRata,
+			feeRate: 0.1,
+			want:    valuationWant{100, 300, 700, 4, 666.67, 66.67, 600},
+		},
+		{
+			name:    "forfeit bonus charges bonus at regular price",
+			course:  tenSessions,
+			balance: models.CourseBalance{RegularTotal: 10, RegularConsumed: 3, BonusTotal: 2, BonusConsumed: 1},
+			rule:    RefundRuleForfeitBonus,
+			feeRate: 0.05,
+			want:    valuationWant{100, 300, 700, 4, 600, 30, 570},
+		},
+		{
+			name:    "forfeit bonus caps at regular total",
+			course:  tenSessions,
+			balance: models.CourseBalance{RegularTotal: 10, RegularConsumed: 10, BonusTotal: 2, BonusConsumed: 2},
+			rule:    RefundRuleForfeitBonus,
+			want:    valuationWant{100, 1000, 0, 10, 0, 0, 0},
+		},
+		{
+			name:    "over consumed regular sessions",
+			course:  tenSessions,
+			balance: models.CourseBalance{RegularTotal: 10, RegularConsumed: 12},
+			rule:    RefundRuleRegularFirst,
+			feeRate: 0.1,
+			want:    valuationWant{100, 1000, 0, 10, 0, 0, 0},
+		},
+		{
+			name:    "rounds to cents",
+			course:  threeSessions,
+			balance: models.CourseBalance{RegularTotal: 3, RegularConsumed: 1},
+			rule:    RefundRuleRegularFirst,
+			feeRate: 0.03,
+			want:    valuationWant{333.33, 333.33, 666.67, 1, 666.67, 20, 646.67},
+		},
+		{
+			name:   "no regular sessions",
+			course: noSessions,
+			rule:   RefundRuleRegularFirst,
+			want:   valuationWant{0, 0, 500, 0, 500, 0, 500},
+		},
+		{
+			name:   "pro rata without sessions",
+			course: noSessions,
+			rule:   RefundRuleProRata,
+			want:   valuationWant{0, 0, 500, 0, 0, 0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valuation := ValueCourse(tt.course, tt.balance, tt.rule, tt.feeRate)
+			if valuation.PricePerSession != tt.want.price {
+				t.Errorf("price = %v, want %v", valuation.PricePerSession, tt.want.price)
+			}
+			if valuation.ConsumedValue != tt.want.consumedValue {
+				t.Errorf("consumedValue = %v, want %v", valuation.ConsumedValue, tt.want.consumedValue)
+			}
+			if valuation.RemainingValue != tt.want.remainingValue {
+				t.Errorf("remainingValue = %v, want %v", valuation.RemainingValue, tt.want.remainingValue)
+			}
+			refund := valuation.Refund
+			if refund.Rule != tt.rule || refund.FeeRate != tt.feeRate {
+				t.Errorf("refund rule = %s/%v, want %s/%v", refund.Rule, refund.FeeRate, tt.rule, tt.feeRate)
+			}
+			if refund.ChargedSessions != tt.want.charged {
+				t.Errorf("charged = %d, want %d", refund.ChargedSessions, tt.want.charged)
+			}
+			if refund.GrossAmount != tt.want.gross || refund.Fee != tt.want.fee || refund.Amount != tt.want.amount {
+				t.Errorf("refund = %v - %v = %v, want %v - %v = %v",
+					refund.GrossAmount, refund.Fee, refund.Amount, tt.want.gross, tt.want.fee, tt.want.amount)
+			}
+		})
+	}
+}
+
+func TestGetCourseValuationRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		feeRate float64
+	}{
+		{"unknown rule", "half", 0},
+		{"negative fee", RefundRuleProRata, -0.1},
+		{"fee above one", RefundRuleProRata, 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 参数校验在查询余额之前，不需要数据库
+			if _, err := GetCourseValuation(nil, &models.Course{}, tt.rule, tt.feeRate); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
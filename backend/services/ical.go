@@ -0,0 +1,412 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"course-management-backend/models"
+)
+
+// iCalendar 相关格式
+const (
+	icalDateTimeFormat = "20060102T150405"
+	icalProdID         = "-//CourseRecord//Course Schedule//CN"
+)
+
+// icalTimezoneYears VTIMEZONE中输出到当前时间之后多少年的时区变化
+const icalTimezoneYears = 10
+
+// icalWeekdays 1-7 对应的 iCalendar 星期缩写
+var icalWeekdays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// RenderCalendar 将课程排课渲染为 iCalendar 文本
+// 每个排课生成一个带RRULE的VEVENT，例外日期和机构取消写入EXDATE，单次调课通过RECURRENCE-ID覆盖
+// courses需通过PreloadForOccurrences预加载关联，时间按排课/用户时区输出（带TZID及对应的VTIMEZONE），未设置时区时按loc输出为浮动时间
+// mutedCourseIDs中的课程不输出提醒
+func RenderCalendar(name string, courses []models.Course, leadTimes []time.Duration, mutedCourseIDs []uint, loc *time.Location) string {
+	muted := make(map[uint]bool, len(mutedCourseIDs))
+	for _, courseID := range mutedCourseIDs {
+		muted[courseID] = true
+	}
+
+	w := &icalWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icalProdID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + escapeICalText(name))
+	if tzid := icalTZID(loc); tzid != "" {
+		w.line("X-WR-TIMEZONE:" + tzid)
+	}
+
+	// 每个用到的TZID都需要对应的VTIMEZONE，从最早的上课日期开始输出时区规则
+	now := time.Now()
+	var zones []*time.Location
+	zoneStarts := make(map[string]time.Time)
+	for i := range courses {
+		course := &courses[i]
+		for j := range course.Schedules {
+			schedule := &course.Schedules[j]
+			scheduleLoc := ScheduleLocation(course, schedule, loc)
+			tzid := icalTZID(scheduleLoc)
+			if !schedule.IsActive || tzid == "" || tzid == "UTC" {
+				continue
+			}
+			first, ok := firstOccurrenceDay(schedule, scheduleLoc)
+			if !ok {
+				continue
+			}
+			if start, exists := zoneStarts[tzid]; !exists || first.Before(start) {
+				if !exists {
+					zones = append(zones, scheduleLoc)
+				}
+				zoneStarts[tzid] = first
+			}
+		}
+	}
+	for _, zone := range zones {
+		from := zoneStarts[zone.String()]
+		to := now
+		if from.After(to) {
+			to = from
+		}
+		writeVTimezone(w, zone, from, to.AddDate(icalTimezoneYears, 0, 0))
+	}
+
+	stamp := now.UTC().Format(icalDateTimeFormat) + "Z"
+	for i := range courses {
+		course := &courses[i]
+		courseLeadTimes := leadTimes
+		if muted[course.ID] {
+			courseLeadTimes = nil
+		}
+		for j := range course.Schedules {
+			schedule := &course.Schedules[j]
+			if !schedule.IsActive {
+				continue
+			}
+			writeScheduleEvents(w, course, schedule, courseLeadTimes, ScheduleLocation(course, schedule, loc), stamp)
+		}
+	}
+
+	w.line("END:VCALENDAR")
+	return w.String()
+}
+
+// writeScheduleEvents 输出单个排课的重复事件及其调课覆盖事件
+func writeScheduleEvents(w *icalWriter, course *models.Course, schedule *models.CourseSchedule, leadTimes []time.Duration, loc *time.Location, stamp string) {
+	first, ok := firstOccurrenceDay(schedule, loc)
+	if !ok {
+		return
+	}
+	start, err := combineDateTime(first, schedule.StartTime)
+	if err != nil {
+		return
+	}
+	end, err := combineDateTime(first, schedule.EndTime)
+	if err != nil {
+		return
+	}
+
+	uid := fmt.Sprintf("course-%d-schedule-%d@course-record", course.ID, schedule.ID)
+
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + uid)
+	w.line("DTSTAMP:" + stamp)
+	w.line(icalDateTimeProperty("DTSTART", loc, start))
+	w.line(icalDateTimeProperty("DTEND", loc, end))
+	w.line("RRULE:" + buildRRule(schedule, loc))
+	if exdates := excludedDates(course, schedule, loc); len(exdates) > 0 {
+		w.line(icalDateTimeProperty("EXDATE", loc, exdates...))
+	}
+	writeEventDetails(w, course, schedule.Location, schedule.Instructor, leadTimes)
+	w.line("END:VEVENT")
+
+	// 调课：以RECURRENCE-ID覆盖原来的那一次
+	for _, override := range course.Overrides {
+		if override.Action != models.OverrideReschedule || override.NewDate == nil || override.OriginalStartTime != schedule.StartTime {
+			continue
+		}
+		originalDay, err := time.ParseInLocation("2006-01-02", models.DateOnly(override.OriginalDate), loc)
+		if err != nil || !schedule.OccursOn(originalDay) {
+			continue
+		}
+		newDay, err := time.ParseInLocation("2006-01-02", models.DateOnly(*override.NewDate), loc)
+		if err != nil {
+			continue
+		}
+		originalStart, _ := combineDateTime(originalDay, schedule.StartTime)
+		newStart, err := combineDateTime(newDay, override.NewStartTime)
+		if err != nil {
+			continue
+		}
+		newEnd, err := combineDateTime(newDay, override.NewEndTime)
+		if err != nil {
+			continue
+		}
+
+		location := schedule.Location
+		if override.Location != "" {
+			location = override.Location
+		}
+
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + uid)
+		w.line("DTSTAMP:" + stamp)
+		w.line(icalDateTimeProperty("RECURRENCE-ID", loc, originalStart))
+		w.line(icalDateTimeProperty("DTSTART", loc, newStart))
+		w.line(icalDateTimeProperty("DTEND", loc, newEnd))
+		writeEventDetails(w, course, location, schedule.Instructor, leadTimes)
+		w.line("END:VEVENT")
+	}
+}
+
+// writeEventDetails 输出事件的标题、地点、描述和提醒
+func writeEventDetails(w *icalWriter, course *models.Course, location, instructor string, leadTimes []time.Duration) {
+	w.line("SUMMARY:" + escapeICalText(course.Name))
+	if location != "" {
+		w.line("LOCATION:" + escapeICalText(location))
+	}
+
+	var description []string
+	if instructor != "" {
+		description = append(description, "老师: "+instructor)
+	}
+	if course.Description != "" {
+		description = append(description, course.Description)
+	}
+	if len(description) > 0 {
+		w.line("DESCRIPTION:" + escapeICalText(strings.Join(description, "\n")))
+	}
+
+	for _, lead := range leadTimes {
+		w.line("BEGIN:VALARM")
+		w.line("ACTION:DISPLAY")
+		w.line("DESCRIPTION:" + escapeICalText(course.Name+" 即将上课"))
+		w.line(fmt.Sprintf("TRIGGER:-PT%dM", int(lead.Minutes())))
+		w.line("END:VALARM")
+	}
+}
+
+// buildRRule 构建重复规则
+func buildRRule(schedule *models.CourseSchedule, loc *time.Location) string {
+	interval := schedule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	day := icalWeekdays[schedule.Weekday]
+
+	var parts []string
+	if schedule.Recurrence == models.RecurrenceMonthly {
+		parts = append(parts, "FREQ=MONTHLY", fmt.Sprintf("INTERVAL=%d", interval), fmt.Sprintf("BYDAY=%d%s", schedule.WeekOfMonth, day))
+	} else {
+		parts = append(parts, "FREQ=WEEKLY", fmt.Sprintf("INTERVAL=%d", interval), "BYDAY="+day, "WKST=MO")
+	}
+
+	if schedule.EndDate != nil && *schedule.EndDate != "" {
+		if endDay, err := time.ParseInLocation("2006-01-02", models.DateOnly(*schedule.EndDate), loc); err == nil {
+			until := endDay.Add(24*time.Hour - time.Second)
+			// DTSTART带TZID时UNTIL必须为UTC时间
+			if icalTZID(loc) != "" {
+				parts = append(parts, "UNTIL="+until.UTC().Format(icalDateTimeFormat)+"Z")
+			} else {
+				parts = append(parts, "UNTIL="+until.Format(icalDateTimeFormat))
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// excludedDates 获取排课需要排除的上课时间（例外日期、机构取消、调课原时间）
+func excludedDates(course *models.Course, schedule *models.CourseSchedule, loc *time.Location) []time.Time {
+	seen := make(map[string]bool)
+	var dates []time.Time
+	add := func(date string) {
+		day, err := time.ParseInLocation("2006-01-02", models.DateOnly(date), loc)
+		if err != nil || !schedule.OccursOn(day) {
+			return
+		}
+		start, err := combineDateTime(day, schedule.StartTime)
+		if err != nil {
+			return
+		}
+		value := start.Format(icalDateTimeFormat)
+		if !seen[value] {
+			seen[value] = true
+			dates = append(dates, start)
+		}
+	}
+
+	for _, exception := range course.Exceptions {
+		add(exception.Date)
+	}
+	for _, override := range course.Overrides {
+		if override.Action == models.OverrideCancel && override.OriginalStartTime == schedule.StartTime {
+			add(override.OriginalDate)
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// icalTZID 时区对应的TZID，服务器本地时区返回空（输出浮动时间）
+func icalTZID(loc *time.Location) string {
+	if loc == nil || loc == time.Local || loc.String() == "Local" {
+		return ""
+	}
+	return loc.String()
+}
+
+// icalDateTimeProperty 输出日期时间属性，有IANA时区时附加TZID参数
+func icalDateTimeProperty(name string, loc *time.Location, times ...time.Time) string {
+	values := make([]string, len(times))
+	for i, t := range times {
+		values[i] = t.Format(icalDateTimeFormat)
+	}
+	if tzid := icalTZID(loc); tzid != "" {
+		if tzid == "UTC" {
+			for i := range values {
+				values[i] += "Z"
+			}
+			return name + ":" + strings.Join(values, ",")
+		}
+		return name + ";TZID=" + tzid + ":" + strings.Join(values, ",")
+	}
+	return name + ":" + strings.Join(values, ",")
+}
+
+// writeVTimezone 输出时区定义（RFC 5545 3.6.5），逐条列出[from, to]内的夏令时切换，没有切换的时区只输出一个STANDARD
+func writeVTimezone(w *icalWriter, loc *time.Location, from, to time.Time) {
+	start := time.Date(from.Year(), 1, 1, 0, 0, 0, 0, loc)
+	_, offset := start.Zone()
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + icalTZID(loc))
+	writeTimezoneObservance(w, start, offset, offset)
+
+	// 按天查找偏移变化，再二分到秒级的切换时刻
+	for day := start.UTC(); day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+		low, high := day, next
+		for high.Sub(low) > time.Second {
+			mid := low.Add(high.Sub(low) / 2)
+			if _, midOffset := mid.In(loc).Zone(); midOffset == offset {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		_, newOffset := high.In(loc).Zone()
+		writeTimezoneObservance(w, high.In(loc), offset, newOffset)
+		offset = newOffset
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeTimezoneObservance 输出一次时区偏移的生效，DTSTART为切换前的本地时间
+func writeTimezoneObservance(w *icalWriter, at time.Time, offsetFrom, offsetTo int) {
+	kind := "STANDARD"
+	if at.IsDST() {
+		kind = "DAYLIGHT"
+	}
+	name, _ := at.Zone()
+
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + at.UTC().Add(time.Duration(offsetFrom)*time.Second).Format(icalDateTimeFormat))
+	w.line("TZOFFSETFROM:" + icalUTCOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + icalUTCOffset(offsetTo))
+	if name != "" {
+		w.line("TZNAME:" + escapeICalText(name))
+	}
+	w.line("END:" + kind)
+}
+
+// icalUTCOffset 格式化UTC偏移（±HHMM，有秒时为±HHMMSS）
+func icalUTCOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		value += fmt.Sprintf("%02d", seconds%60)
+	}
+	return value
+}
+
+// firstOccurrenceDay 查找排课的第一次上课日期
+func firstOccurrenceDay(schedule *models.CourseSchedule, loc *time.Location) (time.Time, bool) {
+	var anchor time.Time
+	if schedule.StartDate != nil && *schedule.StartDate != "" {
+		day, err := time.ParseInLocation("2006-01-02", models.DateOnly(*schedule.StartDate), loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		anchor = day
+	} else {
+		created := schedule.CreatedAt.In(loc)
+		anchor = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, loc)
+	}
+
+	interval := schedule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	// 每月重复最多需要覆盖 interval 个月，每周重复最多 interval 周
+	limit := 7*interval + 1
+	if schedule.Recurrence == models.RecurrenceMonthly {
+		limit = 31*(interval+1) + 1
+	}
+	for i := 0; i < limit; i++ {
+		day := anchor.AddDate(0, 0, i)
+		if schedule.OccursOn(day) {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// escapeICalText 转义 iCalendar 文本值
+func escapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+	)
+	return replacer.Replace(text)
+}
+
+// icalWriter 按 RFC 5545 输出内容行（CRLF换行，超过75字节折行）
+type icalWriter struct {
+	b strings.Builder
+}
+
+// line 输出一行，必要时折行（不拆分多字节字符）
+func (w *icalWriter) line(content string) {
+	const limit = 75
+	width := 0
+	for _, r := range content {
+		size := len(string(r))
+		if width+size > limit {
+			w.b.WriteString("\r\n ")
+			width = 1
+		}
+		w.b.WriteRune(r)
+		width += size
+	}
+	w.b.WriteString("\r\n")
+}
+
+// String 获取输出内容
+func (w *icalWriter) String() string {
+	return w.b.String()
+}
@@ -0,0 +1,541 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"course-management-backend/models"
+)
+
+// ICalEvent 从iCalendar文件解析出的事件
+type ICalEvent struct {
+	UID          string
+	Summary      string
+	Location     string
+	Description  string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	RRule        map[string]string
+	ExDates      []time.Time
+	RecurrenceID bool // 重复事件中被单独修改的某一次
+	Cancelled    bool
+}
+
+// CourseImportProposal 导入建议：一个SUMMARY对应一门课程
+type CourseImportProposal struct {
+	Course     models.CourseRequest `json:"course"`
+	EventCount int                  `json:"eventCount"` // 文件中对应的事件数
+	Warnings   []string             `json:"warnings,omitempty"`
+}
+
+// maxImportOccurrences 展开重复规则时的最大次数，防止无限循环
+const maxImportOccurrences = 1000
+
+// maxImportYears 展开重复规则时最多向后查找的年数，规则永远不匹配或间隔很大时也能结束
+const maxImportYears = 5
+
+// ParseICalendar 解析iCalendar文件中的VEVENT，时间统一转换到loc
+func ParseICalendar(r io.Reader, loc *time.Location) ([]ICalEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ICalEvent
+	var current *ICalEvent
+	depth := 0 // VEVENT内嵌套组件（如VALARM）的层级
+
+	for _, line := range lines {
+		name, params, value := parseICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current = &ICalEvent{}
+			depth = 0
+			continue
+		case name == "END" && value == "VEVENT":
+			if current != nil {
+				events = append(events, *current)
+			}
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if name == "BEGIN" {
+			depth++
+			continue
+		}
+		if name == "END" {
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			current.UID = value
+		case "SUMMARY":
+			current.Summary = strings.TrimSpace(unescapeICalText(value))
+		case "LOCATION":
+			current.Location = strings.TrimSpace(unescapeICalText(value))
+		case "DESCRIPTION":
+			current.Description = strings.TrimSpace(unescapeICalText(value))
+		case "DTSTART":
+			t, allDay, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("解析DTSTART失败: %v", err)
+			}
+			current.Start, current.AllDay = t, allDay
+		case "DTEND":
+			t, _, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("解析DTEND失败: %v", err)
+			}
+			current.End = t
+		case "DURATION":
+			if d, err := parseICalDuration(value); err == nil && !current.Start.IsZero() {
+				current.End = current.Start.Add(d)
+			}
+		case "RRULE":
+			current.RRule = parseRRule(value)
+		case "EXDATE":
+			for _, part := range strings.Split(value, ",") {
+				if t, _, err := parseICalTime(part, params, loc); err == nil {
+					current.ExDates = append(current.ExDates, t)
+				}
+			}
+		case "RECURRENCE-ID":
+			current.RecurrenceID = true
+		case "STATUS":
+			current.Cancelled = strings.EqualFold(value, "CANCELLED")
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("文件中没有找到日程事件")
+	}
+	return events, nil
+}
+
+// ProposeCourseImport 按SUMMARY分组生成课程及排课建议
+func ProposeCourseImport(events []ICalEvent) []CourseImportProposal {
+	groups := make(map[string][]ICalEvent)
+	var order []string
+	for _, event := range events {
+		summary := event.Summary
+		if summary == "" {
+			summary = "未命名课程"
+		}
+		if _, exists := groups[summary]; !exists {
+			order = append(order, summary)
+		}
+		groups[summary] = append(groups[summary], event)
+	}
+
+	proposals := make([]CourseImportProposal, 0, len(order))
+	for _, summary := range order {
+		proposals = append(proposals, proposeCourse(summary, groups[summary]))
+	}
+	return proposals
+}
+
+// proposeCourse 为同名事件生成一门课程的建议
+func proposeCourse(summary string, events []ICalEvent) CourseImportProposal {
+	proposal := CourseImportProposal{
+		Course: models.CourseRequest{
+			Name:     summary,
+			Category: "general",
+		},
+		EventCount: len(events),
+	}
+
+	sessions := 0
+	unbounded := false
+	single := make(map[string][]ICalEvent) // 单次事件按 星期+时间+地点 分组
+
+	for _, event := range events {
+		switch {
+		case event.Cancelled || event.RecurrenceID:
+			continue
+		case event.AllDay:
+			proposal.Warnings = append(proposal.Warnings, fmt.Sprintf("忽略全天事件 %s", event.Start.Format("2006-01-02")))
+			continue
+		case event.End.IsZero() || !event.End.After(event.Start):
+			proposal.Warnings = append(proposal.Warnings, fmt.Sprintf("忽略缺少结束时间的事件 %s", event.Start.Format("2006-01-02 15:04")))
+			continue
+		}
+		if proposal.Course.Description == "" {
+			proposal.Course.Description = event.Description
+		}
+
+		if event.RRule != nil {
+			schedules, count, warning := schedulesFromRRule(event)
+			if warning != "" {
+				proposal.Warnings = append(proposal.Warnings, warning)
+			}
+			if count < 0 {
+				unbounded = true
+			} else {
+				sessions += count
+			}
+			proposal.Course.Schedules = append(proposal.Course.Schedules, schedules...)
+			for _, exdate := range event.ExDates {
+				proposal.Course.Exceptions = append(proposal.Course.Exceptions, models.ScheduleExceptionRequest{
+					Date:   exdate.Format("2006-01-02"),
+					Reason: "导入：日历中已排除",
+				})
+			}
+			continue
+		}
+
+		key := fmt.Sprintf("%d|%s|%s|%s", models.IsoWeekday(event.Start), event.Start.Format("15:04"), event.End.Format("15:04"), event.Location)
+		single[key] = append(single[key], event)
+		sessions++
+	}
+
+	keys := make([]string, 0, len(single))
+	for key := range single {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		schedule, missing := scheduleFromSingleEvents(single[key])
+		proposal.Course.Schedules = append(proposal.Course.Schedules, schedule)
+		for _, date := range missing {
+			proposal.Course.Exceptions = append(proposal.Course.Exceptions, models.ScheduleExceptionRequest{
+				Date:   date,
+				Reason: "导入：当周无课",
+			})
+		}
+	}
+
+	if unbounded {
+		proposal.Warnings = append(proposal.Warnings, "存在无结束日期的重复事件，无法确定课时数，请手动填写正式课时")
+		proposal.Course.RegularSessions = 0
+	} else {
+		proposal.Course.RegularSessions = sessions
+	}
+	return proposal
+}
+
+// schedulesFromRRule 将重复事件映射为排课，返回课时数（无法确定时为-1）
+func schedulesFromRRule(event ICalEvent) ([]models.CourseScheduleRequest, int, string) {
+	rule := event.RRule
+	interval, _ := strconv.Atoi(rule["INTERVAL"])
+	if interval < 1 {
+		interval = 1
+	}
+
+	base := models.CourseScheduleRequest{
+		StartTime: event.Start.Format("15:04"),
+		EndTime:   event.End.Format("15:04"),
+		Location:  event.Location,
+		StartDate: event.Start.Format("2006-01-02"),
+		Interval:  interval,
+	}
+
+	var schedules []models.CourseScheduleRequest
+	switch rule["FREQ"] {
+	case "WEEKLY":
+		days := []string{icalWeekdays[models.IsoWeekday(event.Start)]}
+		if rule["BYDAY"] != "" {
+			days = strings.Split(rule["BYDAY"], ",")
+		}
+		for _, day := range days {
+			weekday := icalWeekdayIndex(day)
+			if weekday == 0 {
+				continue
+			}
+			schedule := base
+			schedule.Weekday = weekday
+			schedule.Recurrence = models.RecurrenceWeekly
+			schedules = append(schedules, schedule)
+		}
+	case "MONTHLY":
+		byDay := rule["BYDAY"]
+		nth, weekday := 0, 0
+		if byDay != "" {
+			nth, _ = strconv.Atoi(strings.TrimRight(byDay, "MOTUWEHFRSA"))
+			weekday = icalWeekdayIndex(byDay[len(byDay)-2:])
+		}
+		// 与排课的weekOfMonth一致，只支持第1-5个或最后一个
+		if (nth < 1 || nth > 5) && nth != -1 || weekday == 0 {
+			return nil, 0, fmt.Sprintf("暂不支持的每月重复规则（%s），已跳过", event.Summary)
+		}
+		schedule := base
+		schedule.Weekday = weekday
+		schedule.Recurrence = models.RecurrenceMonthly
+		schedule.WeekOfMonth = nth
+		schedules = append(schedules, schedule)
+	default:
+		return nil, 0, fmt.Sprintf("暂不支持的重复频率 %s（%s），已跳过", rule["FREQ"], event.Summary)
+	}
+	if len(schedules) == 0 {
+		return nil, 0, fmt.Sprintf("无法识别重复规则的星期（%s），已跳过", event.Summary)
+	}
+
+	// 展开重复规则以确定结束日期和课时数
+	var until time.Time
+	if value := rule["UNTIL"]; value != "" {
+		t, allDay, err := parseICalTime(value, nil, event.Start.Location())
+		if err == nil {
+			until = t
+			// 只有日期的UNTIL包含当天的课
+			if allDay {
+				until = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		}
+	}
+	limit, _ := strconv.Atoi(rule["COUNT"])
+	if until.IsZero() && limit == 0 {
+		return schedules, -1, ""
+	}
+
+	excluded := make(map[string]bool, len(event.ExDates))
+	for _, exdate := range event.ExDates {
+		excluded[exdate.Format("2006-01-02")] = true
+	}
+
+	probe := make([]models.CourseSchedule, len(schedules))
+	for i, schedule := range schedules {
+		startDate := schedule.StartDate
+		probe[i] = models.CourseSchedule{
+			Weekday:     schedule.Weekday,
+			StartDate:   &startDate,
+			Recurrence:  schedule.Recurrence,
+			Interval:    schedule.Interval,
+			WeekOfMonth: schedule.WeekOfMonth,
+		}
+	}
+
+	// COUNT 包含被EXDATE排除的次数，课时数不包含
+	generated, count := 0, 0
+	last := event.Start
+	horizon := event.Start.AddDate(maxImportYears, 0, 0)
+	for day := event.Start; generated < maxImportOccurrences && day.Before(horizon); day = day.AddDate(0, 0, 1) {
+		if !until.IsZero() && day.After(until) {
+			break
+		}
+		if limit > 0 && generated >= limit {
+			break
+		}
+		for _, schedule := range probe {
+			if schedule.OccursOn(day) {
+				generated++
+				if !excluded[day.Format("2006-01-02")] {
+					count++
+				}
+				last = day
+				break
+			}
+		}
+	}
+
+	endDate := last.Format("2006-01-02")
+	for i := range schedules {
+		schedules[i].EndDate = endDate
+	}
+	return schedules, count, ""
+}
+
+// scheduleFromSingleEvents 将同一时间段的单次事件合并为一个每周（或每N周）排课
+// 返回排课建议以及范围内缺少的日期（作为例外日期）
+func scheduleFromSingleEvents(events []ICalEvent) (models.CourseScheduleRequest, []string) {
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	first, last := events[0], events[len(events)-1]
+
+	// 相邻两次的周数差的最大公约数作为重复间隔
+	interval := 0
+	for i := 1; i < len(events); i++ {
+		weeks := int(events[i].Start.Sub(events[i-1].Start).Hours()/24+0.5) / 7
+		interval = gcd(interval, weeks)
+	}
+	if interval < 1 {
+		interval = 1
+	}
+
+	schedule := models.CourseScheduleRequest{
+		Weekday:    models.IsoWeekday(first.Start),
+		StartTime:  first.Start.Format("15:04"),
+		EndTime:    first.End.Format("15:04"),
+		Location:   first.Location,
+		StartDate:  first.Start.Format("2006-01-02"),
+		EndDate:    last.Start.Format("2006-01-02"),
+		Recurrence: models.RecurrenceWeekly,
+		Interval:   interval,
+	}
+
+	present := make(map[string]bool, len(events))
+	for _, event := range events {
+		present[event.Start.Format("2006-01-02")] = true
+	}
+	var missing []string
+	for day := first.Start; !day.After(last.Start); day = day.AddDate(0, 0, 7*interval) {
+		if date := day.Format("2006-01-02"); !present[date] {
+			missing = append(missing, date)
+		}
+	}
+	return schedule, missing
+}
+
+// unfoldICalLines 读取并展开折行
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseICalLine 解析内容行为 名称、参数、值
+func parseICalLine(line string) (string, map[string]string, string) {
+	inQuote := false
+	split := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r == ':' && !inQuote {
+			split = i
+			break
+		}
+	}
+	if split < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	head, value := line[:split], line[split+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseICalTime 解析日期或日期时间，返回是否为全天日期
+func parseICalTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.In(loc), false, err
+	}
+
+	source := loc
+	if tzid := params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			source = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, source)
+	return t.In(loc), false, err
+}
+
+// parseICalDuration 解析 DURATION（仅支持周/天/时/分/秒）
+func parseICalDuration(value string) (time.Duration, error) {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var total time.Duration
+	number := ""
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+		case r == 'T':
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("无效的时长: %s", value)
+			}
+			number = ""
+			switch r {
+			case 'W':
+				total += time.Duration(n) * 7 * 24 * time.Hour
+			case 'D':
+				total += time.Duration(n) * 24 * time.Hour
+			case 'H':
+				total += time.Duration(n) * time.Hour
+			case 'M':
+				total += time.Duration(n) * time.Minute
+			case 'S':
+				total += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("无效的时长: %s", value)
+			}
+		}
+	}
+	return total, nil
+}
+
+// parseRRule 解析重复规则
+func parseRRule(value string) map[string]string {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		if kv := strings.SplitN(part, "=", 2); len(kv) == 2 {
+			rule[strings.ToUpper(kv[0])] = strings.ToUpper(kv[1])
+		}
+	}
+	return rule
+}
+
+// unescapeICalText 反转义文本值
+func unescapeICalText(text string) string {
+	replacer := strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	)
+	return replacer.Replace(text)
+}
+
+// icalWeekdayIndex 将星期缩写转换为1-7（忽略前缀的序号）
+func icalWeekdayIndex(day string) int {
+	day = strings.ToUpper(strings.TrimSpace(day))
+	if len(day) < 2 {
+		return 0
+	}
+	day = day[len(day)-2:]
+	for i, name := range icalWeekdays {
+		if i > 0 && name == day {
+			return i
+		}
+	}
+	return 0
+}
+
+// gcd 最大公约数
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func proposeFromRRule(t *testing.T, rrule string) CourseImportProposal {
+	t.Helper()
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:test-1",
+		"SUMMARY:钢琴课",
+		"DTSTART:20260105T180000",
+		"DTEND:20260105T190000",
+		"RRULE:" + rrule,
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	events, err := ParseICalendar(strings.NewReader(ics), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseICalendar: %v", err)
+	}
+
+	done := make(chan []CourseImportProposal, 1)
+	go func() { done <- ProposeCourseImport(events) }()
+	select {
+	case proposals := <-done:
+		if len(proposals) != 1 {
+			t.Fatalf("got %d proposals, want 1", len(proposals))
+		}
+		return proposals[0]
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ProposeCourseImport did not finish for RRULE %s", rrule)
+	}
+	return CourseImportProposal{}
+}
+
+func TestSchedulesFromRRule(t *testing.T) {
+	tests := []struct {
+		name        string
+		rrule       string
+		schedules   int
+		sessions    int
+		weekOfMonth int
+		endDate     string
+	}{
+		{"weekly count", "FREQ=WEEKLY;COUNT=10", 1, 10, 0, "2026-03-09"},
+		{"weekly two days", "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4", 2, 4, 0, "2026-01-14"},
+		{"weekly until", "FREQ=WEEKLY;INTERVAL=2;UNTIL=20260202T235959", 1, 3, 0, "2026-02-02"},
+		{"date only until includes that day", "FREQ=WEEKLY;UNTIL=20260119", 1, 3, 0, "2026-01-19"},
+		{"until before the class time", "FREQ=WEEKLY;UNTIL=20260119T090000", 1, 2, 0, "2026-01-12"},
+		{"monthly first monday", "FREQ=MONTHLY;BYDAY=1MO;COUNT=3", 1, 3, 1, "2026-03-02"},
+		{"monthly last monday", "FREQ=MONTHLY;BYDAY=-1MO;COUNT=2", 1, 2, -1, "2026-02-23"},
+		{"monthly sixth monday", "FREQ=MONTHLY;BYDAY=6MO;COUNT=3", 0, 0, 0, ""},
+		{"monthly second to last", "FREQ=MONTHLY;BYDAY=-2MO;COUNT=3", 0, 0, 0, ""},
+		{"monthly tenth monday", "FREQ=MONTHLY;BYDAY=10MO;COUNT=3", 0, 0, 0, ""},
+		{"yearly", "FREQ=YEARLY;COUNT=3", 0, 0, 0, ""},
+		// 间隔很大时只展开到maxImportYears年内
+		{"huge interval", "FREQ=WEEKLY;INTERVAL=1000;COUNT=3", 1, 1, 0, "2026-01-05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposal := proposeFromRRule(t, tt.rrule)
+			schedules := proposal.Course.Schedules
+			if len(schedules) != tt.schedules {
+				t.Fatalf("got %d schedules, want %d (warnings: %v)", len(schedules), tt.schedules, proposal.Warnings)
+			}
+			if tt.schedules == 0 {
+				if len(proposal.Warnings) == 0 {
+					t.Fatalf("expected an unsupported rule warning")
+				}
+				return
+			}
+			if proposal.Course.RegularSessions != tt.sessions {
+				t.Errorf("sessions = %d, want %d", proposal.Course.RegularSessions, tt.sessions)
+			}
+			if schedules[0].WeekOfMonth != tt.weekOfMonth {
+				t.Errorf("weekOfMonth = %d, want %d", schedules[0].WeekOfMonth, tt.weekOfMonth)
+			}
+			if schedules[0].EndDate != tt.endDate {
+				t.Errorf("endDate = %s, want %s", schedules[0].EndDate, tt.endDate)
+			}
+		})
+	}
+}
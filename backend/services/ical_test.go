@@ -0,0 +1,107 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func calendarForZone(t *testing.T, timezone string) string {
+	t.Helper()
+	startDate := "2026-01-05"
+	course := models.Course{
+		ID:   1,
+		Name: "钢琴课",
+		Schedules: []models.CourseSchedule{{
+			ID:        1,
+			Weekday:   1,
+			StartTime: "18:00",
+			EndTime:   "19:00",
+			IsActive:  true,
+			StartDate: &startDate,
+			Interval:  1,
+			Timezone:  timezone,
+		}},
+	}
+	return RenderCalendar("课程", []models.Course{course}, nil, nil, time.Local)
+}
+
+func TestRenderCalendarTimezones(t *testing.T) {
+	tests := []struct {
+		timezone string
+		contains []string
+		absent   []string
+	}{
+		{
+			timezone: "America/New_York",
+			contains: []string{
+				"BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n",
+				"BEGIN:DAYLIGHT\r\nDTSTART:20260308T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\nTZNAME:EDT\r\nEND:DAYLIGHT",
+				"BEGIN:STANDARD\r\nDTSTART:20261101T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\nTZNAME:EST\r\nEND:STANDARD",
+				"DTSTART;TZID=America/New_York:20260105T180000",
+			},
+		},
+		{
+			timezone: "Asia/Shanghai",
+			contains: []string{
+				"BEGIN:VTIMEZONE\r\nTZID:Asia/Shanghai\r\nBEGIN:STANDARD\r\nDTSTART:20260101T000000\r\nTZOFFSETFROM:+0800\r\nTZOFFSETTO:+0800\r\nTZNAME:CST\r\nEND:STANDARD\r\nEND:VTIMEZONE",
+				"DTSTART;TZID=Asia/Shanghai:20260105T180000",
+			},
+			absent: []string{"BEGIN:DAYLIGHT"},
+		},
+		{
+			timezone: "Asia/Kolkata",
+			contains: []string{"TZOFFSETTO:+0530"},
+		},
+		{
+			timezone: "UTC",
+			contains: []string{"DTSTART:20260105T180000Z"},
+			absent:   []string{"BEGIN:VTIMEZONE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			calendar := calendarForZone(t, tt.timezone)
+			for _, want := range tt.contains {
+				if !strings.Contains(calendar, want) {
+					t.Errorf("calendar missing %q\n%s", want, calendar)
+				}
+			}
+			for _, unwanted := range tt.absent {
+				if strings.Contains(calendar, unwanted) {
+					t.Errorf("calendar should not contain %q", unwanted)
+				}
+			}
+			// 每个TZID都必须有对应的VTIMEZONE
+			for _, line := range strings.Split(calendar, "\r\n") {
+				if i := strings.Index(line, ";TZID="); i >= 0 {
+					tzid := strings.SplitN(line[i+len(";TZID="):], ":", 2)[0]
+					if !strings.Contains(calendar, "TZID:"+tzid+"\r\n") {
+						t.Errorf("TZID %s has no VTIMEZONE", tzid)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestICalUTCOffset(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{8 * 3600, "+0800"},
+		{-5 * 3600, "-0500"},
+		{5*3600 + 1800, "+0530"},
+		{-(3*3600 + 1800), "-0330"},
+		{0, "+0000"},
+		{-(17*60 + 30), "-001730"},
+	}
+	for _, tt := range tests {
+		if got := icalUTCOffset(tt.seconds); got != tt.want {
+			t.Errorf("icalUTCOffset(%d) = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+}
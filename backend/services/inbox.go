@@ -0,0 +1,38 @@
+package services
+
+import (
+	"encoding/json"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// saveInboxMessage 保存站内通知
+func saveInboxMessage(db *gorm.DB, notification *Notification) error {
+	data := ""
+	if notification.Data != nil {
+		raw, err := json.Marshal(notification.Data)
+		if err != nil {
+			return err
+		}
+		data = string(raw)
+	}
+
+	message := models.InboxMessage{
+		UserID: notification.UserID,
+		Type:   notification.Type,
+		Title:  truncateText(notification.Title, 200),
+		Body:   truncateText(notification.Body, 500),
+		Data:   data,
+	}
+	return db.Create(&message).Error
+}
+
+// GetUnreadInboxCount 获取用户未读站内通知数量
+func GetUnreadInboxCount(db *gorm.DB, userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&models.InboxMessage{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
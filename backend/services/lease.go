@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"course-management-backend/models"
+	"course-management-backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewInstanceID 生成当前进程的实例标识（主机名:进程号:随机串），用作任务租约的持有者
+func NewInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix, err := utils.GenerateRandomToken(4)
+	if err != nil {
+		suffix = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), suffix)
+}
+
+// AcquireJobLease 获取或续期任务租约，租约被其他实例持有且未过期时返回 false
+func AcquireJobLease(db *gorm.DB, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	leaseUntil := now.Add(ttl)
+
+	created := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.JobLease{
+		Name:       name,
+		Owner:      owner,
+		LeaseUntil: leaseUntil,
+	})
+	if created.Error != nil {
+		return false, created.Error
+	}
+	if created.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 已存在：自己持有则续期，过期则接管
+	updated := db.Model(&models.JobLease{}).
+		Where("name = ? AND (owner = ? OR lease_until < ?)", name, owner, now).
+		Updates(map[string]interface{}{"owner": owner, "lease_until": leaseUntil})
+	if updated.Error != nil {
+		return false, updated.Error
+	}
+	return updated.RowsAffected > 0, nil
+}
+
+// ReleaseJobLease 释放自己持有的任务租约，便于其他实例立即接管
+func ReleaseJobLease(db *gorm.DB, name, owner string) error {
+	return db.Where("name = ? AND owner = ?", name, owner).Delete(&models.JobLease{}).Error
+}
@@ -1,54 +1,129 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"course-management-backend/config"
+	"course-management-backend/database"
 	"course-management-backend/models"
+
+	"gorm.io/gorm/clause"
 )
 
 // NotificationManager 通知管理器
+// 订阅保存在数据库中，mu 保证HTTP请求与定时任务并发修改订阅时的一致性
 type NotificationManager struct {
-	subscriptions map[uint][]map[string]interface{} // userID -> subscriptions
+	mu sync.RWMutex
 }
 
-var notificationManager = &NotificationManager{
-	subscriptions: make(map[uint][]map[string]interface{}),
+var notificationManager = &NotificationManager{}
+
+// DefaultReminderLeadTimes 默认提醒提前量，读取REMINDER_LEAD_MINUTES（逗号分隔的分钟数）
+func DefaultReminderLeadTimes() []time.Duration {
+	var leadTimes []time.Duration
+	for _, part := range strings.Split(config.GetEnv("REMINDER_LEAD_MINUTES", "1440"), ",") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || minutes <= 0 {
+			continue
+		}
+		leadTimes = append(leadTimes, time.Duration(minutes)*time.Minute)
+	}
+	return leadTimes
 }
 
-// SaveNotificationSubscription 保存推送订阅
-func SaveNotificationSubscription(userID uint, subscription map[string]interface{}) {
-	if notificationManager.subscriptions[userID] == nil {
-		notificationManager.subscriptions[userID] = make([]map[string]interface{}, 0)
+// SaveNotificationSubscription 保存推送订阅，endpoint已存在时更新密钥和所属用户
+func SaveNotificationSubscription(userID uint, req models.PushSubscriptionRequest, userAgent string) (*models.PushSubscription, error) {
+	notificationManager.mu.Lock()
+	defer notificationManager.mu.Unlock()
+
+	subscription := models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		UserAgent: userAgent,
 	}
-	
-	notificationManager.subscriptions[userID] = append(
-		notificationManager.subscriptions[userID], 
-		subscription,
-	)
-	
+	err := database.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "updated_at"}),
+	}).Create(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+
 	log.Printf("用户 %d 已保存推送订阅", userID)
+	return &subscription, nil
 }
 
-// RemoveNotificationSubscription 移除推送订阅
-func RemoveNotificationSubscription(userID uint, subscription map[string]interface{}) {
-	if subscriptions, exists := notificationManager.subscriptions[userID]; exists {
-		for i, sub := range subscriptions {
-			if sub["endpoint"] == subscription["endpoint"] {
-				notificationManager.subscriptions[userID] = append(
-					subscriptions[:i],
-					subscriptions[i+1:]...)
-				break
-			}
-		}
-	}
+// RemoveNotificationSubscription 移除用户的推送订阅
+func RemoveNotificationSubscription(userID uint, endpoint string) error {
+	notificationManager.mu.Lock()
+	defer notificationManager.mu.Unlock()
+
+	return database.GetDB().
+		Where("user_id = ? AND endpoint = ?", userID, endpoint).
+		Delete(&models.PushSubscription{}).Error
+}
+
+// removeExpiredSubscription 移除推送服务返回已失效的订阅
+func removeExpiredSubscription(subscriptionID uint) error {
+	notificationManager.mu.Lock()
+	defer notificationManager.mu.Unlock()
+
+	return database.GetDB().Delete(&models.PushSubscription{}, subscriptionID).Error
+}
+
+// markSubscriptionSuccess 记录订阅最近一次推送成功的时间
+func markSubscriptionSuccess(subscriptionID uint) error {
+	notificationManager.mu.Lock()
+	defer notificationManager.mu.Unlock()
+
+	return database.GetDB().Model(&models.PushSubscription{}).
+		Where("id = ?", subscriptionID).
+		Update("last_success_at", time.Now()).Error
 }
 
 // SendCourseReminder 发送课程提醒
 func SendCourseReminder(course *models.Course, date string) error {
 	title := "课程提醒"
 	body := buildReminderMessage(course, date)
-	
+	data := map[string]interface{}{
+		"type":      "course_reminder",
+		"courseId":  course.ID,
+		"courseName": course.Name,
+		"date":      date,
+		"action":    "reminder",
+	}
+
+	// 课程开始后不再重试提醒（上课时间按排课所在时区计算）
+	loc := CourseLocation(course, time.Local)
+	if len(course.Schedules) > 0 {
+		loc = ScheduleLocation(course, &course.Schedules[0], time.Local)
+	}
+	var expiresAt *time.Time
+	day, dayErr := time.ParseInLocation("2006-01-02", date, loc)
+	if dayErr == nil && len(course.Schedules) > 0 {
+		if start, err := combineDateTime(day, course.Schedules[0].StartTime); err == nil {
+			expiresAt = &start
+		}
+	}
+
+	// 快捷操作：点击推送中的"上课"/"请假"时无需登录即可记录出勤
+	var action *AttendanceActionTarget
+	if dayErr == nil {
+		startsAt := day.AddDate(0, 0, 1)
+		if expiresAt != nil {
+			startsAt = *expiresAt
+		}
+		action = &AttendanceActionTarget{CourseID: course.ID, Date: date, StartsAt: startsAt}
+	}
+
 	notification := map[string]interface{}{
 		"title": title,
 		"body":  body,
@@ -59,38 +134,191 @@ func SendCourseReminder(course *models.Course, date string) error {
 			{"action": "attend", "title": "上课"},
 			{"action": "absent", "title": "请假"},
 		},
-		"data": map[string]interface{}{
-			"type":      "course_reminder",
-			"courseId":  course.ID,
-			"courseName": course.Name,
-			"date":      date,
-			"action":    "reminder",
-		},
+		"data": data,
+	}
+
+	email, err := renderReminderEmail(course, date)
+	if err != nil {
+		return fmt.Errorf("渲染提醒邮件失败: %v", err)
+	}
+
+	// 发送到课程所属用户启用的所有渠道
+	return DispatchNotification(&Notification{
+		UserID:    course.UserID,
+		Type:      "course_reminder",
+		Title:     title,
+		Body:      body,
+		Data:      data,
+		Push:      notification,
+		Email:     email,
+		ExpiresAt: expiresAt,
+		Action:    action,
+	})
+}
+
+// pushPayload 生成推送负载，有快捷操作时在data中附加token
+// token只出现在推送中，发件箱保存的通知不含token，重试时沿用同一次课未使用的token
+func pushPayload(notification *Notification) map[string]interface{} {
+	action := notification.Action
+	if action == nil {
+		return notification.Push
+	}
+
+	token, err := IssueAttendanceActionToken(database.GetDB(), notification.UserID, action.CourseID, action.Date, action.StartsAt)
+	if err != nil {
+		log.Printf("生成快捷操作token失败 (课程: %d): %v", action.CourseID, err)
+		return notification.Push
+	}
+
+	data := map[string]interface{}{}
+	if existing, ok := notification.Push["data"].(map[string]interface{}); ok {
+		for key, value := range existing {
+			data[key] = value
+		}
 	}
+	data["actionToken"] = token
+	data["actionUrl"] = "/api/attendance/actions"
 
-	// 发送给课程的所有相关用户
-	return sendNotificationToUser(course.UserID, notification)
+	payload := make(map[string]interface{}, len(notification.Push))
+	for key, value := range notification.Push {
+		payload[key] = value
+	}
+	payload["data"] = data
+	return payload
 }
 
 // SendConsumptionConfirmation 发送消课确认通知
 func SendConsumptionConfirmation(course *models.Course, attendance *models.AttendanceRecord, consumption *models.SessionConsumption) error {
 	title := "消课成功"
 	body := fmt.Sprintf("%s 已消耗 %d 个%s", course.Name, consumption.SessionsConsumed, consumption.GetSessionTypeText())
+	data := map[string]interface{}{
+		"type":         "consumption_confirmation",
+		"courseId":     course.ID,
+		"attendanceId": attendance.ID,
+		"consumptionId": consumption.ID,
+	}
 	
 	notification := map[string]interface{}{
 		"title": title,
 		"body":  body,
 		"icon":  "/icon-192x192.png",
 		"tag":   fmt.Sprintf("consumption-%d", consumption.ID),
-		"data": map[string]interface{}{
-			"type":         "consumption_confirmation",
-			"courseId":     course.ID,
-			"attendanceId": attendance.ID,
-			"consumptionId": consumption.ID,
-		},
+		"data": data,
+	}
+
+	email, err := renderConsumptionEmail(course, attendance, consumption)
+	if err != nil {
+		return fmt.Errorf("渲染消课邮件失败: %v", err)
+	}
+
+	return DispatchNotification(&Notification{
+		UserID: course.UserID,
+		Type:   "consumption_confirmation",
+		Title:  title,
+		Body:   body,
+		Data:   data,
+		Push:   notification,
+		Email:  email,
+	})
+}
+
+// SendReconcileSummary 发送出勤自动处理汇总通知
+func SendReconcileSummary(userID uint, results []ReconciledAttendance) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	absent, attended, unconsumed := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Status == models.AttendanceNoShow:
+			absent++
+		case result.Consumed:
+			attended++
+		default:
+			unconsumed++
+		}
 	}
 
-	return sendNotificationToUser(course.UserID, notification)
+	title := "出勤自动处理"
+	body := fmt.Sprintf("%d 节课未确认出勤，已按课程设置处理", len(results))
+	var parts []string
+	if absent > 0 {
+		parts = append(parts, fmt.Sprintf("%d 节标记缺席", absent))
+	}
+	if attended > 0 {
+		parts = append(parts, fmt.Sprintf("%d 节标记出勤并消课", attended))
+	}
+	if unconsumed > 0 {
+		parts = append(parts, fmt.Sprintf("%d 节标记出勤但课时不足未消课", unconsumed))
+	}
+	body += "：" + strings.Join(parts, "，")
+
+	data := map[string]interface{}{
+		"type":    "attendance_reconciled",
+		"results": results,
+	}
+
+	notification := map[string]interface{}{
+		"title": title,
+		"body":  body,
+		"icon":  "/icon-192x192.png",
+		"tag":   fmt.Sprintf("reconcile-%d-%s", userID, results[0].Date),
+		"data":  data,
+	}
+
+	email, err := renderReconcileEmail(results)
+	if err != nil {
+		return fmt.Errorf("渲染出勤处理邮件失败: %v", err)
+	}
+
+	return DispatchNotification(&Notification{
+		UserID: userID,
+		Type:   "attendance_reconciled",
+		Title:  title,
+		Body:   body,
+		Data:   data,
+		Push:   notification,
+		Email:  email,
+	})
+}
+
+// SendCourseAlert 发送课时不足/即将到期提醒
+func SendCourseAlert(course *models.Course, alert *models.CourseAlert) error {
+	title := "课时不足提醒"
+	if alert.Kind == models.CourseAlertExpiring {
+		title = "课包到期提醒"
+	}
+	body := alert.Message
+	data := map[string]interface{}{
+		"type":     "course_alert",
+		"kind":     alert.Kind,
+		"courseId": course.ID,
+		"alertId":  alert.ID,
+	}
+
+	notification := map[string]interface{}{
+		"title": title,
+		"body":  body,
+		"icon":  "/icon-192x192.png",
+		"tag":   fmt.Sprintf("course-alert-%d", alert.ID),
+		"data":  data,
+	}
+
+	email, err := renderCourseAlertEmail(course, title, body)
+	if err != nil {
+		return fmt.Errorf("渲染课程提醒邮件失败: %v", err)
+	}
+
+	return DispatchNotification(&Notification{
+		UserID: course.UserID,
+		Type:   "course_alert",
+		Title:  title,
+		Body:   body,
+		Data:   data,
+		Push:   notification,
+		Email:  email,
+	})
 }
 
 // buildReminderMessage 构建提醒消息内容
@@ -121,56 +349,91 @@ func buildReminderMessage(course *models.Course, date string) string {
 	return message
 }
 
-// sendNotificationToUser 发送通知给指定用户
-func sendNotificationToUser(userID uint, notification map[string]interface{}) error {
-	subscriptions, exists := notificationManager.subscriptions[userID]
-	if !exists || len(subscriptions) == 0 {
-		log.Printf("用户 %d 没有推送订阅", userID)
-		return fmt.Errorf("用户没有推送订阅")
+// sendNotificationToUser 发送通知给指定用户尚未成功送达的订阅设备（目标为订阅ID），返回本次成功的订阅
+// 用户没有订阅时 sent 为 false；任一设备失败都返回错误，已失效的订阅会被自动移除
+func sendNotificationToUser(userID uint, notification map[string]interface{}, delivered map[string]bool) (bool, []string, error) {
+	subscriptions, err := GetSubscriptions(userID)
+	if err != nil {
+		return false, nil, fmt.Errorf("查询推送订阅失败: %v", err)
+	}
+	if len(subscriptions) == 0 {
+		return false, nil, nil
 	}
 
-	// 在实际实现中，这里会使用 Web Push Protocol 发送推送
-	// 这里只是模拟实现
-	log.Printf("模拟发送推送通知 - 用户 %d: %+v", userID, map[string]interface{}{
-		"title": notification["title"],
-		"body":  notification["body"],
-	})
-
-	// 实际实现示例（需要 web-push 库）:
-	/*
-	import "github.com/SherClockHolmes/webpush-go"
-	
+	pending := make([]models.PushSubscription, 0, len(subscriptions))
 	for _, subscription := range subscriptions {
-		s := &webpush.Subscription{
-			Endpoint: subscription["endpoint"].(string),
-			Keys: webpush.Keys{
-				Auth: subscription["keys"].(map[string]interface{})["auth"].(string),
-				P256dh: subscription["keys"].(map[string]interface{})["p256dh"].(string),
-			},
+		if !delivered[strconv.FormatUint(uint64(subscription.ID), 10)] {
+			pending = append(pending, subscription)
 		}
-		
-		payload, _ := json.Marshal(notification)
-		
-		resp, err := webpush.SendNotification(s, payload, &webpush.Options{
-			VAPIDPublicKey:  getVAPIDPublicKey(),
-			VAPIDPrivateKey: getVAPIDPrivateKey(),
-			Subscriber:      getVAPIDEmail(),
-		})
-		
-		if err != nil && resp.StatusCode == 410 {
-			// 订阅失效，移除它
-			RemoveNotificationSubscription(userID, subscription)
+	}
+	if len(pending) == 0 {
+		return true, nil, nil
+	}
+
+	client, err := GetWebPushClient()
+	if err != nil {
+		return true, nil, err
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return true, nil, fmt.Errorf("序列化通知失败: %v", err)
+	}
+
+	succeeded, err := deliverPush(client, userID, pending, payload, markSubscriptionSuccess, removeExpiredSubscription)
+	targets := make([]string, len(succeeded))
+	for i, id := range succeeded {
+		targets[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	if err != nil {
+		return true, targets, fmt.Errorf("推送通知发送失败: %v", err)
+	}
+	log.Printf("已发送推送通知给用户 %d（%d/%d 个设备）", userID, len(succeeded), len(pending))
+	return true, targets, nil
+}
+
+// deliverPush 逐个订阅发送推送，成功时调用markSuccess，推送服务返回404/410时调用removeExpired
+// 返回成功送达的订阅；有设备发送失败时返回错误，已失效的订阅被移除后不再重试，只在没有任何设备送达时返回失效错误
+func deliverPush(client *WebPushClient, userID uint, subscriptions []models.PushSubscription, payload []byte, markSuccess, removeExpired func(subscriptionID uint) error) ([]uint, error) {
+	var succeeded []uint
+	var failures []string
+	var expiredErr error
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		err := client.Send(subscription, payload)
+		switch {
+		case err == nil:
+			succeeded = append(succeeded, subscription.ID)
+			if err := markSuccess(subscription.ID); err != nil {
+				log.Printf("更新订阅 %d 推送时间失败: %v", subscription.ID, err)
+			}
+		case errors.Is(err, ErrSubscriptionExpired):
+			log.Printf("用户 %d 的推送订阅 %d 已失效，自动移除", userID, subscription.ID)
+			if err := removeExpired(subscription.ID); err != nil {
+				log.Printf("移除失效订阅 %d 失败: %v", subscription.ID, err)
+			}
+			expiredErr = err
+		default:
+			log.Printf("发送推送给用户 %d 的订阅 %d 失败: %v", userID, subscription.ID, err)
+			failures = append(failures, fmt.Sprintf("订阅 %d: %v", subscription.ID, err))
 		}
 	}
-	*/
-	
-	return nil
+
+	if len(failures) > 0 {
+		return succeeded, errors.New(strings.Join(failures, "; "))
+	}
+	if len(succeeded) == 0 {
+		return nil, expiredErr
+	}
+	return succeeded, nil
 }
 
 // GetSubscriptions 获取用户的订阅信息
-func GetSubscriptions(userID uint) []map[string]interface{} {
-	if subscriptions, exists := notificationManager.subscriptions[userID]; exists {
-		return subscriptions
-	}
-	return make([]map[string]interface{}, 0)
-}
\ No newline at end of file
+func GetSubscriptions(userID uint) ([]models.PushSubscription, error) {
+	notificationManager.mu.RLock()
+	defer notificationManager.mu.RUnlock()
+
+	var subscriptions []models.PushSubscription
+	err := database.GetDB().Where("user_id = ?", userID).Order("id ASC").Find(&subscriptions).Error
+	return subscriptions, err
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Notification 一条待发送的通知，各渠道按需取用其中的内容
+// 会序列化保存到发件箱，重试时原样恢复
+type Notification struct {
+	UserID    uint                   `json:"userId"`
+	Type      string                 `json:"type"` // course_reminder / consumption_confirmation 等
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`  // 附加数据（课程ID、日期等）
+	Push      map[string]interface{} `json:"push,omitempty"`  // Web Push 负载
+	Email     EmailMessage           `json:"email"`           // 邮件内容（不含收件人）
+	ExpiresAt *time.Time             `json:"expiresAt,omitempty"` // 过期后不再重试（如课程已开始）
+	// Action 推送中附加快捷出勤操作的那次课，token只在推送时签发，不随通知保存或发送到其他渠道
+	Action *AttendanceActionTarget `json:"action,omitempty"`
+}
+
+// AttendanceActionTarget 通知快捷出勤操作对应的一次课
+type AttendanceActionTarget struct {
+	CourseID uint      `json:"courseId"`
+	Date     string    `json:"date"`
+	StartsAt time.Time `json:"startsAt"` // 上课时间，token在此后48小时过期
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	// Name 渠道名称
+	Name() string
+	// Notify 发送通知，用户未启用该渠道时返回 false, nil
+	Notify(notification *Notification) (bool, error)
+}
+
+// TargetNotifier 一个渠道下有多个发送目标（如多个Webhook、多个设备）
+// 发件箱按目标记录发送结果，重试时只发送尚未成功的目标
+type TargetNotifier interface {
+	Notifier
+	// NotifyTargets 发送到delivered以外的目标，返回本次成功的目标；任一目标失败时返回错误
+	// 用户没有可用目标时 sent 为 false
+	NotifyTargets(notification *Notification, delivered map[string]bool) (sent bool, succeeded []string, err error)
+}
+
+// ErrNoNotificationChannel 用户没有可用的通知渠道
+var ErrNoNotificationChannel = errors.New("用户没有可用的通知渠道")
+
+var notifierRegistry = struct {
+	mu        sync.RWMutex
+	notifiers []Notifier
+}{}
+
+func init() {
+	RegisterNotifier(pushNotifier{})
+	RegisterNotifier(emailNotifier{})
+	RegisterNotifier(NewWebhookNotifier(nil))
+}
+
+// RegisterNotifier 注册通知渠道，同名渠道会被替换
+func RegisterNotifier(notifier Notifier) {
+	notifierRegistry.mu.Lock()
+	defer notifierRegistry.mu.Unlock()
+
+	for i, existing := range notifierRegistry.notifiers {
+		if existing.Name() == notifier.Name() {
+			notifierRegistry.notifiers[i] = notifier
+			return
+		}
+	}
+	notifierRegistry.notifiers = append(notifierRegistry.notifiers, notifier)
+}
+
+// registeredNotifiers 获取已注册渠道的快照
+func registeredNotifiers() []Notifier {
+	notifierRegistry.mu.RLock()
+	defer notifierRegistry.mu.RUnlock()
+
+	return append([]Notifier(nil), notifierRegistry.notifiers...)
+}
+
+// findNotifier 按名称查找已注册的渠道
+func findNotifier(name string) Notifier {
+	for _, notifier := range registeredNotifiers() {
+		if notifier.Name() == name {
+			return notifier
+		}
+	}
+	return nil
+}
+
+// pushNotifier Web Push 渠道
+type pushNotifier struct{}
+
+// Name 渠道名称
+func (pushNotifier) Name() string { return "push" }
+
+// Notify 发送到用户所有订阅设备
+func (pushNotifier) Notify(notification *Notification) (bool, error) {
+	sent, _, err := sendNotificationToUser(notification.UserID, pushPayload(notification), nil)
+	return sent, err
+}
+
+// NotifyTargets 发送到用户尚未成功送达的订阅设备（目标为订阅ID）
+func (pushNotifier) NotifyTargets(notification *Notification, delivered map[string]bool) (bool, []string, error) {
+	return sendNotificationToUser(notification.UserID, pushPayload(notification), delivered)
+}
+
+// emailNotifier 邮件渠道
+type emailNotifier struct{}
+
+// Name 渠道名称
+func (emailNotifier) Name() string { return "email" }
+
+// Notify 用户开启邮件提醒时发送邮件
+func (emailNotifier) Notify(notification *Notification) (bool, error) {
+	return sendEmailToUser(notification.UserID, notification.Email)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"sort"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Occurrence 课程的一次具体上课
+type Occurrence struct {
+	Course   *models.Course
+	Schedule *models.CourseSchedule
+	Date     string    // 上课日期 YYYY-MM-DD
+	Start    time.Time // 开始时间
+	End      time.Time // 结束时间
+	Override *models.OccurrenceOverride // 调课/取消记录，正常上课时为nil
+}
+
+// Cancelled 是否已被机构取消
+func (o Occurrence) Cancelled() bool {
+	return o.Override != nil && o.Override.Action == models.OverrideCancel
+}
+
+// Rescheduled 是否为调课后的上课
+func (o Occurrence) Rescheduled() bool {
+	return o.Override != nil && o.Override.Action == models.OverrideReschedule
+}
+
+// CourseForReminder 返回仅包含本次排课的课程副本，用于构建提醒内容
+func (o Occurrence) CourseForReminder() *models.Course {
+	course := *o.Course
+	course.Schedules = []models.CourseSchedule{*o.Schedule}
+	return &course
+}
+
+// PreloadForOccurrences 预加载展开上课实例所需的关联（用户用于确定时区）
+func PreloadForOccurrences(db *gorm.DB) *gorm.DB {
+	return db.Preload("User").
+		Preload("Schedules", "is_active = ?", true).
+		Preload("Exceptions").
+		Preload("Overrides")
+}
+
+// LoadOccurrences 查询活跃课程并展开[from, to)时间范围内的上课实例（不含已取消的课）
+// userID为0时查询所有用户的课程
+func LoadOccurrences(db *gorm.DB, userID uint, from, to time.Time) ([]Occurrence, error) {
+	courses, err := loadActiveCourses(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ExpandOccurrences(courses, from, to), nil
+}
+
+// LoadAllOccurrences 查询活跃课程并展开上课实例，包含已被机构取消的课
+func LoadAllOccurrences(db *gorm.DB, userID uint, from, to time.Time) ([]Occurrence, error) {
+	courses, err := loadActiveCourses(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ExpandAllOccurrences(courses, from, to), nil
+}
+
+// loadActiveCourses 查询活跃课程并预加载展开所需的关联
+func loadActiveCourses(db *gorm.DB, userID uint) ([]models.Course, error) {
+	query := db.Where("is_active = ?", true)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var courses []models.Course
+	if err := PreloadForOccurrences(query).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	return courses, nil
+}
+
+// ExpandOccurrences 展开[from, to)时间范围内开始的上课实例，按开始时间排序
+// 已被机构取消的课不包含在内，调课按新时间展开
+// courses需通过PreloadForOccurrences预加载关联
+func ExpandOccurrences(courses []models.Course, from, to time.Time) []Occurrence {
+	var occurrences []Occurrence
+	for _, occurrence := range ExpandAllOccurrences(courses, from, to) {
+		if !occurrence.Cancelled() {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences
+}
+
+// ExpandAllOccurrences 展开上课实例，包含已被机构取消的课（用于展示）
+// 每个排课按自己的时区（排课时区 > 用户时区）展开，Date 为该时区的日期；未预加载用户时使用from的时区
+func ExpandAllOccurrences(courses []models.Course, from, to time.Time) []Occurrence {
+	var occurrences []Occurrence
+
+	for i := range courses {
+		course := &courses[i]
+
+		exceptions := make(map[string]bool, len(course.Exceptions))
+		for _, exception := range course.Exceptions {
+			exceptions[models.DateOnly(exception.Date)] = true
+		}
+
+		overrides := make(map[string]*models.OccurrenceOverride, len(course.Overrides))
+		for j := range course.Overrides {
+			override := &course.Overrides[j]
+			overrides[overrideKey(override.OriginalDate, override.OriginalStartTime)] = override
+		}
+
+		for j := range course.Schedules {
+			schedule := &course.Schedules[j]
+			if !schedule.IsActive {
+				continue
+			}
+
+			loc := ScheduleLocation(course, schedule, from.Location())
+			localFrom := from.In(loc)
+			firstDay := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, loc)
+			for day := firstDay; day.Before(to); day = day.AddDate(0, 0, 1) {
+				date := day.Format("2006-01-02")
+				if exceptions[date] || !schedule.OccursOn(day) {
+					continue
+				}
+
+				override := overrides[overrideKey(date, schedule.StartTime)]
+				if override != nil && override.Action == models.OverrideReschedule {
+					// 原时间不再上课，新时间单独展开
+					continue
+				}
+
+				occurrence, ok := newOccurrence(course, schedule, day, schedule.StartTime, schedule.EndTime)
+				if !ok || occurrence.Start.Before(from) || !occurrence.Start.Before(to) {
+					continue
+				}
+				occurrence.Override = override
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+
+		// 调课后的上课
+		for j := range course.Overrides {
+			override := &course.Overrides[j]
+			if override.Action != models.OverrideReschedule || override.NewDate == nil {
+				continue
+			}
+
+			schedule := rescheduledSchedule(course, override)
+			loc := ScheduleLocation(course, schedule, from.Location())
+			day, err := time.ParseInLocation("2006-01-02", models.DateOnly(*override.NewDate), loc)
+			if err != nil {
+				continue
+			}
+			schedule.Weekday = models.IsoWeekday(day)
+
+			occurrence, ok := newOccurrence(course, schedule, day, override.NewStartTime, override.NewEndTime)
+			if !ok || occurrence.Start.Before(from) || !occurrence.Start.Before(to) {
+				continue
+			}
+			occurrence.Override = override
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences
+}
+
+// FindOccurrences 查找课程在指定日期（排课所在时区的日期）的上课实例（包含已取消的课）
+func FindOccurrences(course *models.Course, date string) []Occurrence {
+	day, err := time.Parse("2006-01-02", models.DateOnly(date))
+	if err != nil {
+		return nil
+	}
+
+	// 各时区的同一日期都落在UTC的前后一天内
+	var occurrences []Occurrence
+	for _, occurrence := range ExpandAllOccurrences([]models.Course{*course}, day.AddDate(0, 0, -1), day.AddDate(0, 0, 2)) {
+		if occurrence.Date == models.DateOnly(date) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences
+}
+
+// newOccurrence 构建上课实例
+func newOccurrence(course *models.Course, schedule *models.CourseSchedule, day time.Time, startTime, endTime string) (Occurrence, bool) {
+	start, err := combineDateTime(day, startTime)
+	if err != nil {
+		return Occurrence{}, false
+	}
+	end, err := combineDateTime(day, endTime)
+	if err != nil {
+		return Occurrence{}, false
+	}
+	return Occurrence{
+		Course:   course,
+		Schedule: schedule,
+		Date:     day.Format("2006-01-02"),
+		Start:    start,
+		End:      end,
+	}, true
+}
+
+// rescheduledSchedule 构建调课后的排课信息，沿用原排课的地点、老师和时区
+// 原排课需开始时间相同且在原日期上课（星期、生效日期和重复规则都匹配），避免同一时间的其他排课被误用
+func rescheduledSchedule(course *models.Course, override *models.OccurrenceOverride) *models.CourseSchedule {
+	schedule := models.CourseSchedule{
+		CourseID: course.ID,
+		IsActive: true,
+	}
+	originalDay, err := time.Parse("2006-01-02", models.DateOnly(override.OriginalDate))
+	if err == nil {
+		for _, original := range course.Schedules {
+			if original.StartTime == override.OriginalStartTime && original.OccursOn(originalDay) {
+				schedule = original
+				break
+			}
+		}
+	}
+
+	schedule.StartTime = override.NewStartTime
+	schedule.EndTime = override.NewEndTime
+	if override.Location != "" {
+		schedule.Location = override.Location
+	}
+	return &schedule
+}
+
+// overrideKey 调课记录的定位键
+func overrideKey(date, startTime string) string {
+	return models.DateOnly(date) + " " + startTime
+}
+
+// DayRange 获取指定日期当天的起止时间
+func DayRange(day time.Time) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// combineDateTime 将日期与 HH:MM 时间合并
+func combineDateTime(day time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
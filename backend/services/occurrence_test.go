@@ -0,0 +1,249 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+// occurrenceKeys 将上课实例表示为 "上课日期 UTC开始时间"，便于比较
+func occurrenceKeys(occurrences []Occurrence) string {
+	keys := make([]string, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		keys = append(keys, occurrence.Date+" "+occurrence.Start.UTC().Format("2006-01-02T15:04Z"))
+	}
+	return strings.Join(keys, ", ")
+}
+
+func TestExpandOccurrences(t *testing.T) {
+	monday := models.CourseSchedule{Weekday: 1, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2026-01-05")}
+	wednesday := models.CourseSchedule{Weekday: 3, StartTime: "09:00", EndTime: "10:00", IsActive: true, StartDate: stringPtr("2026-01-05")}
+	january := [2]time.Time{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name   string
+		course models.Course
+		from   time.Time
+		to     time.Time
+		want   string
+	}{
+		{
+			name:   "weekly until end date",
+			course: models.Course{Schedules: []models.CourseSchedule{withEndDate(monday, "2026-01-12")}},
+			from:   january[0], to: january[1],
+			want: "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z",
+		},
+		{
+			name:   "exception dates are skipped",
+			course: models.Course{Schedules: []models.CourseSchedule{monday}, Exceptions: []models.ScheduleException{{Date: "2026-01-12T00:00:00Z"}}},
+			from:   january[0], to: january[1],
+			want: "2026-01-05 2026-01-05T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+		},
+		{
+			name:   "inactive schedules are skipped",
+			course: models.Course{Schedules: []models.CourseSchedule{inactiveSchedule(monday)}},
+			from:   january[0], to: january[1],
+			want: "",
+		},
+		{
+			name:   "schedules are merged by start time",
+			course: models.Course{Schedules: []models.CourseSchedule{monday, wednesday}},
+			from:   january[0], to: time.Date(2026, 1, 13, 0, 0, 0, 0, time.UTC),
+			want: "2026-01-05 2026-01-05T18:00Z, 2026-01-07 2026-01-07T09:00Z, 2026-01-12 2026-01-12T18:00Z",
+		},
+		{
+			name:   "range is half open on start time",
+			course: models.Course{Schedules: []models.CourseSchedule{monday}},
+			from:   time.Date(2026, 1, 5, 18, 30, 0, 0, time.UTC), to: time.Date(2026, 1, 19, 18, 0, 0, 0, time.UTC),
+			want: "2026-01-12 2026-01-12T18:00Z",
+		},
+		{
+			name: "user timezone across DST",
+			course: models.Course{
+				User:      models.User{ID: 1, Timezone: "America/New_York"},
+				Schedules: []models.CourseSchedule{{Weekday: 7, StartTime: "10:00", EndTime: "11:00", IsActive: true, StartDate: stringPtr("2026-03-01")}},
+			},
+			from: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+			want: "2026-03-01 2026-03-01T15:00Z, 2026-03-08 2026-03-08T14:00Z, 2026-03-15 2026-03-15T14:00Z",
+		},
+		{
+			name: "date is in the schedule timezone",
+			course: models.Course{
+				User:      models.User{ID: 1, Timezone: "Asia/Shanghai"},
+				Schedules: []models.CourseSchedule{{Weekday: 1, StartTime: "07:00", EndTime: "08:00", IsActive: true, StartDate: stringPtr("2026-01-05")}},
+			},
+			from: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), to: time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC),
+			want: "2026-01-05 2026-01-04T23:00Z",
+		},
+		{
+			name: "schedule timezone overrides user timezone",
+			course: models.Course{
+				User:      models.User{ID: 1, Timezone: "Asia/Shanghai"},
+				Schedules: []models.CourseSchedule{{Weekday: 1, StartTime: "09:00", EndTime: "10:00", IsActive: true, StartDate: stringPtr("2026-01-05"), Timezone: "Europe/London"}},
+			},
+			from: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), to: time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC),
+			want: "2026-01-05 2026-01-05T09:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := occurrenceKeys(ExpandOccurrences([]models.Course{tt.course}, tt.from, tt.to))
+			if got != tt.want {
+				t.Errorf("occurrences = [%s], want [%s]", got, tt.want)
+			}
+		})
+	}
+}
+
+func withEndDate(schedule models.CourseSchedule, endDate string) models.CourseSchedule {
+	schedule.EndDate = stringPtr(endDate)
+	return schedule
+}
+
+func inactiveSchedule(schedule models.CourseSchedule) models.CourseSchedule {
+	schedule.IsActive = false
+	return schedule
+}
+
+func TestExpandOccurrencesWithOverrides(t *testing.T) {
+	monday := models.CourseSchedule{Weekday: 1, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2025-12-01"), Location: "Room A", Instructor: "王老师"}
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		schedule  models.CourseSchedule
+		overrides []models.OccurrenceOverride
+		all       string // ExpandAllOccurrences
+		active    string // ExpandOccurrences（不含已取消的课）
+		location  string // 2026-01-14 上课实例的地点
+	}{
+		{
+			name:      "cancelled by provider",
+			schedule:  monday,
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-12", OriginalStartTime: "18:00", Action: models.OverrideCancel}},
+			all:       "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+			active:    "2026-01-05 2026-01-05T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+		},
+		{
+			name:     "rescheduled within range",
+			schedule: monday,
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-12T00:00:00Z", OriginalStartTime: "18:00", Action: models.OverrideReschedule,
+				NewDate: stringPtr("2026-01-14"), NewStartTime: "20:00", NewEndTime: "21:00", Location: "Room B"}},
+			all:      "2026-01-05 2026-01-05T18:00Z, 2026-01-14 2026-01-14T20:00Z, 2026-01-19 2026-01-19T18:00Z",
+			active:   "2026-01-05 2026-01-05T18:00Z, 2026-01-14 2026-01-14T20:00Z, 2026-01-19 2026-01-19T18:00Z",
+			location: "Room B",
+		},
+		{
+			name:     "rescheduled into range keeps location",
+			schedule: monday,
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2025-12-29", OriginalStartTime: "18:00", Action: models.OverrideReschedule,
+				NewDate: stringPtr("2026-01-14"), NewStartTime: "18:00", NewEndTime: "19:00"}},
+			all:      "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z, 2026-01-14 2026-01-14T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+			active:   "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z, 2026-01-14 2026-01-14T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+			location: "Room A",
+		},
+		{
+			name:     "rescheduled out of range",
+			schedule: monday,
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-19", OriginalStartTime: "18:00", Action: models.OverrideReschedule,
+				NewDate: stringPtr("2026-01-21"), NewStartTime: "18:00", NewEndTime: "19:00"}},
+			all:    "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z",
+			active: "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z",
+		},
+		{
+			name:      "override for another start time is ignored",
+			schedule:  monday,
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-12", OriginalStartTime: "17:00", Action: models.OverrideCancel}},
+			all:       "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+			active:    "2026-01-05 2026-01-05T18:00Z, 2026-01-12 2026-01-12T18:00Z, 2026-01-19 2026-01-19T18:00Z",
+		},
+		{
+			name:     "rescheduled in the schedule timezone",
+			schedule: models.CourseSchedule{Weekday: 1, StartTime: "09:00", EndTime: "10:00", IsActive: true, StartDate: stringPtr("2026-01-12"), EndDate: stringPtr("2026-01-12"), Timezone: "Asia/Shanghai"},
+			overrides: []models.OccurrenceOverride{{OriginalDate: "2026-01-12", OriginalStartTime: "09:00", Action: models.OverrideReschedule,
+				NewDate: stringPtr("2026-01-14"), NewStartTime: "07:00", NewEndTime: "08:00"}},
+			all:    "2026-01-14 2026-01-13T23:00Z",
+			active: "2026-01-14 2026-01-13T23:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			course := models.Course{Schedules: []models.CourseSchedule{tt.schedule}, Overrides: tt.overrides}
+
+			all := ExpandAllOccurrences([]models.Course{course}, from, to)
+			if got := occurrenceKeys(all); got != tt.all {
+				t.Errorf("all = [%s], want [%s]", got, tt.all)
+			}
+			if got := occurrenceKeys(ExpandOccurrences([]models.Course{course}, from, to)); got != tt.active {
+				t.Errorf("active = [%s], want [%s]", got, tt.active)
+			}
+
+			for _, occurrence := range all {
+				if occurrence.Override == nil {
+					continue
+				}
+				switch occurrence.Override.Action {
+				case models.OverrideCancel:
+					if !occurrence.Cancelled() || occurrence.Rescheduled() {
+						t.Errorf("%s: cancelled = %v, rescheduled = %v", occurrence.Date, occurrence.Cancelled(), occurrence.Rescheduled())
+					}
+				case models.OverrideReschedule:
+					day, _ := time.Parse("2006-01-02", occurrence.Date)
+					if !occurrence.Rescheduled() || occurrence.Schedule.Weekday != models.IsoWeekday(day) {
+						t.Errorf("%s: rescheduled = %v, weekday = %d", occurrence.Date, occurrence.Rescheduled(), occurrence.Schedule.Weekday)
+					}
+					if tt.location != "" && occurrence.Schedule.Location != tt.location {
+						t.Errorf("%s: location = %s, want %s", occurrence.Date, occurrence.Schedule.Location, tt.location)
+					}
+					if occurrence.Schedule.Instructor != tt.schedule.Instructor {
+						t.Errorf("%s: instructor = %s, want %s", occurrence.Date, occurrence.Schedule.Instructor, tt.schedule.Instructor)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRescheduledSchedule(t *testing.T) {
+	// 周一和周三都在18:00上课，周一的排课到01-12结束后改为周一19:00
+	monday := models.CourseSchedule{ID: 1, Weekday: 1, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2026-01-05"), EndDate: stringPtr("2026-01-12"), Location: "Room A"}
+	wednesday := models.CourseSchedule{ID: 2, Weekday: 3, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2026-01-05"), Location: "Room C"}
+	later := models.CourseSchedule{ID: 3, Weekday: 1, StartTime: "18:00", EndTime: "19:00", IsActive: true, StartDate: stringPtr("2026-01-19"), Location: "Room D"}
+	course := &models.Course{ID: 5, Schedules: []models.CourseSchedule{monday, wednesday, later}}
+
+	tests := []struct {
+		name         string
+		originalDate string
+		startTime    string
+		scheduleID   uint
+		location     string
+	}{
+		{"matches weekday", "2026-01-07", "18:00", 2, "Room C"},
+		{"matches date range", "2026-01-12", "18:00", 1, "Room A"},
+		{"later schedule on the same weekday", "2026-01-26", "18:00", 3, "Room D"},
+		{"no schedule on that day", "2026-01-08", "18:00", 0, ""},
+		{"another start time", "2026-01-07", "09:00", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override := &models.OccurrenceOverride{OriginalDate: tt.originalDate, OriginalStartTime: tt.startTime, Action: models.OverrideReschedule,
+				NewDate: stringPtr("2026-01-30"), NewStartTime: "20:00", NewEndTime: "21:00"}
+			schedule := rescheduledSchedule(course, override)
+			if schedule.ID != tt.scheduleID || schedule.Location != tt.location {
+				t.Errorf("schedule = %d (%s), want %d (%s)", schedule.ID, schedule.Location, tt.scheduleID, tt.location)
+			}
+			if schedule.StartTime != "20:00" || schedule.EndTime != "21:00" {
+				t.Errorf("time = %s-%s, want 20:00-21:00", schedule.StartTime, schedule.EndTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 发件箱重试参数
+const (
+	outboxMaxAttempts = 6
+	outboxBaseBackoff = time.Minute
+	outboxMaxBackoff  = time.Hour
+	outboxBatchSize   = 100
+	outboxLease       = 5 * time.Minute // 发送中的记录在此期间不会被重试任务领取
+)
+
+// outboxNoChannel 用户没有任何可用渠道时记录的渠道名
+const outboxNoChannel = "none"
+
+// DispatchNotification 保存站内通知并发送到用户启用的所有渠道，任一渠道成功即视为成功
+// 每个渠道的发送都记录在发件箱中，失败的由 RetryPendingNotifications 按指数退避重试
+func DispatchNotification(notification *Notification) error {
+	db := database.GetDB()
+
+	// 站内通知始终保存，不依赖推送是否可用
+	if err := saveInboxMessage(db, notification); err != nil {
+		log.Printf("保存站内通知失败: %v", err)
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("序列化通知失败: %v", err)
+	}
+
+	var errs []string
+	attempted := false
+	delivered := false
+
+	for _, notifier := range registeredNotifiers() {
+		leaseUntil := time.Now().Add(outboxLease)
+		entry := models.NotificationOutbox{
+			UserID:        notification.UserID,
+			Type:          notification.Type,
+			Channel:       notifier.Name(),
+			Title:         truncateText(notification.Title, 200),
+			Body:          truncateText(notification.Body, 500),
+			Payload:       string(payload),
+			Status:        models.OutboxPending,
+			NextAttemptAt: &leaseUntil,
+		}
+		// 先落库再发送，进程中断时由重试任务补发
+		if err := db.Create(&entry).Error; err != nil {
+			log.Printf("写入通知发件箱失败: %v", err)
+		}
+
+		sent, err := notifyOutboxEntry(db, notifier, &entry, notification)
+		if err == nil && !sent {
+			// 用户未启用该渠道
+			if entry.ID > 0 {
+				db.Delete(&entry)
+			}
+			continue
+		}
+
+		attempted = true
+		recordOutboxAttempt(db, &entry, err)
+		if err != nil {
+			log.Printf("通过%s发送通知给用户 %d 失败: %v", notifier.Name(), notification.UserID, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", notifier.Name(), err))
+			continue
+		}
+		delivered = true
+	}
+
+	if delivered {
+		return nil
+	}
+	if attempted {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	// 没有可用渠道也记录下来，便于用户在历史中查看原因
+	entry := models.NotificationOutbox{
+		UserID:    notification.UserID,
+		Type:      notification.Type,
+		Channel:   outboxNoChannel,
+		Title:     truncateText(notification.Title, 200),
+		Body:      truncateText(notification.Body, 500),
+		Payload:   string(payload),
+		Status:    models.OutboxFailed,
+		LastError: ErrNoNotificationChannel.Error(),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("写入通知发件箱失败: %v", err)
+	}
+	return ErrNoNotificationChannel
+}
+
+// RetryPendingNotifications 重试到期的待发送通知，返回本次处理的数量
+func RetryPendingNotifications(db *gorm.DB, now time.Time) (int, error) {
+	var entries []models.NotificationOutbox
+	err := db.Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+		Order("next_attempt_at ASC").
+		Limit(outboxBatchSize).
+		Find(&entries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range entries {
+		entry := &entries[i]
+
+		// 领取记录，避免与正在进行的发送或其他实例重复
+		leaseUntil := now.Add(outboxLease)
+		result := db.Model(&models.NotificationOutbox{}).
+			Where("id = ? AND status = ? AND attempts = ?", entry.ID, models.OutboxPending, entry.Attempts).
+			Update("next_attempt_at", leaseUntil)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		processed++
+
+		var notification Notification
+		if err := json.Unmarshal([]byte(entry.Payload), &notification); err != nil {
+			failOutboxEntry(db, entry, "通知内容无法解析")
+			continue
+		}
+		if notification.ExpiresAt != nil && now.After(*notification.ExpiresAt) {
+			failOutboxEntry(db, entry, "通知已过期，不再重试")
+			continue
+		}
+		notifier := findNotifier(entry.Channel)
+		if notifier == nil {
+			failOutboxEntry(db, entry, "通知渠道不存在")
+			continue
+		}
+
+		sent, err := notifyOutboxEntry(db, notifier, entry, &notification)
+		if err == nil && !sent {
+			failOutboxEntry(db, entry, "用户已停用该渠道")
+			continue
+		}
+		recordOutboxAttempt(db, entry, err)
+	}
+	return processed, nil
+}
+
+// notifyOutboxEntry 通过渠道发送发件箱中的一条通知
+// 多目标渠道跳过该记录中已成功的目标，本次成功的目标追加到记录中，重试时不会重复发送
+func notifyOutboxEntry(db *gorm.DB, notifier Notifier, entry *models.NotificationOutbox, notification *Notification) (bool, error) {
+	targeted, ok := notifier.(TargetNotifier)
+	if !ok {
+		return notifier.Notify(notification)
+	}
+
+	var targets []string
+	if entry.DeliveredTargets != "" {
+		targets = strings.Split(entry.DeliveredTargets, ",")
+	}
+	delivered := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		delivered[target] = true
+	}
+
+	sent, succeeded, err := targeted.NotifyTargets(notification, delivered)
+	if len(succeeded) > 0 {
+		entry.DeliveredTargets = strings.Join(append(targets, succeeded...), ",")
+		if entry.ID > 0 {
+			if err := db.Model(&models.NotificationOutbox{}).Where("id = ?", entry.ID).
+				Update("delivered_targets", entry.DeliveredTargets).Error; err != nil {
+				log.Printf("更新通知发件箱 %d 失败: %v", entry.ID, err)
+			}
+		}
+	}
+	return sent, err
+}
+
+// recordOutboxAttempt 记录一次发送结果，失败时安排下次重试
+func recordOutboxAttempt(db *gorm.DB, entry *models.NotificationOutbox, sendErr error) {
+	now := time.Now()
+	entry.Attempts++
+
+	updates := map[string]interface{}{"attempts": entry.Attempts}
+	switch {
+	case sendErr == nil:
+		entry.Status = models.OutboxDelivered
+		updates["status"] = models.OutboxDelivered
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = ""
+	case entry.Attempts >= outboxMaxAttempts:
+		entry.Status = models.OutboxFailed
+		updates["status"] = models.OutboxFailed
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = truncateText(sendErr.Error(), 500)
+	default:
+		entry.Status = models.OutboxPending
+		updates["status"] = models.OutboxPending
+		updates["next_attempt_at"] = now.Add(outboxBackoff(entry.Attempts))
+		updates["last_error"] = truncateText(sendErr.Error(), 500)
+	}
+
+	if entry.ID == 0 {
+		return
+	}
+	if err := db.Model(&models.NotificationOutbox{}).Where("id = ?", entry.ID).Updates(updates).Error; err != nil {
+		log.Printf("更新通知发件箱 %d 失败: %v", entry.ID, err)
+	}
+}
+
+// failOutboxEntry 将记录标记为最终失败
+func failOutboxEntry(db *gorm.DB, entry *models.NotificationOutbox, reason string) {
+	err := db.Model(&models.NotificationOutbox{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+		"status":          models.OutboxFailed,
+		"next_attempt_at": nil,
+		"last_error":      reason,
+	}).Error
+	if err != nil {
+		log.Printf("更新通知发件箱 %d 失败: %v", entry.ID, err)
+	}
+}
+
+// outboxBackoff 第n次失败后的重试间隔：1分钟起每次翻倍，最长1小时
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+// truncateText 按字符截断文本
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit])
+}
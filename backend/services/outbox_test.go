@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{5, 16 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRecordOutboxAttempt(t *testing.T) {
+	sendErr := errors.New("推送服务返回 503")
+
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		status   string
+		want     int
+	}{
+		{"delivered", 0, nil, models.OutboxDelivered, 1},
+		{"first failure is retried", 0, sendErr, models.OutboxPending, 1},
+		{"delivered on retry", outboxMaxAttempts - 1, nil, models.OutboxDelivered, outboxMaxAttempts},
+		{"gives up after max attempts", outboxMaxAttempts - 1, sendErr, models.OutboxFailed, outboxMaxAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 未落库的记录（ID为0）只更新内存中的状态，不访问数据库
+			entry := &models.NotificationOutbox{Status: models.OutboxPending, Attempts: tt.attempts}
+			recordOutboxAttempt(nil, entry, tt.err)
+			if entry.Status != tt.status {
+				t.Errorf("status = %s, want %s", entry.Status, tt.status)
+			}
+			if entry.Attempts != tt.want {
+				t.Errorf("attempts = %d, want %d", entry.Attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"课程提醒：钢琴课", 4, "课程提醒"},
+		{strings.Repeat("a", 600), 500, strings.Repeat("a", 500)},
+	}
+
+	for _, tt := range tests {
+		if got := truncateText(tt.text, tt.limit); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// fakeTargetNotifier 记录每次发送的目标，failing中的目标发送失败
+type fakeTargetNotifier struct {
+	targets []string
+	failing map[string]bool
+	sent    [][]string
+}
+
+func (f *fakeTargetNotifier) Name() string { return "fake" }
+
+func (f *fakeTargetNotifier) Notify(notification *Notification) (bool, error) {
+	sent, _, err := f.NotifyTargets(notification, nil)
+	return sent, err
+}
+
+func (f *fakeTargetNotifier) NotifyTargets(notification *Notification, delivered map[string]bool) (bool, []string, error) {
+	var attempted, succeeded []string
+	var err error
+	for _, target := range f.targets {
+		if delivered[target] {
+			continue
+		}
+		attempted = append(attempted, target)
+		if f.failing[target] {
+			err = errors.New("目标 " + target + " 发送失败")
+			continue
+		}
+		succeeded = append(succeeded, target)
+	}
+	f.sent = append(f.sent, attempted)
+	return len(f.targets) > 0, succeeded, err
+}
+
+func TestNotifyOutboxEntrySkipsDeliveredTargets(t *testing.T) {
+	notifier := &fakeTargetNotifier{targets: []string{"1", "2", "3"}, failing: map[string]bool{"2": true}}
+	entry := &models.NotificationOutbox{Status: models.OutboxPending}
+	notification := &Notification{UserID: 1, Type: "course_reminder"}
+
+	// 第一次：2失败，整条记录进入重试
+	sent, err := notifyOutboxEntry(nil, notifier, entry, notification)
+	if !sent || err == nil {
+		t.Fatalf("first attempt = %v, %v; want sent with an error", sent, err)
+	}
+	recordOutboxAttempt(nil, entry, err)
+	if entry.Status != models.OutboxPending || entry.DeliveredTargets != "1,3" {
+		t.Fatalf("after first attempt: status = %s, delivered = %q", entry.Status, entry.DeliveredTargets)
+	}
+
+	// 重试：只发送2
+	delete(notifier.failing, "2")
+	sent, err = notifyOutboxEntry(nil, notifier, entry, notification)
+	if !sent || err != nil {
+		t.Fatalf("retry = %v, %v; want delivered", sent, err)
+	}
+	recordOutboxAttempt(nil, entry, err)
+	if entry.Status != models.OutboxDelivered || entry.DeliveredTargets != "1,3,2" {
+		t.Errorf("after retry: status = %s, delivered = %q", entry.Status, entry.DeliveredTargets)
+	}
+	if len(notifier.sent) != 2 || strings.Join(notifier.sent[1], ",") != "2" {
+		t.Errorf("sent = %v, want the retry to send only to 2", notifier.sent)
+	}
+}
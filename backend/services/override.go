@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrOccurrenceCancelled 课程已被机构取消
+var ErrOccurrenceCancelled = errors.New("该次课程已被机构取消，不能消耗课时")
+
+// CreateOccurrenceOverride 创建单次调课/取消记录，并同步调整对应的出勤记录
+// 必须在事务中调用，course需通过PreloadForOccurrences预加载关联
+func CreateOccurrenceOverride(tx *gorm.DB, course *models.Course, req models.OccurrenceOverrideRequest) (*models.OccurrenceOverride, error) {
+	if _, err := time.Parse("2006-01-02", req.OriginalDate); err != nil {
+		return nil, errors.New("原上课日期格式必须为YYYY-MM-DD")
+	}
+
+	// 原上课时间必须是一次正常排课
+	found := false
+	for _, occurrence := range FindOccurrences(course, req.OriginalDate) {
+		if occurrence.Override == nil && occurrence.Schedule.StartTime == req.OriginalStartTime {
+			found = true
+			break
+		}
+	}
+	if !found {
+		for _, override := range course.Overrides {
+			if overrideKey(override.OriginalDate, override.OriginalStartTime) == overrideKey(req.OriginalDate, req.OriginalStartTime) {
+				return nil, errors.New("该次课程已调整过，请先撤销原调整")
+			}
+		}
+		return nil, errors.New("原上课时间没有对应的排课")
+	}
+
+	override := models.OccurrenceOverride{
+		CourseID:          course.ID,
+		OriginalDate:      req.OriginalDate,
+		OriginalStartTime: req.OriginalStartTime,
+		Action:            req.Action,
+		Reason:            req.Reason,
+	}
+	if req.Action == models.OverrideReschedule {
+		newDate := req.NewDate
+		override.NewDate = &newDate
+		override.NewStartTime = req.NewStartTime
+		override.NewEndTime = req.NewEndTime
+		override.Location = req.Location
+	}
+
+	if err := tx.Create(&override).Error; err != nil {
+		return nil, err
+	}
+
+	var attendance models.AttendanceRecord
+	if err := tx.Where("course_id = ? AND schedule_date = ?", course.ID, req.OriginalDate).First(&attendance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &override, nil
+		}
+		return nil, err
+	}
+
+	switch req.Action {
+	case models.OverrideCancel:
+		// 机构取消的课不消耗课时，退回已消耗的课时
+		if err := tx.Where("attendance_id = ?", attendance.ID).Delete(&models.SessionConsumption{}).Error; err != nil {
+			return nil, err
+		}
+		notes := "机构取消"
+		if req.Reason != "" {
+			notes = fmt.Sprintf("机构取消：%s", req.Reason)
+		}
+		if err := tx.Model(&attendance).Updates(map[string]interface{}{
+			"status": models.AttendanceCancelledByProvider,
+			"notes":  notes,
+		}).Error; err != nil {
+			return nil, err
+		}
+	case models.OverrideReschedule:
+		// 尚未上课的出勤记录随课程移动到新日期
+		if err := moveAttendance(tx, &attendance, req.NewDate); err != nil {
+			return nil, err
+		}
+	}
+
+	return &override, nil
+}
+
+// DeleteOccurrenceOverride 撤销单次调课/取消，调课时把未上课的出勤记录移回原日期
+// 撤销取消时，未安排补课的机构取消出勤记录恢复为待上课；必须在事务中调用
+func DeleteOccurrenceOverride(tx *gorm.DB, override *models.OccurrenceOverride) error {
+	if err := tx.Delete(override).Error; err != nil {
+		return err
+	}
+
+	if override.Action == models.OverrideCancel {
+		var madeUpIDs []uint
+		if err := tx.Model(&models.AttendanceRecord{}).
+			Where("course_id = ? AND makeup_for_id IS NOT NULL", override.CourseID).
+			Pluck("makeup_for_id", &madeUpIDs).Error; err != nil {
+			return err
+		}
+		query := tx.Model(&models.AttendanceRecord{}).
+			Where("course_id = ? AND schedule_date = ? AND status = ?", override.CourseID, models.DateOnly(override.OriginalDate), models.AttendanceCancelledByProvider)
+		if len(madeUpIDs) > 0 {
+			query = query.Where("id NOT IN ?", madeUpIDs)
+		}
+		return query.Update("status", models.AttendancePending).Error
+	}
+
+	if override.Action != models.OverrideReschedule || override.NewDate == nil {
+		return nil
+	}
+
+	var attendance models.AttendanceRecord
+	if err := tx.Where("course_id = ? AND schedule_date = ?", override.CourseID, models.DateOnly(*override.NewDate)).First(&attendance).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return moveAttendance(tx, &attendance, models.DateOnly(override.OriginalDate))
+}
+
+// CheckAttendanceDate 检查指定日期是否可以创建出勤记录
+// course需通过PreloadForOccurrences预加载关联
+func CheckAttendanceDate(course *models.Course, date string) error {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return errors.New("日期格式必须为YYYY-MM-DD")
+	}
+
+	occurrences := FindOccurrences(course, date)
+	if len(occurrences) > 0 && allCancelled(occurrences) {
+		return errors.New("该次课程已被机构取消")
+	}
+
+	if len(occurrences) == 0 {
+		for _, override := range course.Overrides {
+			if override.Action == models.OverrideReschedule && override.NewDate != nil && models.DateOnly(override.OriginalDate) == date {
+				return fmt.Errorf("该次课程已调至 %s %s", models.DateOnly(*override.NewDate), override.NewStartTime)
+			}
+		}
+	}
+	return nil
+}
+
+// IsOccurrenceCancelled 检查课程在指定日期的课是否全部被机构取消
+func IsOccurrenceCancelled(db *gorm.DB, courseID uint, date string) (bool, error) {
+	var cancelCount int64
+	err := db.Model(&models.OccurrenceOverride{}).
+		Where("course_id = ? AND original_date = ? AND action = ?", courseID, models.DateOnly(date), models.OverrideCancel).
+		Count(&cancelCount).Error
+	if err != nil || cancelCount == 0 {
+		return false, err
+	}
+
+	var course models.Course
+	if err := PreloadForOccurrences(db).First(&course, courseID).Error; err != nil {
+		return false, err
+	}
+
+	occurrences := FindOccurrences(&course, date)
+	return len(occurrences) > 0 && allCancelled(occurrences), nil
+}
+
+// allCancelled 上课实例是否全部被取消
+func allCancelled(occurrences []Occurrence) bool {
+	for _, occurrence := range occurrences {
+		if !occurrence.Cancelled() {
+			return false
+		}
+	}
+	return true
+}
+
+// moveAttendance 将未上课的出勤记录移动到新日期（新日期已有记录时不移动）
+func moveAttendance(tx *gorm.DB, attendance *models.AttendanceRecord, date string) error {
+	if attendance.Status != models.AttendancePending {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.AttendanceRecord{}).Where("course_id = ? AND schedule_date = ?", attendance.CourseID, date).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return tx.Model(attendance).Updates(map[string]interface{}{
+		"schedule_date": date,
+		"reminder_sent": false,
+	}).Error
+}
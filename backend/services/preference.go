@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// maxReminderLead 提醒提前量上限（7天）
+const maxReminderLead = 7 * 24 * time.Hour
+
+// GetNotificationPreference 获取用户通知偏好，没有记录时返回默认值
+func GetNotificationPreference(userID uint) (*models.NotificationPreference, error) {
+	var preference models.NotificationPreference
+	err := database.GetDB().Where(models.NotificationPreference{UserID: userID}).
+		Attrs(models.NotificationPreference{EmailEnabled: false}).
+		FirstOrInit(&preference).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// GetMutedCourseIDs 获取用户关闭提醒的课程
+func GetMutedCourseIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	courseIDs := []uint{}
+	err := db.Model(&models.NotificationMute{}).
+		Where("user_id = ?", userID).
+		Order("course_id ASC").
+		Pluck("course_id", &courseIDs).Error
+	return courseIDs, err
+}
+
+// SetMutedCourses 替换用户关闭提醒的课程（只保留属于该用户的课程）
+func SetMutedCourses(tx *gorm.DB, userID uint, courseIDs []uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.NotificationMute{}).Error; err != nil {
+		return err
+	}
+	if len(courseIDs) == 0 {
+		return nil
+	}
+
+	var owned []uint
+	if err := tx.Model(&models.Course{}).
+		Where("id IN ? AND user_id = ?", courseIDs, userID).
+		Pluck("id", &owned).Error; err != nil {
+		return err
+	}
+	if len(owned) != len(uniqueUints(courseIDs)) {
+		return errors.New("静音的课程不存在")
+	}
+
+	mutes := make([]models.NotificationMute, len(owned))
+	for i, courseID := range owned {
+		mutes[i] = models.NotificationMute{UserID: userID, CourseID: courseID}
+	}
+	return tx.Create(&mutes).Error
+}
+
+// BuildPreferenceResponse 构建通知偏好响应（填充默认值）
+func BuildPreferenceResponse(preference *models.NotificationPreference, mutedCourseIDs []uint) models.NotificationPreferenceResponse {
+	leadMinutes := make([]int, 0)
+	for _, lead := range ReminderLeadTimes(preference) {
+		leadMinutes = append(leadMinutes, int(lead.Minutes()))
+	}
+	if mutedCourseIDs == nil {
+		mutedCourseIDs = []uint{}
+	}
+	return models.NotificationPreferenceResponse{
+		EmailEnabled:   preference.EmailEnabled,
+		LeadMinutes:    leadMinutes,
+		QuietStart:     preference.QuietStart,
+		QuietEnd:       preference.QuietEnd,
+		MutedCourseIDs: mutedCourseIDs,
+	}
+}
+
+// ReminderLeadTimes 用户的提醒提前量（从大到小），未设置时使用系统默认
+func ReminderLeadTimes(preference *models.NotificationPreference) []time.Duration {
+	var leadTimes []time.Duration
+	if preference != nil && preference.LeadMinutes != "" {
+		seen := make(map[int]bool)
+		for _, minutes := range parseLeadMinutes(preference.LeadMinutes) {
+			if !seen[minutes] {
+				seen[minutes] = true
+				leadTimes = append(leadTimes, time.Duration(minutes)*time.Minute)
+			}
+		}
+	}
+	if len(leadTimes) == 0 {
+		leadTimes = DefaultReminderLeadTimes()
+	}
+	sort.Slice(leadTimes, func(i, j int) bool { return leadTimes[i] > leadTimes[j] })
+	return leadTimes
+}
+
+// FormatLeadMinutes 将提前量格式化为存储格式（去重、从大到小）
+func FormatLeadMinutes(leadMinutes []int) string {
+	seen := make(map[int]bool)
+	var values []int
+	for _, minutes := range leadMinutes {
+		if minutes > 0 && !seen[minutes] {
+			seen[minutes] = true
+			values = append(values, minutes)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+
+	parts := make([]string, len(values))
+	for i, minutes := range values {
+		parts[i] = strconv.Itoa(minutes)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ValidateClock 验证 HH:MM 格式的时间
+func ValidateClock(value string) error {
+	if _, err := time.Parse("15:04", value); err != nil || len(value) != 5 {
+		return errors.New("时间格式必须为HH:MM")
+	}
+	return nil
+}
+
+// InQuietHours 判断时间点是否处于用户的免打扰时段，loc 为用户时区
+func InQuietHours(preference *models.NotificationPreference, loc *time.Location, t time.Time) bool {
+	start, end, ok := quietWindow(preference)
+	if !ok {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// 跨零点，如 22:00-07:00
+	return minute >= start || minute < end
+}
+
+// quietHoursEnd 获取t所在免打扰时段的结束时间
+func quietHoursEnd(preference *models.NotificationPreference, loc *time.Location, t time.Time) time.Time {
+	_, end, _ := quietWindow(preference)
+	local := t.In(loc)
+	candidate := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !candidate.After(local) {
+		candidate = candidate.AddDate(0, 0, 1)
+	}
+	return candidate
+}
+
+// quietWindow 免打扰时段（当天的分钟数）
+func quietWindow(preference *models.NotificationPreference) (int, int, bool) {
+	if preference == nil || preference.QuietStart == "" || preference.QuietEnd == "" {
+		return 0, 0, false
+	}
+	start, err1 := time.Parse("15:04", preference.QuietStart)
+	end, err2 := time.Parse("15:04", preference.QuietEnd)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute == endMinute {
+		return 0, 0, false
+	}
+	return startMinute, endMinute, true
+}
+
+// parseLeadMinutes 解析逗号分隔的分钟数
+func parseLeadMinutes(value string) []int {
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || minutes <= 0 || time.Duration(minutes)*time.Minute > maxReminderLead {
+			continue
+		}
+		values = append(values, minutes)
+	}
+	return values
+}
+
+// uniqueUints 去重
+func uniqueUints(values []uint) []uint {
+	seen := make(map[uint]bool, len(values))
+	var result []uint
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func TestReminderLeadTimes(t *testing.T) {
+	t.Setenv("REMINDER_LEAD_MINUTES", "1440")
+
+	tests := []struct {
+		name        string
+		leadMinutes string
+		want        []time.Duration
+	}{
+		{"default", "", []time.Duration{24 * time.Hour}},
+		{"sorted largest first", "60,1440,15", []time.Duration{24 * time.Hour, time.Hour, 15 * time.Minute}},
+		{"duplicates removed", "60, 60,30", []time.Duration{time.Hour, 30 * time.Minute}},
+		{"invalid values skipped", "abc,-5,20000,30", []time.Duration{30 * time.Minute}},
+		{"all invalid uses default", "0,abc", []time.Duration{24 * time.Hour}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReminderLeadTimes(&models.NotificationPreference{LeadMinutes: tt.leadMinutes})
+			if len(got) != len(tt.want) {
+				t.Fatalf("lead times = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("lead times = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFormatLeadMinutes(t *testing.T) {
+	tests := []struct {
+		leadMinutes []int
+		want        string
+	}{
+		{nil, ""},
+		{[]int{60, 1440}, "1440,60"},
+		{[]int{30, 30, 0, 10}, "30,10"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatLeadMinutes(tt.leadMinutes); got != tt.want {
+			t.Errorf("FormatLeadMinutes(%v) = %q, want %q", tt.leadMinutes, got, tt.want)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 5, hour, minute, 0, 0, shanghai)
+	}
+
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		at      time.Time
+		quiet   bool
+		resumes time.Time
+	}{
+		{"not configured", "", "", day(23, 0), false, time.Time{}},
+		{"same start and end", "22:00", "22:00", day(22, 0), false, time.Time{}},
+		{"inside daytime window", "12:00", "14:00", day(13, 30), true, day(14, 0)},
+		{"end is exclusive", "12:00", "14:00", day(14, 0), false, time.Time{}},
+		{"before midnight", "22:00", "07:00", day(23, 15), true, day(7, 0).AddDate(0, 0, 1)},
+		{"after midnight", "22:00", "07:00", day(6, 59), true, day(7, 0)},
+		{"outside overnight window", "22:00", "07:00", day(12, 0), false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preference := &models.NotificationPreference{QuietStart: tt.start, QuietEnd: tt.end}
+			if got := InQuietHours(preference, shanghai, tt.at.UTC()); got != tt.quiet {
+				t.Fatalf("InQuietHours = %v, want %v", got, tt.quiet)
+			}
+			if !tt.quiet {
+				return
+			}
+			if got := quietHoursEnd(preference, shanghai, tt.at.UTC()); !got.Equal(tt.resumes) {
+				t.Errorf("quietHoursEnd = %v, want %v", got, tt.resumes)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 购买记录错误
+var (
+	// ErrPurchaseBelowConsumed 修改或删除购买记录后课时总数少于已消耗课时
+	ErrPurchaseBelowConsumed = errors.New("已消耗的课时超过修改后的课时总数")
+	// ErrInvalidPurchase 购买记录请求校验失败
+	ErrInvalidPurchase = errors.New("购买记录无效")
+)
+
+// CreateInitialPurchase 为新建课程记录初始课包，课程的金额、课时和到期日作为第一条购买记录
+// 必须在事务中调用
+func CreateInitialPurchase(tx *gorm.DB, course *models.Course) error {
+	purchase := models.CoursePurchase{
+		CourseID:        course.ID,
+		Kind:            models.PurchaseInitial,
+		PurchasedOn:     course.CreatedAt.Format("2006-01-02"),
+		AmountPaid:      course.TotalAmount,
+		RegularSessions: course.RegularSessions,
+		BonusSessions:   course.BonusSessions,
+		ExpiresOn:       course.ExpiresOn,
+	}
+	return tx.Create(&purchase).Error
+}
+
+// CreateCoursePurchase 续费或加购课时，并重新汇总课程总数
+// 必须在事务中调用
+func CreateCoursePurchase(tx *gorm.DB, courseID uint, req models.CoursePurchaseRequest) (*models.CoursePurchase, error) {
+	if _, _, err := lockCourseBalance(tx, courseID); err != nil {
+		return nil, err
+	}
+
+	purchase := models.CoursePurchase{CourseID: courseID, Kind: models.PurchaseTopUp}
+	if err := applyPurchaseRequest(&purchase, req); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&purchase).Error; err != nil {
+		return nil, err
+	}
+	if err := SyncCourseTotals(tx, courseID); err != nil {
+		return nil, err
+	}
+	return &purchase, nil
+}
+
+// UpdateCoursePurchase 修改购买记录，修改后课时总数不能少于已消耗课时
+// 必须在事务中调用
+func UpdateCoursePurchase(tx *gorm.DB, purchase *models.CoursePurchase, req models.CoursePurchaseRequest) error {
+	if _, _, err := lockCourseBalance(tx, purchase.CourseID); err != nil {
+		return err
+	}
+
+	if err := applyPurchaseRequest(purchase, req); err != nil {
+		return err
+	}
+	if err := tx.Save(purchase).Error; err != nil {
+		return err
+	}
+	if err := SyncCourseTotals(tx, purchase.CourseID); err != nil {
+		return err
+	}
+	return ensurePurchasesCoverConsumption(tx, purchase.CourseID)
+}
+
+// DeleteCoursePurchase 删除购买记录，删除后课时总数不能少于已消耗课时
+// 必须在事务中调用
+func DeleteCoursePurchase(tx *gorm.DB, purchase *models.CoursePurchase) error {
+	if _, _, err := lockCourseBalance(tx, purchase.CourseID); err != nil {
+		return err
+	}
+
+	if err := tx.Delete(purchase).Error; err != nil {
+		return err
+	}
+	if err := SyncCourseTotals(tx, purchase.CourseID); err != nil {
+		return err
+	}
+	return ensurePurchasesCoverConsumption(tx, purchase.CourseID)
+}
+
+// RecordCourseTotalsChange 直接编辑课程金额、课时或到期日时，差额记为一条调整记录，保证课程总数仍由购买记录汇总
+// 修改后课时总数不能少于已消耗课时；expiresOn为nil时不修改到期日，空字符串清除；必须在事务中调用
+func RecordCourseTotalsChange(tx *gorm.DB, courseID uint, totalAmount float64, regularSessions, bonusSessions int, expiresOn *string) error {
+	locked, _, err := lockCourseBalance(tx, courseID)
+	if err != nil {
+		return err
+	}
+
+	adjustment := models.CoursePurchase{
+		CourseID:        courseID,
+		Kind:            models.PurchaseAdjustment,
+		PurchasedOn:     time.Now().Format("2006-01-02"),
+		AmountPaid:      roundCents(totalAmount - locked.TotalAmount),
+		RegularSessions: regularSessions - locked.RegularSessions,
+		BonusSessions:   bonusSessions - locked.BonusSessions,
+		Notes:           "编辑课程时调整",
+	}
+	if adjustment.AmountPaid != 0 || adjustment.RegularSessions != 0 || adjustment.BonusSessions != 0 {
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+	}
+
+	// 到期日取各购买记录的最晚到期日：清除时清空全部，修改时更新最近一次购买并截断更晚的到期日
+	current := ""
+	if locked.ExpiresOn != nil {
+		current = models.DateOnly(*locked.ExpiresOn)
+	}
+	if expiresOn != nil && *expiresOn != current {
+		purchases := tx.Model(&models.CoursePurchase{}).Where("course_id = ?", courseID)
+		if *expiresOn == "" {
+			err = purchases.Update("expires_on", nil).Error
+		} else {
+			var latest models.CoursePurchase
+			if err := tx.Where("course_id = ? AND kind <> ?", courseID, models.PurchaseAdjustment).
+				Order("purchased_on DESC, id DESC").
+				First(&latest).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			err = purchases.Where("id = ? OR expires_on > ?", latest.ID, *expiresOn).Update("expires_on", *expiresOn).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := SyncCourseTotals(tx, courseID); err != nil {
+		return err
+	}
+	return ensurePurchasesCoverConsumption(tx, courseID)
+}
+
+// SyncCourseTotals 按购买记录重新汇总课程的金额、正式/赠送课时和到期日（最晚到期日）
+func SyncCourseTotals(tx *gorm.DB, courseID uint) error {
+	var totals struct {
+		TotalAmount     float64
+		RegularSessions int
+		BonusSessions   int
+		ExpiresOn       *string
+	}
+	err := tx.Model(&models.CoursePurchase{}).
+		Select("COALESCE(SUM(amount_paid), 0) AS total_amount, COALESCE(SUM(regular_sessions), 0) AS regular_sessions, " +
+			"COALESCE(SUM(bonus_sessions), 0) AS bonus_sessions, MAX(expires_on) AS expires_on").
+		Where("course_id = ?", courseID).
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+
+	var expiresOn interface{}
+	if totals.ExpiresOn != nil {
+		expiresOn = models.DateOnly(*totals.ExpiresOn)
+	}
+	return tx.Model(&models.Course{}).Where("id = ?", courseID).Updates(map[string]interface{}{
+		"total_amount":     totals.TotalAmount,
+		"regular_sessions": totals.RegularSessions,
+		"bonus_sessions":   totals.BonusSessions,
+		"expires_on":       expiresOn,
+	}).Error
+}
+
+// ensurePurchasesCoverConsumption 检查各课时池的总数仍不少于已消耗课时
+func ensurePurchasesCoverConsumption(tx *gorm.DB, courseID uint) error {
+	var course models.Course
+	if err := tx.First(&course, courseID).Error; err != nil {
+		return err
+	}
+	balance, err := GetCourseBalance(tx, &course)
+	if err != nil {
+		return err
+	}
+	if balance.RegularConsumed > balance.RegularTotal || balance.BonusConsumed > balance.BonusTotal {
+		return ErrPurchaseBelowConsumed
+	}
+	return nil
+}
+
+// applyPurchaseRequest 校验请求并写入购买记录，初始课包和调整记录的类型保持不变；校验失败返回 ErrInvalidPurchase
+func applyPurchaseRequest(purchase *models.CoursePurchase, req models.CoursePurchaseRequest) error {
+	if _, err := time.Parse("2006-01-02", req.PurchasedOn); err != nil {
+		return fmt.Errorf("%w: 购买日期格式必须为YYYY-MM-DD", ErrInvalidPurchase)
+	}
+	purchase.ExpiresOn = nil
+	if req.ExpiresOn != "" {
+		if _, err := time.Parse("2006-01-02", req.ExpiresOn); err != nil {
+			return fmt.Errorf("%w: 到期日格式必须为YYYY-MM-DD", ErrInvalidPurchase)
+		}
+		if req.ExpiresOn < req.PurchasedOn {
+			return fmt.Errorf("%w: 到期日不能早于购买日期", ErrInvalidPurchase)
+		}
+		expiresOn := req.ExpiresOn
+		purchase.ExpiresOn = &expiresOn
+	}
+	if req.RegularSessions == 0 && req.BonusSessions == 0 && req.AmountPaid == 0 {
+		return fmt.Errorf("%w: 购买记录的金额和课时不能都为0", ErrInvalidPurchase)
+	}
+
+	receiptImages := ""
+	if len(req.ReceiptImages) > 0 {
+		imagesJSON, err := json.Marshal(req.ReceiptImages)
+		if err != nil {
+			return fmt.Errorf("%w: 收据图片格式错误", ErrInvalidPurchase)
+		}
+		receiptImages = string(imagesJSON)
+	}
+
+	if req.Kind != "" && purchase.Kind != models.PurchaseInitial && purchase.Kind != models.PurchaseAdjustment {
+		purchase.Kind = req.Kind
+	}
+	purchase.PurchasedOn = req.PurchasedOn
+	purchase.AmountPaid = req.AmountPaid
+	purchase.RegularSessions = req.RegularSessions
+	purchase.BonusSessions = req.BonusSessions
+	purchase.ReceiptImages = receiptImages
+	purchase.Notes = req.Notes
+	return nil
+}
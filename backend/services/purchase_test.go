@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSyncCourseTotals(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	// 金额和课时求和，到期日取最晚的到期日
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount_paid\\), 0\\) AS total_amount, .* MAX\\(expires_on\\) AS expires_on FROM `course_purchases` WHERE course_id = \\? AND `course_purchases`.`deleted_at` IS NULL").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"total_amount", "regular_sessions", "bonus_sessions", "expires_on"}).
+			AddRow(3200.5, 24, 2, "2026-09-30T00:00:00Z"))
+	mock.ExpectExec("UPDATE `courses` SET `bonus_sessions`=\\?,`expires_on`=\\?,`regular_sessions`=\\?,`total_amount`=\\?,`updated_at`=\\? WHERE id = \\?").
+		WithArgs(2, "2026-09-30", 24, 3200.5, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := SyncCourseTotals(db, 5); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPurchaseRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     models.CoursePurchaseRequest
+		invalid bool
+	}{
+		{name: "valid", req: models.CoursePurchaseRequest{PurchasedOn: "2026-01-05", RegularSessions: 10, ReceiptImages: []string{"/uploads/a.jpg"}}},
+		{name: "bad purchase date", req: models.CoursePurchaseRequest{PurchasedOn: "2026/01/05", RegularSessions: 10}, invalid: true},
+		{name: "expires before purchase", req: models.CoursePurchaseRequest{PurchasedOn: "2026-01-05", ExpiresOn: "2026-01-04", RegularSessions: 10}, invalid: true},
+		{name: "empty purchase", req: models.CoursePurchaseRequest{PurchasedOn: "2026-01-05"}, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchase := models.CoursePurchase{Kind: models.PurchaseTopUp}
+			err := applyPurchaseRequest(&purchase, tt.req)
+			if errors.Is(err, ErrInvalidPurchase) != tt.invalid {
+				t.Fatalf("err = %v, want invalid %v", err, tt.invalid)
+			}
+			if !tt.invalid && purchase.ReceiptImages != `["/uploads/a.jpg"]` {
+				t.Errorf("receiptImages = %s", purchase.ReceiptImages)
+			}
+		})
+	}
+}
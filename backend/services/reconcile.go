@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// reconcileLookbackDays 自动处理的回看天数，更早的待上课记录保持不变
+const reconcileLookbackDays = 7
+
+// reconcileNote 自动处理时写入出勤备注
+const reconcileNote = "未确认出勤，已按课程设置自动处理"
+
+// ReconciledAttendance 一次被自动处理的出勤
+type ReconciledAttendance struct {
+	CourseID     uint   `json:"courseId"`
+	CourseName   string `json:"courseName"`
+	AttendanceID uint   `json:"attendanceId"`
+	Date         string `json:"date"`
+	StartTime    string `json:"startTime"`
+	Status       string `json:"status"`   // no_show / attend
+	Consumed     bool   `json:"consumed"` // 是否已消课（课时不足时只标记出勤）
+}
+
+// GetResultText 获取处理结果描述
+func (r ReconciledAttendance) GetResultText() string {
+	switch {
+	case r.Status == models.AttendanceNoShow:
+		return "标记缺席"
+	case r.Consumed:
+		return "标记出勤并消课"
+	default:
+		return "标记出勤（课时不足，未消课）"
+	}
+}
+
+// ReconcileMissedAttendance 按课程的 MissedPolicy 处理已过去仍为待上课的课，并向每个用户发送汇总通知
+// "已过去"指上课日期早于该次课所在时区的今天，返回处理的出勤数
+func ReconcileMissedAttendance(db *gorm.DB, now time.Time) (int, error) {
+	var courses []models.Course
+	err := PreloadForOccurrences(db.Where("is_active = ? AND missed_policy IN ?", true,
+		[]string{models.MissedPolicyAbsent, models.MissedPolicyAttend})).
+		Order("user_id ASC, id ASC").
+		Find(&courses).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	// 展开覆盖所有时区的回看范围，再按每次课所在时区过滤
+	to, _ := DayRange(now.UTC())
+	to = to.AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -reconcileLookbackDays-2)
+
+	var userIDs []uint
+	results := make(map[uint][]ReconciledAttendance)
+	total := 0
+	for i := range courses {
+		course := &courses[i]
+		for _, occurrence := range ExpandOccurrences(courses[i:i+1], from, to) {
+			if !shouldReconcile(occurrence, now) {
+				continue
+			}
+
+			result, err := reconcileOccurrence(db, course, occurrence)
+			if err != nil {
+				log.Printf("自动处理出勤失败 (课程ID: %d, 日期: %s): %v", course.ID, occurrence.Date, err)
+				continue
+			}
+			if result == nil {
+				continue
+			}
+			if _, exists := results[course.UserID]; !exists {
+				userIDs = append(userIDs, course.UserID)
+			}
+			results[course.UserID] = append(results[course.UserID], *result)
+			total++
+		}
+	}
+
+	for _, userID := range userIDs {
+		if err := SendReconcileSummary(userID, results[userID]); err != nil {
+			log.Printf("发送出勤处理汇总失败 (用户ID: %d): %v", userID, err)
+		}
+	}
+	return total, nil
+}
+
+// shouldReconcile 判断一次课是否需要自动处理：上课日期早于所在时区的今天且在回看范围内，
+// 并且开始时间不早于课程创建、策略开启，以及未设置开始日期的排课的创建时间（避免补扣创建之前的课）
+func shouldReconcile(occurrence Occurrence, now time.Time) bool {
+	local := now.In(occurrence.Start.Location())
+	if occurrence.Date >= local.Format("2006-01-02") ||
+		occurrence.Date < local.AddDate(0, 0, -reconcileLookbackDays).Format("2006-01-02") {
+		return false
+	}
+
+	course := occurrence.Course
+	if occurrence.Start.Before(course.CreatedAt) {
+		return false
+	}
+	if course.MissedPolicySince != nil && occurrence.Start.Before(*course.MissedPolicySince) {
+		return false
+	}
+	schedule := occurrence.Schedule
+	if (schedule.StartDate == nil || *schedule.StartDate == "") && occurrence.Start.Before(schedule.CreatedAt) {
+		return false
+	}
+	return true
+}
+
+// reconcileOccurrence 在事务中按课程策略处理一次课，出勤已确认时返回nil
+func reconcileOccurrence(db *gorm.DB, course *models.Course, occurrence Occurrence) (*ReconciledAttendance, error) {
+	var result *ReconciledAttendance
+	err := db.Transaction(func(tx *gorm.DB) error {
+		record, _, err := EnsureAttendanceRecord(tx, course.ID, occurrence.Date)
+		if err != nil {
+			return err
+		}
+		if record.Status != models.AttendancePending {
+			return nil
+		}
+
+		notes := reconcileNote
+		if record.Notes != "" {
+			notes = record.Notes + "\n" + reconcileNote
+		}
+		// absent 策略记为缺席（未请假），不消课
+		updates := map[string]interface{}{"status": models.AttendanceNoShow, "notes": notes}
+		if course.MissedPolicy == models.MissedPolicyAttend {
+			updates["status"] = models.AttendanceAttend
+			updates["check_in_time"] = occurrence.Start
+		}
+
+		// 只更新仍为待上课的记录，避免覆盖用户同时提交的出勤
+		updated := tx.Model(&models.AttendanceRecord{}).
+			Where("id = ? AND status = ?", record.ID, models.AttendancePending).
+			Updates(updates)
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return nil
+		}
+
+		result = &ReconciledAttendance{
+			CourseID:     course.ID,
+			CourseName:   course.Name,
+			AttendanceID: record.ID,
+			Date:         occurrence.Date,
+			StartTime:    occurrence.Start.Format("15:04"),
+			Status:       updates["status"].(string),
+		}
+		if result.Status != models.AttendanceAttend {
+			return nil
+		}
+
+		consumed, err := HasConsumption(tx, record.ID)
+		if err != nil {
+			return err
+		}
+		if !consumed {
+			_, err = ConsumeSessionsForAttendance(tx, course, record, 1, "未确认出勤，按课程设置自动消课")
+			if errors.Is(err, ErrInsufficientSessions) {
+				// 课时不足时只标记出勤，在汇总中提示
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		result.Consumed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
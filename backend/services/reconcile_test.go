@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"course-management-backend/models"
+)
+
+func TestShouldReconcile(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, loc)
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, loc) }
+	ptr := func(t time.Time) *time.Time { return &t }
+	startDate := "2026-01-01"
+
+	tests := []struct {
+		name        string
+		start       time.Time
+		created     time.Time
+		policySince *time.Time
+		scheduleAt  time.Time
+		startDate   *string
+		want        bool
+	}{
+		{"yesterday", at(9, 18), at(1, 9), nil, at(1, 9), nil, true},
+		{"today", at(10, 9), at(1, 9), nil, at(1, 9), nil, false},
+		{"outside lookback", at(2, 18), at(1, 9), nil, at(1, 9), nil, false},
+		{"before course created", at(8, 18), at(10, 9), nil, at(10, 9), nil, false},
+		{"same day before created", at(9, 9), at(9, 10), nil, at(9, 10), nil, false},
+		{"same day after created", at(9, 18), at(9, 10), nil, at(9, 10), nil, true},
+		{"before policy enabled", at(8, 18), at(1, 9), ptr(at(9, 0)), at(1, 9), nil, false},
+		{"after policy enabled", at(9, 18), at(1, 9), ptr(at(9, 0)), at(1, 9), nil, true},
+		{"before schedule recreated", at(8, 18), at(1, 9), nil, at(9, 12), nil, false},
+		// 排课设置了开始日期时以开始日期为准，重新保存排课不影响
+		{"schedule with start date", at(8, 18), at(1, 9), nil, at(9, 12), &startDate, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrence := Occurrence{
+				Course:   &models.Course{CreatedAt: tt.created, MissedPolicySince: tt.policySince},
+				Schedule: &models.CourseSchedule{CreatedAt: tt.scheduleAt, StartDate: tt.startDate},
+				Date:     tt.start.Format("2006-01-02"),
+				Start:    tt.start,
+			}
+			if got := shouldReconcile(occurrence, now); got != tt.want {
+				t.Errorf("shouldReconcile = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
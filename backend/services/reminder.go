@@ -0,0 +1,127 @@
+package services
+
+import (
+	"log"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reminderCatchUp 提醒时间已过但仍会补发的时长（如服务重启期间错过的提醒）
+const reminderCatchUp = 10 * time.Minute
+
+// SendDueReminders 发送到期的课程提醒，每分钟调用一次
+// 每次课按用户设置的每个提前量各提醒一次，免打扰时段内的提醒顺延到时段结束（课程开始前）
+func SendDueReminders(db *gorm.DB, now time.Time) (int, error) {
+	var preferences []models.NotificationPreference
+	if err := db.Find(&preferences).Error; err != nil {
+		return 0, err
+	}
+	preferenceByUser := make(map[uint]*models.NotificationPreference, len(preferences))
+	maxLead := time.Duration(0)
+	for _, lead := range DefaultReminderLeadTimes() {
+		if lead > maxLead {
+			maxLead = lead
+		}
+	}
+	for i := range preferences {
+		preferenceByUser[preferences[i].UserID] = &preferences[i]
+		if leads := ReminderLeadTimes(&preferences[i]); len(leads) > 0 && leads[0] > maxLead {
+			maxLead = leads[0]
+		}
+	}
+	if maxLead == 0 {
+		return 0, nil
+	}
+
+	var mutes []models.NotificationMute
+	if err := db.Find(&mutes).Error; err != nil {
+		return 0, err
+	}
+	muted := make(map[[2]uint]bool, len(mutes))
+	for _, mute := range mutes {
+		muted[[2]uint{mute.UserID, mute.CourseID}] = true
+	}
+
+	occurrences, err := LoadOccurrences(db, 0, now, now.Add(maxLead+time.Minute))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		userID := occurrence.Course.UserID
+		if muted[[2]uint{userID, occurrence.Course.ID}] {
+			continue
+		}
+
+		preference := preferenceByUser[userID]
+		if preference == nil {
+			preference = &models.NotificationPreference{UserID: userID}
+		}
+
+		// 同一时刻到期的多个提前量（如都被免打扰顺延）只发送一次
+		claimed := false
+		for _, lead := range ReminderLeadTimes(preference) {
+			if !reminderDue(preference, occurrence, lead, now) {
+				continue
+			}
+			ok, err := claimReminder(db, userID, occurrence, lead)
+			if err != nil {
+				log.Printf("记录提醒失败 (课程: %s): %v", occurrence.Course.Name, err)
+				continue
+			}
+			claimed = claimed || ok
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := SendCourseReminder(occurrence.CourseForReminder(), occurrence.Date); err != nil {
+			log.Printf("发送课程提醒失败 (课程: %s): %v", occurrence.Course.Name, err)
+			continue
+		}
+		sent++
+
+		err := db.Model(&models.AttendanceRecord{}).
+			Where("course_id = ? AND schedule_date = ?", occurrence.Course.ID, occurrence.Date).
+			Update("reminder_sent", true).Error
+		if err != nil {
+			log.Printf("更新提醒状态失败: %v", err)
+		}
+	}
+	return sent, nil
+}
+
+// reminderDue 判断某个提前量的提醒是否应在此刻发送
+func reminderDue(preference *models.NotificationPreference, occurrence *Occurrence, lead time.Duration, now time.Time) bool {
+	// 免打扰按用户时区计算（课程可能在其他时区上课）
+	loc := UserLocation(&occurrence.Course.User)
+	sendAt := occurrence.Start.Add(-lead)
+	if InQuietHours(preference, loc, sendAt) {
+		sendAt = quietHoursEnd(preference, loc, sendAt)
+	}
+	if sendAt.After(now) || now.Sub(sendAt) > reminderCatchUp {
+		return false
+	}
+	return now.Before(occurrence.Start)
+}
+
+// claimReminder 记录提醒，已记录过（已发送）时返回false
+func claimReminder(db *gorm.DB, userID uint, occurrence *Occurrence, lead time.Duration) (bool, error) {
+	entry := models.ReminderLog{
+		UserID:         userID,
+		CourseID:       occurrence.Course.ID,
+		OccurrenceDate: occurrence.Date,
+		StartTime:      occurrence.Start.Format("15:04"),
+		LeadMinutes:    int(lead.Minutes()),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
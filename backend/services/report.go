@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// reportMaxMonths 报表最多覆盖的月数
+const reportMaxMonths = 120
+
+// SpendingReportRow 某类别某月的花费与消耗
+type SpendingReportRow struct {
+	Category         string  `json:"category"`
+	Month            string  `json:"month"` // YYYY-MM
+	Spend            float64 `json:"spend"`            // 按购买日期统计的实付金额
+	Purchases        int64   `json:"purchases"`        // 购买次数（含调整）
+	ConsumedValue    float64 `json:"consumedValue"`    // 按上课日期统计的已消耗价值（正式课时按当时的单价，赠送课时不计价）
+	SessionsConsumed int64   `json:"sessionsConsumed"` // 消耗课时数（含赠送课时）
+}
+
+// SpendingReportTotal 按类别或按月的合计
+type SpendingReportTotal struct {
+	Key              string  `json:"key"`
+	Spend            float64 `json:"spend"`
+	ConsumedValue    float64 `json:"consumedValue"`
+	SessionsConsumed int64   `json:"sessionsConsumed"`
+}
+
+// SpendingReport 花费报表
+type SpendingReport struct {
+	From               string                `json:"from"`
+	To                 string                `json:"to"`
+	Rows               []SpendingReportRow   `json:"rows"`
+	ByCategory         []SpendingReportTotal `json:"byCategory"`
+	ByMonth            []SpendingReportTotal `json:"byMonth"` // 范围内每个月都有一项
+	TotalSpend         float64               `json:"totalSpend"`
+	TotalConsumedValue float64               `json:"totalConsumedValue"`
+}
+
+// BuildSpendingReport 统计用户在[from, to]（YYYY-MM-DD，含两端）内按课程类别和月份汇总的花费和消耗价值
+// 花费按购买记录的购买日期，消耗按对应出勤的上课日期，没有出勤记录的手动消课按创建时间（loc）；统计未删除的课程（含已停用）
+func BuildSpendingReport(db *gorm.DB, userID uint, from, to string, loc *time.Location) (*SpendingReport, error) {
+	fromDate, err := time.ParseInLocation("2006-01-02", from, loc)
+	if err != nil {
+		return nil, errors.New("开始日期格式必须为YYYY-MM-DD")
+	}
+	toDate, err := time.ParseInLocation("2006-01-02", to, loc)
+	if err != nil {
+		return nil, errors.New("结束日期格式必须为YYYY-MM-DD")
+	}
+	if toDate.Before(fromDate) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+	months := []string{}
+	for month := time.Date(fromDate.Year(), fromDate.Month(), 1, 0, 0, 0, 0, loc); !month.After(toDate); month = month.AddDate(0, 1, 0) {
+		months = append(months, month.Format("2006-01"))
+	}
+	if len(months) > reportMaxMonths {
+		return nil, errors.New("报表范围不能超过10年")
+	}
+
+	report := &SpendingReport{
+		From:       from,
+		To:         to,
+		Rows:       []SpendingReportRow{},
+		ByCategory: []SpendingReportTotal{},
+		ByMonth:    []SpendingReportTotal{},
+	}
+
+	var courses []models.Course
+	if err := db.Where("user_id = ?", userID).Find(&courses).Error; err != nil {
+		return nil, err
+	}
+	courseMap := make(map[uint]*models.Course, len(courses))
+	courseIDs := make([]uint, 0, len(courses))
+	for i := range courses {
+		courseMap[courses[i].ID] = &courses[i]
+		courseIDs = append(courseIDs, courses[i].ID)
+	}
+
+	rows := make(map[string]*SpendingReportRow)
+	row := func(courseID uint, month string) *SpendingReportRow {
+		category := courseMap[courseID].Category
+		if category == "" {
+			category = "general"
+		}
+		key := category + "|" + month
+		if rows[key] == nil {
+			rows[key] = &SpendingReportRow{Category: category, Month: month}
+		}
+		return rows[key]
+	}
+
+	// 查询全部购买记录：范围内的计入花费，范围之前的用于计算消课当时的单价
+	var purchases []models.CoursePurchase
+	if err := db.Select("course_id", "purchased_on", "amount_paid", "regular_sessions").
+		Where("course_id IN ? AND purchased_on <= ?", courseIDs, to).
+		Order("purchased_on ASC, id ASC").
+		Find(&purchases).Error; err != nil {
+		return nil, err
+	}
+	prices := make(map[uint][]purchasePrice)
+	for _, purchase := range purchases {
+		date := models.DateOnly(purchase.PurchasedOn)
+		prices[purchase.CourseID] = appendPurchasePrice(prices[purchase.CourseID], date, purchase.AmountPaid, purchase.RegularSessions)
+		if date < from {
+			continue
+		}
+		item := row(purchase.CourseID, monthKey(date))
+		item.Spend += purchase.AmountPaid
+		item.Purchases++
+	}
+
+	var consumptions []struct {
+		CourseID         uint
+		SessionsConsumed int
+		SessionType      string
+		ScheduleDate     *string
+		CreatedAt        time.Time
+	}
+	err = db.Table("session_consumptions AS c").
+		Select("c.course_id, c.sessions_consumed, c.session_type, a.schedule_date, c.created_at").
+		Joins("LEFT JOIN attendance_records AS a ON a.id = c.attendance_id AND a.deleted_at IS NULL").
+		Where("c.course_id IN ? AND c.deleted_at IS NULL", courseIDs).
+		Where("(a.schedule_date >= ? AND a.schedule_date <= ?) OR (a.id IS NULL AND c.created_at >= ? AND c.created_at < ?)",
+			from, to, fromDate, toDate.AddDate(0, 0, 1)).
+		Scan(&consumptions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, consumption := range consumptions {
+		date := consumption.CreatedAt.In(loc).Format("2006-01-02")
+		if consumption.ScheduleDate != nil {
+			date = models.DateOnly(*consumption.ScheduleDate)
+		}
+		item := row(consumption.CourseID, monthKey(date))
+		item.SessionsConsumed += int64(consumption.SessionsConsumed)
+		// 正式课时按上课当时的单价计价，之后的续费或调整不影响已统计的月份；赠送课时不计价
+		if consumption.SessionType == "regular" {
+			item.ConsumedValue += priceOn(prices[consumption.CourseID], date) * float64(consumption.SessionsConsumed)
+		}
+	}
+
+	byCategory := make(map[string]*SpendingReportTotal)
+	byMonth := make(map[string]*SpendingReportTotal, len(months))
+	for _, month := range months {
+		byMonth[month] = &SpendingReportTotal{Key: month}
+	}
+	for _, item := range rows {
+		item.Spend = roundCents(item.Spend)
+		item.ConsumedValue = roundCents(item.ConsumedValue)
+		report.Rows = append(report.Rows, *item)
+
+		if byCategory[item.Category] == nil {
+			byCategory[item.Category] = &SpendingReportTotal{Key: item.Category}
+		}
+		for _, total := range []*SpendingReportTotal{byCategory[item.Category], byMonth[item.Month]} {
+			total.Spend += item.Spend
+			total.ConsumedValue += item.ConsumedValue
+			total.SessionsConsumed += item.SessionsConsumed
+		}
+		report.TotalSpend += item.Spend
+		report.TotalConsumedValue += item.ConsumedValue
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Month != report.Rows[j].Month {
+			return report.Rows[i].Month < report.Rows[j].Month
+		}
+		return report.Rows[i].Category < report.Rows[j].Category
+	})
+
+	for _, total := range byCategory {
+		total.Spend = roundCents(total.Spend)
+		total.ConsumedValue = roundCents(total.ConsumedValue)
+		report.ByCategory = append(report.ByCategory, *total)
+	}
+	sort.Slice(report.ByCategory, func(i, j int) bool { return report.ByCategory[i].Key < report.ByCategory[j].Key })
+	for _, month := range months {
+		total := byMonth[month]
+		total.Spend = roundCents(total.Spend)
+		total.ConsumedValue = roundCents(total.ConsumedValue)
+		report.ByMonth = append(report.ByMonth, *total)
+	}
+	report.TotalSpend = roundCents(report.TotalSpend)
+	report.TotalConsumedValue = roundCents(report.TotalConsumedValue)
+	return report, nil
+}
+
+// purchasePrice 截至某个购买日期的累计金额和正式课时
+type purchasePrice struct {
+	Date            string
+	Amount          float64
+	RegularSessions int
+}
+
+// appendPurchasePrice 按购买日期顺序累加购买记录，同一天的购买合并为一项
+func appendPurchasePrice(prices []purchasePrice, date string, amount float64, regularSessions int) []purchasePrice {
+	entry := purchasePrice{Date: date, Amount: amount, RegularSessions: regularSessions}
+	if n := len(prices); n > 0 {
+		entry.Amount += prices[n-1].Amount
+		entry.RegularSessions += prices[n-1].RegularSessions
+		if prices[n-1].Date == date {
+			prices[n-1] = entry
+			return prices
+		}
+	}
+	return append(prices, entry)
+}
+
+// priceOn 某天的正式课时单价：该日及之前所有购买（含调整）的累计金额 / 累计正式课时；
+// 早于第一次购买的消课按第一次购买的单价
+func priceOn(prices []purchasePrice, date string) float64 {
+	if len(prices) == 0 {
+		return 0
+	}
+	index := sort.Search(len(prices), func(i int) bool { return prices[i].Date > date }) - 1
+	if index < 0 {
+		index = 0
+	}
+	if prices[index].RegularSessions <= 0 {
+		return 0
+	}
+	return prices[index].Amount / float64(prices[index].RegularSessions)
+}
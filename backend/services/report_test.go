@@ -0,0 +1,50 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriceOn(t *testing.T) {
+	var prices []purchasePrice
+	prices = appendPurchasePrice(prices, "2026-01-10", 1000, 10) // 100/课时
+	prices = appendPurchasePrice(prices, "2026-03-01", 2000, 10) // 续费后累计 3000/20
+	prices = appendPurchasePrice(prices, "2026-03-01", 0, 5)     // 同一天的调整合并：3000/25
+	prices = appendPurchasePrice(prices, "2026-05-01", -400, -5) // 调整减少：2600/20
+
+	tests := []struct {
+		date string
+		want float64
+	}{
+		{"2026-01-01", 100}, // 早于第一次购买按第一次购买
+		{"2026-01-10", 100},
+		{"2026-02-28", 100}, // 之后的续费不影响之前的消课
+		{"2026-03-01", 120},
+		{"2026-04-30", 120},
+		{"2026-05-01", 130},
+	}
+	for _, tt := range tests {
+		if got := priceOn(prices, tt.date); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("priceOn(%s) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+
+	if len(prices) != 3 {
+		t.Fatalf("same-day purchases should be merged, got %d entries", len(prices))
+	}
+
+	mixed := appendPurchasePrice(nil, "2026-01-01", 1000, 10)
+	mixed = appendPurchasePrice(mixed, "2026-02-01", 1500, 5)
+	if got := priceOn(mixed, "2026-02-15"); math.Abs(got-2500.0/15) > 1e-9 {
+		t.Errorf("priceOn after top-up = %v, want %v", got, 2500.0/15)
+	}
+	if got := priceOn(mixed, "2026-01-31"); got != 100 {
+		t.Errorf("priceOn before top-up = %v, want 100", got)
+	}
+	if got := priceOn(nil, "2026-01-01"); got != 0 {
+		t.Errorf("priceOn without purchases = %v, want 0", got)
+	}
+	if got := priceOn(appendPurchasePrice(nil, "2026-01-01", 500, 0), "2026-01-02"); got != 0 {
+		t.Errorf("priceOn without regular sessions = %v, want 0", got)
+	}
+}
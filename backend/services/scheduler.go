@@ -1,161 +1,363 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
+	"course-management-backend/config"
 	"course-management-backend/database"
 	"course-management-backend/models"
 
 	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
 )
 
+// 任务错误
+var (
+	ErrJobNotFound = errors.New("定时任务不存在")
+	ErrJobRunning  = errors.New("定时任务正在运行")
+	ErrJobLeased   = errors.New("定时任务由其他实例负责运行")
+)
+
+// jobRunStaleAfter 超过此时长仍为运行中的记录视为进程中断
+const jobRunStaleAfter = time.Hour
+
+// Job 命名的定时任务
+type Job struct {
+	Name        string
+	Description string
+	Spec        string        // cron表达式
+	LeaseTTL    time.Duration // 任务租约时长，应大于执行间隔
+	// Run 执行任务，返回处理的数量
+	Run func(db *gorm.DB, now time.Time) (int, error)
+}
+
+// JobInfo 任务状态
+type JobInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Spec        string                 `json:"spec"`
+	NextRunAt   *time.Time             `json:"nextRunAt"`
+	Running     bool                   `json:"running"` // 当前实例是否正在运行
+	LastRun     *models.JobRunResponse `json:"lastRun"`
+}
+
+// scheduledJob 已注册的任务
+type scheduledJob struct {
+	Job
+	entryID cron.EntryID
+	running atomic.Bool
+}
+
 // SchedulerService 定时任务服务
+// 每个进程都会启动调度器，任务执行前需获取数据库中的任务租约，多实例部署时同一任务只由一个实例执行
 type SchedulerService struct {
-	cron *cron.Cron
+	cron       *cron.Cron
+	instanceID string
+	jobs       []*scheduledJob
+}
+
+var currentScheduler struct {
+	mu        sync.RWMutex
+	scheduler *SchedulerService
+}
+
+// GetScheduler 获取当前进程中已启动的调度器，未启动时返回nil
+func GetScheduler() *SchedulerService {
+	currentScheduler.mu.RLock()
+	defer currentScheduler.mu.RUnlock()
+	return currentScheduler.scheduler
 }
 
 // NewSchedulerService 创建新的调度器服务
 func NewSchedulerService() *SchedulerService {
-	return &SchedulerService{
-		cron: cron.New(),
+	s := &SchedulerService{
+		cron:       cron.New(),
+		instanceID: NewInstanceID(),
 	}
+
+	// 每小时检查一次明天的课程
+	s.Register(Job{
+		Name:        "check_tomorrow_courses",
+		Description: "创建明天课程的出勤记录",
+		Spec:        "0 * * * *",
+		LeaseTTL:    2 * time.Hour,
+		Run:         checkTomorrowCourses,
+	})
+
+	// 每分钟按用户设置的提前量发送到期的课程提醒
+	s.Register(Job{
+		Name:        "send_due_reminders",
+		Description: "发送到期的课程提醒",
+		Spec:        "* * * * *",
+		LeaseTTL:    2 * time.Minute,
+		Run:         SendDueReminders,
+	})
+
+	// 每分钟重试发送失败的通知
+	s.Register(Job{
+		Name:        "retry_notifications",
+		Description: "重试发件箱中到期的通知",
+		Spec:        "* * * * *",
+		LeaseTTL:    2 * time.Minute,
+		Run:         RetryPendingNotifications,
+	})
+
+	// 每小时处理各时区已过零点的未确认出勤（按课程设置标记缺席或出勤消课）
+	s.Register(Job{
+		Name:        "reconcile_attendance",
+		Description: "按课程设置处理过期未确认的出勤",
+		Spec:        "10 * * * *",
+		LeaseTTL:    2 * time.Hour,
+		Run:         ReconcileMissedAttendance,
+	})
+
+	// 每小时检查课时不足和即将到期的课程
+	s.Register(Job{
+		Name:        "check_course_alerts",
+		Description: "提醒课时不足和即将到期的课程",
+		Spec:        "20 * * * *",
+		LeaseTTL:    2 * time.Hour,
+		Run:         CheckCourseAlerts,
+	})
+
+	// 每天清理过期的任务运行记录
+	s.Register(Job{
+		Name:        "prune_job_runs",
+		Description: "清理过期的任务运行记录",
+		Spec:        "30 3 * * *",
+		LeaseTTL:    2 * time.Hour,
+		Run:         pruneJobRuns,
+	})
+
+	return s
+}
+
+// Register 注册任务，需在 Start 之前调用
+func (s *SchedulerService) Register(job Job) {
+	s.jobs = append(s.jobs, &scheduledJob{Job: job})
 }
 
 // Start 启动定时任务
 func (s *SchedulerService) Start() {
-	log.Println("启动课程提醒调度器...")
+	log.Printf("启动课程提醒调度器 (实例: %s)...", s.instanceID)
 
-	// 每小时检查一次明天的课程
-	s.cron.AddFunc("0 * * * *", s.checkTomorrowCourses)
-	
-	// 每天晚上8点检查第二天的课程并发送提醒
-	s.cron.AddFunc("0 20 * * *", s.sendEveningReminders)
+	for _, job := range s.jobs {
+		job := job
+		entryID, err := s.cron.AddFunc(job.Spec, func() { s.runScheduled(job) })
+		if err != nil {
+			log.Printf("注册定时任务 %s 失败: %v", job.Name, err)
+			continue
+		}
+		job.entryID = entryID
+	}
 
 	s.cron.Start()
+
+	currentScheduler.mu.Lock()
+	currentScheduler.scheduler = s
+	currentScheduler.mu.Unlock()
+
 	log.Println("课程提醒调度器启动成功")
 }
 
-// Stop 停止定时任务
+// Stop 停止定时任务并释放持有的租约
 func (s *SchedulerService) Stop() {
 	if s.cron != nil {
-		s.cron.Stop()
+		<-s.cron.Stop().Done()
+		if db := database.GetDB(); db != nil {
+			for _, job := range s.jobs {
+				if err := ReleaseJobLease(db, job.Name, s.instanceID); err != nil {
+					log.Printf("释放任务租约 %s 失败: %v", job.Name, err)
+				}
+			}
+		}
+
+		currentScheduler.mu.Lock()
+		if currentScheduler.scheduler == s {
+			currentScheduler.scheduler = nil
+		}
+		currentScheduler.mu.Unlock()
+
 		log.Println("课程提醒调度器已停止")
 	}
 }
 
-// checkTomorrowCourses 检查明天的课程
-func (s *SchedulerService) checkTomorrowCourses() {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("检查明天课程时出错: %v", r)
+// Jobs 获取所有任务及下次执行时间、最近一次运行记录
+func (s *SchedulerService) Jobs(db *gorm.DB) ([]JobInfo, error) {
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		info := JobInfo{
+			Name:        job.Name,
+			Description: job.Description,
+			Spec:        job.Spec,
+			Running:     job.running.Load(),
+		}
+		if job.entryID != 0 {
+			if next := s.cron.Entry(job.entryID).Next; !next.IsZero() {
+				info.NextRunAt = &next
+			}
 		}
-	}()
 
-	tomorrow := time.Now().AddDate(0, 0, 1)
-	tomorrowStr := tomorrow.Format("2006-01-02")
-	weekday := int(tomorrow.Weekday())
-	if weekday == 0 {
-		weekday = 7 // 周日转换为7
+		var lastRun models.JobRun
+		err := db.Where("job_name = ?", job.Name).Order("started_at DESC, id DESC").Limit(1).Find(&lastRun).Error
+		if err != nil {
+			return nil, err
+		}
+		if lastRun.ID > 0 {
+			response := lastRun.ToResponse()
+			info.LastRun = &response
+		}
+		infos = append(infos, info)
 	}
+	return infos, nil
+}
 
-	db := database.GetDB()
-
-	var courses []models.Course
-	err := db.Where("is_active = ?", true).
-		Preload("Schedules", "weekday = ? AND is_active = ?", weekday, true).
-		Find(&courses).Error
+// HasJob 判断任务是否已注册
+func (s *SchedulerService) HasJob(name string) bool {
+	return s.findJob(name) != nil
+}
 
-	if err != nil {
-		log.Printf("查询明天课程失败: %v", err)
-		return
+// TriggerJob 立即运行任务，与定时触发一样需持有租约，其他实例持有租约时返回 ErrJobLeased
+func (s *SchedulerService) TriggerJob(name string) (*models.JobRun, error) {
+	job := s.findJob(name)
+	if job == nil {
+		return nil, ErrJobNotFound
 	}
+	return s.runLeased(job, models.JobTriggerManual)
+}
 
-	for _, course := range courses {
-		// 检查是否已经有明天的出勤记录
-		var existingRecord models.AttendanceRecord
-		err := db.Where("course_id = ? AND schedule_date = ?", course.ID, tomorrowStr).First(&existingRecord).Error
-		if err != nil {
-			// 创建明天的出勤记录
-			newRecord := models.AttendanceRecord{
-				CourseID:     course.ID,
-				ScheduleDate: tomorrowStr,
-				Status:       "pending",
-				ReminderSent: false,
-			}
-			if err := db.Create(&newRecord).Error; err != nil {
-				log.Printf("创建出勤记录失败 (课程ID: %d): %v", course.ID, err)
-			}
+// findJob 按名称查找任务
+func (s *SchedulerService) findJob(name string) *scheduledJob {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job
 		}
 	}
+	return nil
+}
 
-	log.Printf("检查明天课程完成，发现 %d 个课程", len(courses))
+// runScheduled 定时触发：持有租约时才执行
+func (s *SchedulerService) runScheduled(job *scheduledJob) {
+	run, err := s.runLeased(job, models.JobTriggerSchedule)
+	switch {
+	case errors.Is(err, ErrJobLeased):
+		return
+	case errors.Is(err, ErrJobRunning):
+		log.Printf("定时任务 %s 上次运行尚未结束，跳过本次", job.Name)
+		return
+	case err != nil:
+		log.Printf("获取任务租约 %s 失败: %v", job.Name, err)
+		return
+	}
+	if run != nil && run.Status == models.JobRunFailed {
+		log.Printf("定时任务 %s 失败: %s", job.Name, run.Error)
+	} else if run != nil && run.Processed > 0 {
+		log.Printf("定时任务 %s 完成，处理了 %d 条", job.Name, run.Processed)
+	}
 }
 
-// sendEveningReminders 发送晚间提醒
-func (s *SchedulerService) sendEveningReminders() {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("发送晚间提醒时出错: %v", r)
-		}
-	}()
+// runLeased 获取任务租约后执行任务，定时触发和手动触发都经过这里，多实例部署时同一任务只由一个实例执行
+func (s *SchedulerService) runLeased(job *scheduledJob, trigger string) (*models.JobRun, error) {
+	acquired, err := AcquireJobLease(database.GetDB(), job.Name, s.instanceID, job.LeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrJobLeased
+	}
+	return s.runJob(job, trigger)
+}
 
-	tomorrow := time.Now().AddDate(0, 0, 1)
-	tomorrowStr := tomorrow.Format("2006-01-02")
-	weekday := int(tomorrow.Weekday())
-	if weekday == 0 {
-		weekday = 7
+// runJob 执行任务并记录运行结果
+func (s *SchedulerService) runJob(job *scheduledJob, trigger string) (*models.JobRun, error) {
+	if !job.running.CompareAndSwap(false, true) {
+		return nil, ErrJobRunning
 	}
+	defer job.running.Store(false)
 
 	db := database.GetDB()
+	run := &models.JobRun{
+		JobName:     job.Name,
+		TriggeredBy: trigger,
+		Instance:    s.instanceID,
+		Status:      models.JobRunRunning,
+		StartedAt:   time.Now(),
+	}
+	if err := db.Create(run).Error; err != nil {
+		log.Printf("记录任务 %s 运行失败: %v", job.Name, err)
+	}
 
-	var coursesForTomorrow []models.Course
-	err := db.Where("is_active = ?", true).
-		Preload("Schedules", "weekday = ? AND is_active = ?", weekday, true).
-		Preload("User").
-		Preload("AttendanceRecords", "schedule_date = ?", tomorrowStr).
-		Find(&coursesForTomorrow).Error
+	processed, runErr := safeRunJob(job, db, run.StartedAt)
 
-	if err != nil {
-		log.Printf("查询明日课程失败: %v", err)
-		return
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Processed = processed
+	run.Status = models.JobRunSuccess
+	if runErr != nil {
+		run.Status = models.JobRunFailed
+		run.Error = truncateText(runErr.Error(), 1000)
+	}
+	if run.ID > 0 {
+		if err := db.Save(run).Error; err != nil {
+			log.Printf("更新任务 %s 运行记录失败: %v", job.Name, err)
+		}
 	}
+	return run, nil
+}
 
-	for _, course := range coursesForTomorrow {
-		needsReminder := true
-		
-		for _, record := range course.AttendanceRecords {
-			if record.ScheduleDate == tomorrowStr && record.ReminderSent {
-				needsReminder = false
-				break
-			}
+// safeRunJob 执行任务，panic 转为错误
+func safeRunJob(job *scheduledJob, db *gorm.DB, now time.Time) (processed int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务异常: %v", r)
 		}
+	}()
+	return job.Run(db, now)
+}
 
-		if needsReminder {
-			// 发送提醒通知
-			err := SendCourseReminder(&course, tomorrowStr)
-			if err != nil {
-				log.Printf("发送课程提醒失败 (课程: %s): %v", course.Name, err)
-				continue
-			}
+// checkTomorrowCourses 创建明天课程的出勤记录，返回明天的课程数
+// "明天"按每次上课所在的时区计算，因此先展开覆盖所有时区的范围再逐个过滤
+func checkTomorrowCourses(db *gorm.DB, now time.Time) (int, error) {
+	from, _ := DayRange(now.UTC())
+	to := from.AddDate(0, 0, 3)
 
-			// 标记提醒已发送
-			if len(course.AttendanceRecords) > 0 {
-				err := db.Model(&course.AttendanceRecords[0]).
-					Update("reminder_sent", true).Error
-				if err != nil {
-					log.Printf("更新提醒状态失败: %v", err)
-				}
-			}
-		}
+	occurrences, err := LoadOccurrences(db, 0, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("查询明天课程失败: %v", err)
 	}
 
-	log.Printf("发送晚间提醒完成，处理了 %d 个课程", len(coursesForTomorrow))
+	count := 0
+	for _, occurrence := range occurrences {
+		tomorrow := now.In(occurrence.Start.Location()).AddDate(0, 0, 1).Format("2006-01-02")
+		if occurrence.Date != tomorrow {
+			continue
+		}
+		count++
+		// 创建明天的出勤记录，已存在时跳过（course_id + schedule_date 唯一）
+		if _, _, err := EnsureAttendanceRecord(db, occurrence.Course.ID, occurrence.Date); err != nil {
+			log.Printf("创建出勤记录失败 (课程ID: %d): %v", occurrence.Course.ID, err)
+		}
+	}
+	return count, nil
 }
 
-// TriggerReminderCheck 手动触发提醒检查（用于测试）
-func (s *SchedulerService) TriggerReminderCheck() {
-	log.Println("手动触发提醒检查...")
-	s.checkTomorrowCourses()
-	s.sendEveningReminders()
-	log.Println("手动提醒检查完成")
-}
\ No newline at end of file
+// pruneJobRuns 删除保留期（JOB_RUN_RETENTION_DAYS，默认14天）之前的运行记录，并将中断的运行标记为失败
+func pruneJobRuns(db *gorm.DB, now time.Time) (int, error) {
+	err := db.Model(&models.JobRun{}).
+		Where("status = ? AND started_at < ?", models.JobRunRunning, now.Add(-jobRunStaleAfter)).
+		Updates(map[string]interface{}{"status": models.JobRunFailed, "error": "运行中断"}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	days := config.GetEnvInt("JOB_RUN_RETENTION_DAYS", 14)
+	if days <= 0 {
+		return 0, nil
+	}
+	result := db.Where("started_at < ?", now.AddDate(0, 0, -days)).Delete(&models.JobRun{})
+	return int(result.RowsAffected), result.Error
+}
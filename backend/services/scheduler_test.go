@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/gorm"
+)
+
+func TestTriggerJobRequiresLease(t *testing.T) {
+	tests := []struct {
+		name     string
+		acquired bool
+		err      error
+	}{
+		{name: "lease held by this instance", acquired: true},
+		{name: "lease held by another instance", err: ErrJobLeased},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			previous := database.DB
+			database.DB = db
+			defer func() { database.DB = previous }()
+
+			ran := false
+			s := &SchedulerService{instanceID: "instance-a"}
+			s.Register(Job{Name: "check_reminders", LeaseTTL: time.Hour, Run: func(*gorm.DB, time.Time) (int, error) {
+				ran = true
+				return 3, nil
+			}})
+
+			mock.ExpectExec("INSERT INTO `job_leases`").WillReturnResult(sqlmock.NewResult(0, 0))
+			var renewed int64
+			if tt.acquired {
+				renewed = 1
+			}
+			mock.ExpectExec("UPDATE `job_leases` SET .* WHERE name = \\? AND \\(owner = \\? OR lease_until < \\?\\)").
+				WillReturnResult(sqlmock.NewResult(0, renewed))
+			if tt.acquired {
+				mock.ExpectExec("INSERT INTO `job_runs`").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE `job_runs`").WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			run, err := s.TriggerJob("check_reminders")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if ran != tt.acquired {
+				t.Errorf("ran = %v, want %v", ran, tt.acquired)
+			}
+			if tt.acquired && (run.TriggeredBy != models.JobTriggerManual || run.Processed != 3) {
+				t.Errorf("run = %s %d, want manual 3", run.TriggeredBy, run.Processed)
+			}
+		})
+	}
+}
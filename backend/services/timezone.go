@@ -0,0 +1,80 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"course-management-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTimezone 时区不是有效的IANA名称
+var ErrInvalidTimezone = errors.New("无效的时区")
+
+// locationCache 已解析的时区，避免重复读取时区数据库
+var locationCache sync.Map
+
+// ValidateTimezone 验证IANA时区名称（空字符串表示使用服务器时区）
+func ValidateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := loadLocation(name); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+// LocationOrDefault 解析时区，为空或无效时返回fallback
+func LocationOrDefault(name string, fallback *time.Location) *time.Location {
+	if name != "" {
+		if loc, err := loadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return fallback
+}
+
+// UserLocation 用户时区，未设置时使用服务器时区
+func UserLocation(user *models.User) *time.Location {
+	if user == nil {
+		return time.Local
+	}
+	return LocationOrDefault(user.Timezone, time.Local)
+}
+
+// GetUserLocation 查询用户时区
+func GetUserLocation(db *gorm.DB, userID uint) (*time.Location, error) {
+	var user models.User
+	if err := db.Select("id", "timezone").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return UserLocation(&user), nil
+}
+
+// CourseLocation 课程的默认时区（所属用户的时区），未预加载用户时返回fallback
+func CourseLocation(course *models.Course, fallback *time.Location) *time.Location {
+	if course.User.ID == 0 {
+		return fallback
+	}
+	return UserLocation(&course.User)
+}
+
+// ScheduleLocation 排课时区：排课单独设置了时区（如异地上课）时优先，否则使用课程默认时区
+func ScheduleLocation(course *models.Course, schedule *models.CourseSchedule, fallback *time.Location) *time.Location {
+	return LocationOrDefault(schedule.Timezone, CourseLocation(course, fallback))
+}
+
+// loadLocation 带缓存的 time.LoadLocation
+func loadLocation(name string) (*time.Location, error) {
+	if cached, ok := locationCache.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
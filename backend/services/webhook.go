@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"course-management-backend/database"
+	"course-management-backend/models"
+)
+
+// Webhook 请求头
+const (
+	WebhookSignatureHeader = "X-CourseRecord-Signature" // sha256=HMAC-SHA256(secret, timestamp + "." + body)
+	WebhookTimestampHeader = "X-CourseRecord-Timestamp"
+	WebhookEventHeader     = "X-CourseRecord-Event"
+)
+
+// WebhookPayload Webhook 请求体
+type WebhookPayload struct {
+	Event  string                 `json:"event"`
+	Title  string                 `json:"title"`
+	Body   string                 `json:"body"`
+	Text   string                 `json:"text"` // 标题+正文，便于直接转发到聊天工具
+	Data   map[string]interface{} `json:"data,omitempty"`
+	SentAt time.Time              `json:"sentAt"`
+}
+
+// WebhookNotifier 外部Webhook渠道
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier 创建Webhook渠道，client为空时使用只能连接公网地址、不跟随重定向的客户端
+func NewWebhookNotifier(client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = newWebhookClient()
+	}
+	return &WebhookNotifier{client: client}
+}
+
+// ErrWebhookAddressNotAllowed Webhook地址指向本机、内网或保留地址
+var ErrWebhookAddressNotAllowed = errors.New("Webhook地址不能指向本机、内网或保留地址")
+
+// newWebhookClient 创建发送Webhook的客户端：在建立连接时检查解析后的IP（覆盖DNS重绑定），不使用代理，不跟随重定向
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicWebhookIP(ip) {
+				return ErrWebhookAddressNotAllowed
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// isPublicWebhookIP 是否为允许Webhook连接的公网地址
+func isPublicWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	// 运营商级NAT 100.64.0.0/10
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// ValidateWebhookURL 检查Webhook地址：必须为https，主机不能是localhost或非公网IP（域名在连接时再检查解析结果）
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return errors.New("Webhook地址格式错误")
+	}
+	if parsed.Scheme != "https" {
+		return errors.New("Webhook地址必须使用https")
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookAddressNotAllowed
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicWebhookIP(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// Name 渠道名称
+func (w *WebhookNotifier) Name() string { return models.ChannelTypeWebhook }
+
+// Notify 发送到用户所有启用的Webhook，任一Webhook失败都返回错误
+func (w *WebhookNotifier) Notify(notification *Notification) (bool, error) {
+	sent, _, err := w.NotifyTargets(notification, nil)
+	return sent, err
+}
+
+// NotifyTargets 发送到用户启用的、尚未成功的Webhook（目标为渠道ID），返回本次成功的渠道
+func (w *WebhookNotifier) NotifyTargets(notification *Notification, delivered map[string]bool) (bool, []string, error) {
+	var channels []models.NotificationChannel
+	err := database.GetDB().
+		Where("user_id = ? AND type = ? AND is_active = ?", notification.UserID, models.ChannelTypeWebhook, true).
+		Order("id ASC").
+		Find(&channels).Error
+	if err != nil {
+		return false, nil, fmt.Errorf("查询通知渠道失败: %v", err)
+	}
+	if len(channels) == 0 {
+		return false, nil, nil
+	}
+
+	var succeeded, errs []string
+	for i := range channels {
+		target := strconv.FormatUint(uint64(channels[i].ID), 10)
+		if delivered[target] {
+			continue
+		}
+		if err := w.Send(&channels[i], notification); err != nil {
+			log.Printf("发送Webhook %d 失败: %v", channels[i].ID, err)
+			errs = append(errs, fmt.Sprintf("Webhook %d: %v", channels[i].ID, err))
+			continue
+		}
+		succeeded = append(succeeded, target)
+	}
+
+	if len(errs) > 0 {
+		return true, succeeded, errors.New(strings.Join(errs, "; "))
+	}
+	return true, succeeded, nil
+}
+
+// Send 发送到指定Webhook，并记录最近一次发送结果
+func (w *WebhookNotifier) Send(channel *models.NotificationChannel, notification *Notification) error {
+	err := w.post(channel, notification)
+
+	updates := map[string]interface{}{"last_error": ""}
+	if err != nil {
+		updates["last_error"] = truncateText(err.Error(), 500)
+	} else {
+		updates["last_success_at"] = time.Now()
+	}
+	if updateErr := database.GetDB().Model(channel).Updates(updates).Error; updateErr != nil {
+		log.Printf("更新Webhook %d 状态失败: %v", channel.ID, updateErr)
+	}
+	return err
+}
+
+// post 签名并发送请求
+func (w *WebhookNotifier) post(channel *models.NotificationChannel, notification *Notification) error {
+	payload := WebhookPayload{
+		Event:  notification.Type,
+		Title:  notification.Title,
+		Body:   notification.Body,
+		Text:   strings.TrimSpace(notification.Title + "\n" + notification.Body),
+		Data:   notification.Data,
+		SentAt: time.Now(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateWebhookURL(channel.URL); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(payload.SentAt.Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CourseRecord-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, notification.Type)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(channel.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求Webhook失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 不返回响应内容，避免通过测试接口读取任意地址的响应
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload 计算Webhook签名（十六进制），接收方用同样方式校验
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SendTestWebhook 向指定Webhook发送测试消息
+func SendTestWebhook(channel *models.NotificationChannel) error {
+	notification := &Notification{
+		UserID: channel.UserID,
+		Type:   "test",
+		Title:  "测试通知",
+		Body:   "这是一条来自课程管理的测试消息",
+	}
+	for _, notifier := range registeredNotifiers() {
+		if webhook, ok := notifier.(*WebhookNotifier); ok {
+			return webhook.Send(channel, notification)
+		}
+	}
+	return NewWebhookNotifier(nil).Send(channel, notification)
+}
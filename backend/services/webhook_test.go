@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"course-management-backend/database"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://hooks.example.com/notify", true},
+		{"https://93.184.216.34/hook", true},
+		{"http://hooks.example.com/notify", false},
+		{"ftp://hooks.example.com/notify", false},
+		{"https://localhost/hook", false},
+		{"https://api.localhost./hook", false},
+		{"https://127.0.0.1:8080/hook", false},
+		{"https://[::1]/hook", false},
+		{"https://0.0.0.0/hook", false},
+		{"https://10.0.0.5/hook", false},
+		{"https://172.16.1.1/hook", false},
+		{"https://192.168.1.1/hook", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://100.64.0.1/hook", false},
+		{"https://[fe80::1]/hook", false},
+		{"https://[fd00::1]/hook", false},
+		{"https://[::ffff:127.0.0.1]/hook", false},
+		{"https:///hook", false},
+	}
+	for _, tt := range tests {
+		err := ValidateWebhookURL(tt.url)
+		if (err == nil) != tt.allowed {
+			t.Errorf("ValidateWebhookURL(%q) = %v, allowed want %v", tt.url, err, tt.allowed)
+		}
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	// 域名校验通过后，连接时仍按解析出的IP拦截（如DNS重绑定到127.0.0.1）
+	_, err := newWebhookClient().Get(server.URL)
+	if !errors.Is(err, ErrWebhookAddressNotAllowed) {
+		t.Fatalf("expected ErrWebhookAddressNotAllowed, got %v", err)
+	}
+	if requested {
+		t.Fatal("request reached the private address")
+	}
+}
+
+// roundTripFunc 用函数实现http.RoundTripper，不建立真实连接
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestWebhookNotifyTargets(t *testing.T) {
+	db, mock := newMockDB(t)
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+
+	mock.ExpectQuery("SELECT \\* FROM `notification_channels` WHERE \\(user_id = \\? AND type = \\? AND is_active = \\?\\)").
+		WithArgs(1, models.ChannelTypeWebhook, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "type", "url", "secret", "is_active"}).
+			AddRow(1, 1, models.ChannelTypeWebhook, "https://hooks.example.com/ok", "s1", true).
+			AddRow(2, 1, models.ChannelTypeWebhook, "https://hooks.example.com/down", "s2", true).
+			AddRow(3, 1, models.ChannelTypeWebhook, "https://hooks.example.com/sent", "s3", true))
+	// 每次发送后记录该Webhook的最近结果
+	mock.ExpectExec("UPDATE `notification_channels` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `notification_channels` SET").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var paths []string
+	notifier := NewWebhookNotifier(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		status := http.StatusOK
+		if req.URL.Path == "/down" {
+			status = http.StatusBadGateway
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})})
+
+	sent, succeeded, err := notifier.NotifyTargets(&Notification{UserID: 1, Type: "course_reminder", Title: "课程提醒"}, map[string]bool{"3": true})
+	if !sent {
+		t.Fatalf("sent = false, want true: %v", err)
+	}
+	if err == nil {
+		t.Error("expected an error for the failed webhook")
+	}
+	if strings.Join(succeeded, ",") != "1" {
+		t.Errorf("succeeded = %v, want [1]", succeeded)
+	}
+	if strings.Join(paths, ",") != "/ok,/down" {
+		t.Errorf("requested %v, want the delivered webhook to be skipped", paths)
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"course-management-backend/config"
+	"course-management-backend/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Web Push 相关常量（RFC 8291 / RFC 8292）
+const (
+	webPushRecordSize  = 4096
+	webPushDefaultTTL  = 24 * 60 * 60
+	vapidTokenLifetime = 12 * time.Hour
+)
+
+// ErrSubscriptionExpired 推送服务返回404/410，订阅已失效
+var ErrSubscriptionExpired = errors.New("推送订阅已失效")
+
+// ErrWebPushNotConfigured 未配置VAPID密钥
+var ErrWebPushNotConfigured = errors.New("未配置VAPID密钥，无法发送推送通知")
+
+// WebPushClient Web Push 发送客户端
+type WebPushClient struct {
+	HTTPClient *http.Client
+	Subject    string // VAPID联系方式，mailto: 或 https: 地址
+	TTL        int    // 推送服务保留消息的秒数
+
+	privateKey *ecdsa.PrivateKey
+	publicKey  string // base64url编码的未压缩公钥，用于 Authorization 头的 k 参数
+}
+
+var (
+	webPushMu     sync.Mutex
+	webPushClient *WebPushClient
+)
+
+// NewWebPushClient 根据base64url编码的VAPID密钥对创建客户端
+func NewWebPushClient(publicKey, privateKey, subject string, httpClient *http.Client) (*WebPushClient, error) {
+	if publicKey == "" || privateKey == "" {
+		return nil, ErrWebPushNotConfigured
+	}
+
+	raw, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID私钥格式错误: %v", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID私钥格式错误: %v", err)
+	}
+
+	derived, err := vapidPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if trimBase64Padding(publicKey) != derived {
+		return nil, errors.New("VAPID公钥与私钥不匹配")
+	}
+
+	if subject == "" {
+		subject = "mailto:admin@example.com"
+	} else if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https:") {
+		subject = "mailto:" + subject
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	return &WebPushClient{
+		HTTPClient: httpClient,
+		Subject:    subject,
+		TTL:        webPushDefaultTTL,
+		privateKey: key,
+		publicKey:  derived,
+	}, nil
+}
+
+// GetWebPushClient 获取全局推送客户端（首次调用时读取VAPID配置）
+func GetWebPushClient() (*WebPushClient, error) {
+	webPushMu.Lock()
+	defer webPushMu.Unlock()
+
+	if webPushClient != nil {
+		return webPushClient, nil
+	}
+	client, err := NewWebPushClient(
+		config.GetEnv("VAPID_PUBLIC_KEY", ""),
+		config.GetEnv("VAPID_PRIVATE_KEY", ""),
+		config.GetEnv("VAPID_EMAIL", ""),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	webPushClient = client
+	return client, nil
+}
+
+// SetWebPushClient 替换全局推送客户端（如指向本地模拟推送服务）
+func SetWebPushClient(client *WebPushClient) {
+	webPushMu.Lock()
+	defer webPushMu.Unlock()
+	webPushClient = client
+}
+
+// GetVAPIDPublicKey 获取前端订阅时使用的 applicationServerKey
+func GetVAPIDPublicKey() (string, error) {
+	client, err := GetWebPushClient()
+	if err != nil {
+		return "", err
+	}
+	return client.publicKey, nil
+}
+
+// GenerateVAPIDKeys 生成新的VAPID密钥对（base64url编码，无填充）
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	publicKey = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	privateKey = base64.RawURLEncoding.EncodeToString(key.Bytes())
+	return publicKey, privateKey, nil
+}
+
+// Send 加密并发送一条推送消息
+// 推送服务返回404/410时返回 ErrSubscriptionExpired
+func (c *WebPushClient) Send(subscription *models.PushSubscription, payload []byte) error {
+	body, err := encryptWebPushPayload(subscription.P256dh, subscription.Auth, payload)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.vapidToken(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(c.TTL))
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, c.publicKey))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求推送服务失败: %v", err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionExpired
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("推送服务返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// vapidToken 生成推送服务所在源的VAPID JWT（RFC 8292）
+func (c *WebPushClient) vapidToken(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("无效的推送地址: %s", endpoint)
+	}
+
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenLifetime).Unix(),
+		"sub": c.Subject,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(c.privateKey)
+}
+
+// encryptWebPushPayload 按 RFC 8291 使用 aes128gcm 加密消息
+func encryptWebPushPayload(p256dh, auth string, payload []byte) ([]byte, error) {
+	if len(payload)+17 > webPushRecordSize-86 {
+		return nil, errors.New("推送消息过长")
+	}
+
+	rawReceiver, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("订阅公钥格式错误: %v", err)
+	}
+	receiverKey, err := ecdh.P256().NewPublicKey(rawReceiver)
+	if err != nil {
+		return nil, fmt.Errorf("订阅公钥格式错误: %v", err)
+	}
+	authSecret, err := decodeBase64URL(auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("订阅auth格式错误")
+	}
+
+	// 每条消息使用新的临时密钥对和盐值
+	senderKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := senderKey.ECDH(receiverKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	senderPublic := senderKey.PublicKey().Bytes()
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public, 32)
+	keyInfo := "WebPush: info\x00" + string(rawReceiver) + string(senderPublic)
+	prkKey, err := hkdf.Extract(sha256.New, sharedSecret, authSecret)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 单条记录，0x02 表示最后一条记录
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	// 头部：salt(16) || rs(4) || idlen(1) || keyid(发送方公钥)
+	header := make([]byte, 0, 21+len(senderPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(senderPublic)))
+	header = append(header, senderPublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// vapidPublicKey 获取私钥对应的未压缩公钥（base64url编码）
+func vapidPublicKey(key *ecdsa.PrivateKey) (string, error) {
+	ecdhKey, err := key.ECDH()
+	if err != nil {
+		return "", fmt.Errorf("VAPID私钥格式错误: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(ecdhKey.PublicKey().Bytes()), nil
+}
+
+// decodeBase64URL 解码base64url（兼容带填充和标准base64）
+func decodeBase64URL(value string) ([]byte, error) {
+	value = strings.NewReplacer("+", "-", "/", "_").Replace(trimBase64Padding(value))
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+// trimBase64Padding 去掉base64填充
+func trimBase64Padding(value string) string {
+	return strings.TrimRight(strings.TrimSpace(value), "=")
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"course-management-backend/database"
+	"course-management-backend/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// pushRequest 模拟推送服务收到的请求
+type pushRequest struct {
+	path          string
+	authorization string
+	encoding      string
+	body          []byte
+}
+
+// pushSubscriber 浏览器端的订阅密钥
+type pushSubscriber struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newPushSubscriber(t *testing.T) *pushSubscriber {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		t.Fatal(err)
+	}
+	return &pushSubscriber{key: key, auth: auth}
+}
+
+func (s *pushSubscriber) subscription(id uint, endpoint string) models.PushSubscription {
+	return models.PushSubscription{
+		ID:       id,
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(s.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(s.auth),
+	}
+}
+
+// decrypt 按 RFC 8291 以接收方身份解密 aes128gcm 消息
+func (s *pushSubscriber) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("body too short: %d bytes", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != webPushRecordSize {
+		t.Errorf("record size = %d, want %d", rs, webPushRecordSize)
+	}
+	idlen := int(body[20])
+	if idlen != 65 || len(body) < 21+idlen {
+		t.Fatalf("keyid length = %d, want 65", idlen)
+	}
+	senderPublic := body[21 : 21+idlen]
+	ciphertext := body[21+idlen:]
+
+	senderKey, err := ecdh.P256().NewPublicKey(senderPublic)
+	if err != nil {
+		t.Fatalf("sender key: %v", err)
+	}
+	sharedSecret, err := s.key.ECDH(senderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyInfo := "WebPush: info\x00" + string(s.key.PublicKey().Bytes()) + string(senderPublic)
+	prkKey, _ := hkdf.Extract(sha256.New, sharedSecret, s.auth)
+	ikm, _ := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	// 去掉填充，最后一条记录以0x02结尾
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatalf("missing last record delimiter")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+// verifyVAPID 校验 Authorization: vapid t=..., k=... 头（RFC 8292）
+func verifyVAPID(t *testing.T, header, publicKey, audience, subject string) {
+	t.Helper()
+	if !strings.HasPrefix(header, "vapid ") {
+		t.Fatalf("authorization = %q, want vapid scheme", header)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[name] = value
+	}
+	if params["k"] != publicKey {
+		t.Fatalf("k = %q, want %q", params["k"], publicKey)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(params["k"])
+	if err != nil {
+		t.Fatalf("k: %v", err)
+	}
+	key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), raw)
+	if err != nil {
+		t.Fatalf("k: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(params["t"], claims, func(*jwt.Token) (interface{}, error) { return key, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithAudience(audience),
+	)
+	if err != nil {
+		t.Fatalf("vapid token: %v", err)
+	}
+	if _, ok := claims["exp"]; !ok {
+		t.Errorf("vapid token has no exp claim")
+	}
+	if claims["sub"] != subject {
+		t.Errorf("sub = %v, want %s", claims["sub"], subject)
+	}
+}
+
+func TestDeliverPush(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		delivered int
+		expired   bool
+	}{
+		{"created", http.StatusCreated, 1, false},
+		{"not found", http.StatusNotFound, 0, true},
+		{"gone", http.StatusGone, 0, true},
+		{"server error", http.StatusInternalServerError, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []pushRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				requests = append(requests, pushRequest{
+					path:          r.URL.Path,
+					authorization: r.Header.Get("Authorization"),
+					encoding:      r.Header.Get("Content-Encoding"),
+					body:          body,
+				})
+				mu.Unlock()
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			publicKey, privateKey, err := GenerateVAPIDKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewWebPushClient(publicKey, privateKey, "mailto:ops@example.com", server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subscriber := newPushSubscriber(t)
+			subscriptions := []models.PushSubscription{subscriber.subscription(7, server.URL+"/push/abc")}
+			payload := []byte(`{"title":"课程提醒","body":"钢琴课 18:00"}`)
+
+			var marked, removed []uint
+			delivered, lastErr := deliverPush(client, 1, subscriptions, payload,
+				func(id uint) error { marked = append(marked, id); return nil },
+				func(id uint) error { removed = append(removed, id); return nil },
+			)
+
+			if len(delivered) != tt.delivered {
+				t.Errorf("delivered = %v, want %d subscriptions", delivered, tt.delivered)
+			}
+			if tt.expired != errors.Is(lastErr, ErrSubscriptionExpired) {
+				t.Errorf("lastErr = %v, expired = %v", lastErr, tt.expired)
+			}
+			if (tt.delivered == 0) != (lastErr != nil) {
+				t.Errorf("lastErr = %v with %v delivered", lastErr, delivered)
+			}
+			if got := len(marked); got != tt.delivered {
+				t.Errorf("marked %v, want %d subscriptions", marked, tt.delivered)
+			}
+			if tt.expired && (len(removed) != 1 || removed[0] != 7) {
+				t.Errorf("removed = %v, want [7]", removed)
+			}
+			if !tt.expired && len(removed) != 0 {
+				t.Errorf("removed = %v, want none", removed)
+			}
+
+			if len(requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(requests))
+			}
+			request := requests[0]
+			if request.path != "/push/abc" {
+				t.Errorf("path = %s", request.path)
+			}
+			if request.encoding != "aes128gcm" {
+				t.Errorf("Content-Encoding = %s", request.encoding)
+			}
+			verifyVAPID(t, request.authorization, publicKey, server.URL, "mailto:ops@example.com")
+			if got := subscriber.decrypt(t, request.body); !bytes.Equal(got, payload) {
+				t.Errorf("payload = %s, want %s", got, payload)
+			}
+		})
+	}
+}
+
+func TestPushNotifyTargets(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/push/down":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/push/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewWebPushClient(publicKey, privateKey, "mailto:ops@example.com", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetWebPushClient(client)
+	defer SetWebPushClient(nil)
+
+	subscriber := newPushSubscriber(t)
+	subscriptionRows := func(endpoints ...string) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "user_id", "endpoint", "p256dh", "auth"})
+		for i, endpoint := range endpoints {
+			subscription := subscriber.subscription(uint(i+1), server.URL+endpoint)
+			rows.AddRow(subscription.ID, 1, subscription.Endpoint, subscription.P256dh, subscription.Auth)
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name      string
+		endpoints []string
+		delivered map[string]bool
+		requested string
+		succeeded string
+		updates   int // 记录推送成功时间和移除失效订阅
+		failed    bool
+	}{
+		{
+			name:      "retry skips delivered devices",
+			endpoints: []string{"/push/ok", "/push/down", "/push/sent"},
+			delivered: map[string]bool{"3": true},
+			requested: "/push/ok,/push/down",
+			succeeded: "1",
+			updates:   1,
+			failed:    true,
+		},
+		{
+			name:      "expired device is removed without retry",
+			endpoints: []string{"/push/ok", "/push/gone"},
+			requested: "/push/ok,/push/gone",
+			succeeded: "1",
+			updates:   2,
+		},
+		{
+			name:      "all devices already delivered",
+			endpoints: []string{"/push/ok"},
+			delivered: map[string]bool{"1": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			previous := database.DB
+			database.DB = db
+			defer func() { database.DB = previous }()
+
+			mock.ExpectQuery("SELECT \\* FROM `push_subscriptions` WHERE user_id = \\?").WithArgs(1).
+				WillReturnRows(subscriptionRows(tt.endpoints...))
+			for i := 0; i < tt.updates; i++ {
+				mock.ExpectExec("(UPDATE|DELETE FROM) `push_subscriptions`").WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+			paths = nil
+
+			sent, succeeded, err := pushNotifier{}.NotifyTargets(&Notification{UserID: 1, Push: map[string]interface{}{"title": "课程提醒"}}, tt.delivered)
+			if !sent {
+				t.Fatalf("sent = false: %v", err)
+			}
+			if (err != nil) != tt.failed {
+				t.Errorf("err = %v, want failed %v", err, tt.failed)
+			}
+			if got := strings.Join(succeeded, ","); got != tt.succeeded {
+				t.Errorf("succeeded = %s, want %s", got, tt.succeeded)
+			}
+			if got := strings.Join(paths, ","); got != tt.requested {
+				t.Errorf("requested %s, want %s", got, tt.requested)
+			}
+		})
+	}
+}
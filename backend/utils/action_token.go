@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"time"
+	"course-management-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ActionClaims 通知快捷操作token声明
+type ActionClaims struct {
+	UserID   uint   `json:"uid"`
+	CourseID uint   `json:"cid"`
+	Date     string `json:"date"`
+	jwt.RegisteredClaims
+}
+
+// GenerateActionToken 生成通知快捷操作token，tokenID 用于服务端记录一次性使用
+func GenerateActionToken(tokenID string, userID, courseID uint, date string, expiresAt time.Time) (string, error) {
+	claims := ActionClaims{
+		UserID:   userID,
+		CourseID: courseID,
+		Date:     date,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(actionTokenKey())
+}
+
+// ValidateActionToken 验证通知快捷操作token
+func ValidateActionToken(tokenString string) (*ActionClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("无效的签名方法")
+		}
+		return actionTokenKey(), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*ActionClaims); ok && token.Valid && claims.ID != "" {
+		return claims, nil
+	}
+
+	return nil, errors.New("无效的token")
+}
+
+// actionTokenKey 快捷操作token的签名密钥，与登录JWT区分，两种token不能互相冒用
+func actionTokenKey() []byte {
+	return []byte("attendance-action:" + config.GetEnv("JWT_SECRET", "your-secret-key"))
+}